@@ -0,0 +1,67 @@
+// Package apidocs serves the OpenAPI document of the api and documentation rendered from it
+package apidocs
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+
+	"github.com/walez/weather-monster/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Routes served by RegisterRoutes
+const (
+	SpecPath = "/openapi.json"
+	DocsPath = "/docs"
+)
+
+// WebhookSchemaPath returns the path of the JSON Schema of the webhook payloads of version
+func WebhookSchemaPath(version int) string {
+	return fmt.Sprintf("/schemas/webhooks/v%d.json", version)
+}
+
+// spec documents every route, it must be updated along with them
+//
+//go:embed openapi.json
+var spec []byte
+
+// docsPage renders the spec with redoc
+const docsPage = `<!DOCTYPE html>
+<html>
+  <head>
+    <title>Weather Monster API</title>
+    <meta charset="utf-8"/>
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+  </head>
+  <body>
+    <redoc spec-url="` + SpecPath + `"></redoc>
+    <script src="https://cdn.redoc.ly/redoc/v2.0.0/bundles/redoc.standalone.js"></script>
+  </body>
+</html>
+`
+
+// Spec returns the OpenAPI 3 document of the api
+func Spec() []byte {
+	return spec
+}
+
+func RegisterRoutes(r gin.IRoutes) {
+	r.GET(SpecPath, func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", spec)
+	})
+	r.GET(DocsPath, func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+	})
+
+	for _, version := range webhook.Versions {
+		schema, err := webhook.Schema(version)
+		if err != nil {
+			panic(err)
+		}
+		r.GET(WebhookSchemaPath(version), func(c *gin.Context) {
+			c.Data(http.StatusOK, "application/schema+json", schema)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package apidocs_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/apidocs"
+	"github.com/walez/weather-monster/gql"
+	"github.com/walez/weather-monster/health"
+	"github.com/walez/weather-monster/weather"
+	"github.com/walez/weather-monster/webhook"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type spec struct {
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadSpec(t *testing.T) *spec {
+	s := &spec{}
+	require.NoError(t, json.Unmarshal(apidocs.Spec(), s))
+	return s
+}
+
+func TestSpec_Schemas(t *testing.T) {
+	s := loadSpec(t)
+
+	schemas := map[string]interface{}{
+		"City":                     core.City{},
+		"Temperature":              core.Temperature{},
+		"Forecast":                 core.Forecast{},
+		"Webhook":                  core.Webhook{},
+		"CreatedWebhook":           weather.CreateWebhookResponse{},
+		"AuditEntry":               core.AuditEntry{},
+		"CreateCityRequest":        weather.CreateCityRequest{},
+		"UpdateCityRequest":        weather.CreateCityRequest{},
+		"CitiesResponse":           weather.CitiesResponse{},
+		"CreateTemperatureRequest": weather.CreateTemperatureRequest{},
+		"CreateWebhookRequest":     weather.CreateWebhookRequest{},
+		"AuditResponse":            weather.AuditResponse{},
+		"GraphQLRequest":           gql.Request{},
+		"Response":                 weather.Response{},
+		"BuildInfo":                health.BuildInfo{},
+	}
+
+	for name, value := range schemas {
+		schema, ok := s.Components.Schemas[name]
+		if !assert.True(t, ok, "schema %s is missing", name) {
+			continue
+		}
+
+		var documented []string
+		for property := range schema.Properties {
+			documented = append(documented, property)
+		}
+		sort.Strings(documented)
+		assert.Equal(t, jsonFields(reflect.TypeOf(value)), documented, "properties of schema %s", name)
+	}
+}
+
+// jsonFields returns the sorted names fields of t are encoded with
+func jsonFields(t reflect.Type) []string {
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func TestRegisterRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	apidocs.RegisterRoutes(r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, apidocs.SpecPath, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, apidocs.Spec(), w.Body.Bytes())
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, apidocs.DocsPath, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), apidocs.SpecPath)
+
+	for _, version := range webhook.Versions {
+		schema, err := webhook.Schema(version)
+		require.NoError(t, err)
+
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, apidocs.WebhookSchemaPath(version), nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, schema, w.Body.Bytes())
+	}
+}
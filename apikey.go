@@ -0,0 +1,68 @@
+//go:generate mockgen --source apikey.go -destination mocks/apikey.go -package mocks
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Scopes grant access to groups of api routes
+const (
+	ScopeCitiesWrite       = "cities:write"
+	ScopeTemperaturesWrite = "temperatures:write"
+	ScopeForecastsRead     = "forecasts:read"
+	ScopeWebhooksManage    = "webhooks:manage"
+	ScopeAuditRead         = "audit:read"
+)
+
+// Scopes lists every scope that can be granted
+var Scopes = []string{
+	ScopeCitiesWrite,
+	ScopeTemperaturesWrite,
+	ScopeForecastsRead,
+	ScopeWebhooksManage,
+	ScopeAuditRead,
+}
+
+// ValidateScopes returns an error naming the first unknown scope of the space separated scopes
+func ValidateScopes(scopes string) error {
+	for _, scope := range strings.Fields(scopes) {
+		known := false
+		for _, s := range Scopes {
+			known = known || s == scope
+		}
+		if !known {
+			return fmt.Errorf("unknown scope %q, available scopes: %v", scope, Scopes)
+		}
+	}
+	return nil
+}
+
+// APIKey defines a credential granting scopes on a tenant, only a hash of the key is stored
+type APIKey struct {
+	ID        int64      `json:"id,omitempty" gorm:"AUTO_INCREMENT;PRIMARY_KEY"`
+	TenantID  int64      `json:"tenant_id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Hash      string     `json:"-"`
+	Scopes    string     `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ScopeList returns the space separated scopes of the key
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+type APIKeyService interface {
+	// FindAPIKeyByHash returns the key matching hash unless it has been revoked
+	FindAPIKeyByHash(ctx context.Context, hash string) (*APIKey, error)
+	// ListAPIKeys returns the keys of a tenant, of every tenant when tenantID is zero
+	ListAPIKeys(ctx context.Context, tenantID int64) ([]*APIKey, error)
+	CreateAPIKey(ctx context.Context, key *APIKey) error
+	RevokeAPIKey(ctx context.Context, id int64) error
+}
@@ -0,0 +1,105 @@
+//go:generate mockgen --source audit.go -destination mocks/audit.go -package mocks
+
+package core
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Audited actions
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// Audited entities
+const (
+	AuditEntityCity    = "city"
+	AuditEntityWebhook = "webhook"
+)
+
+// AuditEntry records who changed an entity, and how
+type AuditEntry struct {
+	ID        int64     `json:"id" gorm:"AUTO_INCREMENT;PRIMARY_KEY"`
+	TenantID  int64     `json:"-"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Entity    string    `json:"entity"`
+	EntityID  int64     `json:"entity_id"`
+	Before    JSON      `json:"before"`
+	After     JSON      `json:"after"`
+	RequestID string    `json:"request_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditFilter selects audit entries, zero fields match every entry
+type AuditFilter struct {
+	Entity   string
+	EntityID int64
+	Actor    string
+	Action   string
+	// Cursor only selects entries older than the entry with this id
+	Cursor int64
+	Limit  int
+}
+
+// AuditService records the mutations of the tenant set in the context with WithTenant
+type AuditService interface {
+	CreateAuditEntry(ctx context.Context, entry *AuditEntry) error
+	// ListAuditEntries returns entries matching filter, most recent first
+	ListAuditEntries(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error)
+}
+
+// JSON is a raw JSON document stored in a json column, null when empty
+type JSON []byte
+
+// MarshalJSONValue encodes v, nil values are encoded as null
+func MarshalJSONValue(v interface{}) (JSON, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+func (j *JSON) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*j = nil
+		return nil
+	}
+	*j = append((*j)[:0], data...)
+	return nil
+}
+
+// Value stores the document as text so that the driver does not send it as binary
+func (j JSON) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+func (j *JSON) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append((*j)[:0], v...)
+	case string:
+		*j = JSON(v)
+	default:
+		return errors.New("json: unsupported source type")
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	core "github.com/walez/weather-monster"
+
+	"github.com/pkg/errors"
+)
+
+// apiKeyPrefix marks bearer tokens that are api keys
+const apiKeyPrefix = "wm_"
+
+// GenerateAPIKey returns a new random key, its public prefix used to identify it and the hash to store
+func GenerateAPIKey() (key string, prefix string, hash string, err error) {
+	id := make([]byte, 4)
+	secret := make([]byte, 24)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", err
+	}
+
+	prefix = apiKeyPrefix + hex.EncodeToString(id)
+	key = prefix + "_" + hex.EncodeToString(secret)
+	return key, prefix, HashAPIKey(key), nil
+}
+
+// HashAPIKey returns the hex encoded sha256 of key, keys are random so no salt is required
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeys authenticates requests sending an api key as `Authorization: Bearer <key>`
+func APIKeys(ks core.APIKeyService) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+		key, ok := bearerToken(r)
+		if !ok || !strings.HasPrefix(key, apiKeyPrefix) {
+			return nil, ErrNoCredentials
+		}
+
+		apiKey, err := ks.FindAPIKeyByHash(r.Context(), HashAPIKey(key))
+		if err != nil {
+			return nil, errors.Wrap(err, "auth: unknown or revoked api key")
+		}
+
+		return &Principal{
+			Subject:  "api_key:" + strconv.FormatInt(apiKey.ID, 10),
+			TenantID: apiKey.TenantID,
+			Scopes:   apiKey.ScopeList(),
+		}, nil
+	})
+}
+
+// bearerToken returns the token of an `Authorization: Bearer <token>` header
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return "", false
+	}
+
+	token := strings.TrimSpace(parts[1])
+	return token, token != ""
+}
@@ -0,0 +1,110 @@
+package auth_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/auth"
+	mocks "github.com/walez/weather-monster/mocks"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(key, prefix+"_"))
+	assert.Equal(t, auth.HashAPIKey(key), hash)
+	assert.NotContains(t, hash, key, "keys are never stored in clear")
+
+	other, _, _, err := auth.GenerateAPIKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+}
+
+func TestAPIKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	key, _, hash, err := auth.GenerateAPIKey()
+	require.NoError(t, err)
+
+	ks := mocks.NewMockAPIKeyService(mockCtrl)
+	ks.EXPECT().FindAPIKeyByHash(gomock.Any(), hash).Return(&core.APIKey{
+		ID:       3,
+		TenantID: 2,
+		Scopes:   core.ScopeCitiesWrite + " " + core.ScopeForecastsRead,
+	}, nil).AnyTimes()
+	ks.EXPECT().FindAPIKeyByHash(gomock.Any(), gomock.Any()).Return(nil, errors.New("record not found")).AnyTimes()
+
+	type test struct {
+		summary       string
+		authorization string
+		scope         string
+		status        int
+	}
+
+	tests := []test{
+		{
+			summary:       "should accept key granted the route scope",
+			authorization: "Bearer " + key,
+			scope:         core.ScopeCitiesWrite,
+			status:        http.StatusOK,
+		},
+		{
+			summary:       "should forbid key not granted the route scope",
+			authorization: "Bearer " + key,
+			scope:         core.ScopeWebhooksManage,
+			status:        http.StatusForbidden,
+		},
+		{
+			summary:       "should reject unknown or revoked key",
+			authorization: "Bearer wm_00000000_unknown",
+			scope:         core.ScopeCitiesWrite,
+			status:        http.StatusUnauthorized,
+		},
+		{
+			summary: "should reject request without key",
+			scope:   core.ScopeCitiesWrite,
+			status:  http.StatusUnauthorized,
+		},
+		{
+			summary:       "should reject other authorization schemes",
+			authorization: "Basic " + key,
+			scope:         core.ScopeCitiesWrite,
+			status:        http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.summary, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", auth.Middleware(auth.APIKeys(ks)), auth.RequireScope(tc.scope), func(c *gin.Context) {
+				p, _ := auth.PrincipalFromContext(c.Request.Context())
+				tenantID, _ := core.TenantFromContext(c.Request.Context())
+				assert.Equal(t, "api_key:3", p.Subject)
+				assert.Equal(t, int64(2), tenantID)
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+		})
+	}
+}
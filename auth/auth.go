@@ -0,0 +1,57 @@
+// Package auth resolves who is calling the api and which tenant their request is scoped to
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	core "github.com/walez/weather-monster"
+)
+
+// ErrNoCredentials is returned by an Authenticator when the request carries none of
+// the credentials it understands so the next authenticator can be tried
+var ErrNoCredentials = errors.New("no credentials")
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Subject  string
+	TenantID int64
+	Scopes   []string
+}
+
+// HasScope reports whether the principal was granted scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator resolves the principal from the request credentials
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// AuthenticatorFunc adapts a function to an Authenticator
+type AuthenticatorFunc func(r *http.Request) (*Principal, error)
+
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (*Principal, error) {
+	return f(r)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the principal and scoped to its tenant
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	ctx = context.WithValue(ctx, principalKey{}, p)
+	return core.WithTenant(ctx, p.TenantID)
+}
+
+// PrincipalFromContext returns the principal set by WithPrincipal
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
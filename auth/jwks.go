@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// minJWKSRefresh bounds how often unknown key ids trigger a reload of the key set
+const minJWKSRefresh = time.Minute
+
+// jwksLoadTimeout bounds a load of the key set, which outlives the requests waiting for it
+const jwksLoadTimeout = 10 * time.Second
+
+// JWKS is a JSON Web Key Set reloaded when a token is signed with an unknown key,
+// so keys rotated by the identity provider are picked up without restart.
+// Concurrent reloads share a single load, which does not hold up the lookups of known keys.
+// The load is not cancelled with the request that started it, each request only stops waiting for it.
+type JWKS struct {
+	load      func(ctx context.Context) ([]byte, error)
+	reloading singleflight.Group
+
+	mu         sync.Mutex
+	keys       *jose.JSONWebKeySet
+	reloadedAt time.Time
+}
+
+// NewJWKS reads the key set from source, an http(s) url or a file path
+func NewJWKS(source string) *JWKS {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		return RemoteJWKS(source, &http.Client{Timeout: 10 * time.Second})
+	}
+	return FileJWKS(source)
+}
+
+// FileJWKS reads the key set from a file
+func FileJWKS(path string) *JWKS {
+	return &JWKS{load: func(ctx context.Context) ([]byte, error) {
+		return ioutil.ReadFile(path)
+	}}
+}
+
+// RemoteJWKS fetches the key set from url, e.g the jwks_uri of an OIDC provider
+func RemoteJWKS(url string, client *http.Client) *JWKS {
+	return &JWKS{load: func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			return nil, errors.Errorf("unexpected status %d", res.StatusCode)
+		}
+		return ioutil.ReadAll(res.Body)
+	}}
+}
+
+// Key returns the public key identified by kid
+func (j *JWKS) Key(ctx context.Context, kid string) (*jose.JSONWebKey, error) {
+	key, ok, reloadedAt := j.lookup(kid)
+	if ok {
+		return key, nil
+	}
+
+	if !reloadedAt.IsZero() && time.Since(reloadedAt) < minJWKSRefresh {
+		return nil, errors.Errorf("auth: unknown signing key %q", kid)
+	}
+
+	reloaded := j.reloading.DoChan("", func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), jwksLoadTimeout)
+		defer cancel()
+		return nil, j.reloadKeys(ctx)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err(), "auth: waiting for jwks")
+	case res := <-reloaded:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+	}
+
+	if key, ok, _ := j.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, errors.Errorf("auth: unknown signing key %q", kid)
+}
+
+// lookup returns the key identified by kid in the current key set and when it was reloaded
+func (j *JWKS) lookup(kid string) (*jose.JSONWebKey, bool, time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.keys == nil {
+		return nil, false, j.reloadedAt
+	}
+
+	for _, key := range j.keys.Key(kid) {
+		if key.Use == "" || key.Use == "sig" {
+			public := key.Public()
+			return &public, true, j.reloadedAt
+		}
+	}
+	return nil, false, j.reloadedAt
+}
+
+// reloadKeys loads the key set without holding the lock and swaps it under the lock. The time of the
+// load is recorded once it is done so that lookups made meanwhile wait for it rather than being throttled,
+// failed loads are throttled like successful ones.
+func (j *JWKS) reloadKeys(ctx context.Context) error {
+	j.mu.Lock()
+	recent := !j.reloadedAt.IsZero() && time.Since(j.reloadedAt) < minJWKSRefresh
+	j.mu.Unlock()
+	if recent {
+		// another load completed since the caller looked the key up
+		return nil
+	}
+
+	content, err := j.load(ctx)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.reloadedAt = time.Now()
+	if err != nil {
+		return errors.Wrap(err, "auth: unable to load jwks")
+	}
+
+	keys := &jose.JSONWebKeySet{}
+	err = json.Unmarshal(content, keys)
+	if err != nil {
+		return errors.Wrap(err, "auth: invalid jwks")
+	}
+
+	j.keys = keys
+	return nil
+}
@@ -0,0 +1,117 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/walez/weather-monster/auth"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+func TestJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &key.PublicKey, KeyID: "key-1", Algorithm: "RS256", Use: "sig"},
+	}})
+	require.NoError(t, err)
+
+	var fetches int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		w.Write(jwks)
+	}))
+	defer server.Close()
+
+	keys := auth.RemoteJWKS(server.URL, server.Client())
+
+	t.Run("should share a single load between concurrent lookups", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				found, err := keys.Key(context.Background(), "key-1")
+				if assert.NoError(t, err) {
+					assert.Equal(t, "key-1", found.KeyID)
+				}
+			}()
+		}
+
+		for atomic.LoadInt32(&fetches) == 0 {
+			runtime.Gosched()
+		}
+		close(release)
+		wg.Wait()
+		assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+	})
+
+	t.Run("should not reload for unknown keys right after a load", func(t *testing.T) {
+		_, err := keys.Key(context.Background(), "key-2")
+		assert.EqualError(t, err, `auth: unknown signing key "key-2"`)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+	})
+}
+
+func TestJWKS_Cancel(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &key.PublicKey, KeyID: "key-1", Algorithm: "RS256", Use: "sig"},
+	}})
+	require.NoError(t, err)
+
+	var fetches int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		w.Write(jwks)
+	}))
+	defer server.Close()
+
+	keys := auth.RemoteJWKS(server.URL, server.Client())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error)
+	go func() {
+		_, err := keys.Key(ctx, "key-1")
+		cancelled <- err
+	}()
+	for atomic.LoadInt32(&fetches) == 0 {
+		runtime.Gosched()
+	}
+
+	waiting := make(chan error)
+	go func() {
+		_, err := keys.Key(context.Background(), "key-1")
+		waiting <- err
+	}()
+
+	cancel()
+	select {
+	case err := <-cancelled:
+		assert.True(t, errors.Is(err, context.Canceled), "unexpected error %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("the cancelled lookup is still waiting for the load")
+	}
+
+	close(release)
+	assert.NoError(t, <-waiting, "the load is not cancelled with the lookup that started it")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	core "github.com/walez/weather-monster"
+
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// JWTConfig describes the tokens issued by the identity provider
+type JWTConfig struct {
+	// Issuer and Audience must match the iss and aud claims when set
+	Issuer   string
+	Audience string
+	// TenantClaim names the claim holding the tenant name, "tenant" by default
+	TenantClaim string
+	// ScopeClaim names the claim holding the granted scopes either as a space separated
+	// string or a list, "scope" by default
+	ScopeClaim string
+	// Leeway tolerates clock skew when validating exp and nbf
+	Leeway time.Duration
+}
+
+// JWT authenticates requests sending a token signed by a key of keys as `Authorization: Bearer <token>`,
+// the tenant and scopes of the request are read from the token claims
+func JWT(keys *JWKS, config JWTConfig, ts core.TenantService) Authenticator {
+	if config.TenantClaim == "" {
+		config.TenantClaim = "tenant"
+	}
+	if config.ScopeClaim == "" {
+		config.ScopeClaim = "scope"
+	}
+
+	return AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+		token, ok := bearerToken(r)
+		if !ok || strings.HasPrefix(token, apiKeyPrefix) {
+			return nil, ErrNoCredentials
+		}
+
+		parsed, err := jwt.ParseSigned(token)
+		if err != nil {
+			return nil, errors.Wrap(err, "auth: malformed token")
+		}
+		if len(parsed.Headers) != 1 {
+			return nil, errors.New("auth: token must have a single signature")
+		}
+
+		key, err := keys.Key(r.Context(), parsed.Headers[0].KeyID)
+		if err != nil {
+			return nil, err
+		}
+
+		claims := jwt.Claims{}
+		custom := map[string]interface{}{}
+		err = parsed.Claims(key, &claims, &custom)
+		if err != nil {
+			return nil, errors.Wrap(err, "auth: invalid token signature")
+		}
+
+		if claims.Expiry == nil {
+			return nil, errors.New("auth: token without expiry")
+		}
+
+		expected := jwt.Expected{Issuer: config.Issuer, Time: time.Now()}
+		if config.Audience != "" {
+			expected.Audience = jwt.Audience{config.Audience}
+		}
+		err = claims.ValidateWithLeeway(expected, config.Leeway)
+		if err != nil {
+			return nil, errors.Wrap(err, "auth: invalid token claims")
+		}
+
+		name, _ := custom[config.TenantClaim].(string)
+		if name == "" {
+			return nil, errors.Errorf("auth: token without %q claim", config.TenantClaim)
+		}
+
+		tenant, err := ts.FindTenantByName(r.Context(), name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "auth: unknown tenant %q", name)
+		}
+
+		return &Principal{
+			Subject:  "jwt:" + claims.Subject,
+			TenantID: tenant.ID,
+			Scopes:   scopesClaim(custom[config.ScopeClaim]),
+		}, nil
+	})
+}
+
+// scopesClaim reads scopes from a space separated string, as in OAuth2, or a list of strings
+func scopesClaim(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		scopes := make([]string, 0, len(v))
+		for _, s := range v {
+			if scope, ok := s.(string); ok {
+				scopes = append(scopes, scope)
+			}
+		}
+		return scopes
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,185 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/auth"
+	mocks "github.com/walez/weather-monster/mocks"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+const testIssuer = "https://id.example.com"
+
+type tokenClaims struct {
+	jwt.Claims
+	Tenant string      `json:"tenant,omitempty"`
+	Scope  interface{} `json:"scope,omitempty"`
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims tokenClaims) string {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", kid),
+	)
+	require.NoError(t, err)
+
+	token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+func validClaims(tenant string, scope interface{}) tokenClaims {
+	return tokenClaims{
+		Claims: jwt.Claims{
+			Subject:  "user-1",
+			Issuer:   testIssuer,
+			Audience: jwt.Audience{"weather-monster"},
+			Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Tenant: tenant,
+		Scope:  scope,
+	}
+}
+
+func TestJWT(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ts := mocks.NewMockTenantService(mockCtrl)
+	ts.EXPECT().FindTenantByName(gomock.Any(), "team-a").Return(&core.Tenant{ID: 2, Name: "team-a"}, nil).AnyTimes()
+	ts.EXPECT().FindTenantByName(gomock.Any(), gomock.Any()).Return(nil, errors.New("record not found")).AnyTimes()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: key.Public(), KeyID: "key-1", Algorithm: string(jose.RS256), Use: "sig"},
+	}})
+	require.NoError(t, err)
+
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jwks)
+	}))
+	defer jwksServer.Close()
+
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, ioutil.WriteFile(jwksFile, jwks, 0600))
+
+	expired := validClaims("team-a", core.ScopeCitiesWrite)
+	expired.Expiry = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+
+	otherIssuer := validClaims("team-a", core.ScopeCitiesWrite)
+	otherIssuer.Issuer = "https://evil.example.com"
+
+	noExpiry := validClaims("team-a", core.ScopeCitiesWrite)
+	noExpiry.Expiry = nil
+
+	type test struct {
+		summary string
+		token   string
+		status  int
+	}
+
+	tests := []test{
+		{
+			summary: "should accept token granted the route scope",
+			token:   signToken(t, key, "key-1", validClaims("team-a", core.ScopeForecastsRead+" "+core.ScopeCitiesWrite)),
+			status:  http.StatusOK,
+		},
+		{
+			summary: "should accept scopes as a list",
+			token:   signToken(t, key, "key-1", validClaims("team-a", []string{core.ScopeCitiesWrite})),
+			status:  http.StatusOK,
+		},
+		{
+			summary: "should forbid token not granted the route scope",
+			token:   signToken(t, key, "key-1", validClaims("team-a", core.ScopeForecastsRead)),
+			status:  http.StatusForbidden,
+		},
+		{
+			summary: "should reject token signed by another key",
+			token:   signToken(t, otherKey, "key-1", validClaims("team-a", core.ScopeCitiesWrite)),
+			status:  http.StatusUnauthorized,
+		},
+		{
+			summary: "should reject token signed by an unknown key",
+			token:   signToken(t, otherKey, "key-2", validClaims("team-a", core.ScopeCitiesWrite)),
+			status:  http.StatusUnauthorized,
+		},
+		{
+			summary: "should reject expired token",
+			token:   signToken(t, key, "key-1", expired),
+			status:  http.StatusUnauthorized,
+		},
+		{
+			summary: "should reject token without expiry",
+			token:   signToken(t, key, "key-1", noExpiry),
+			status:  http.StatusUnauthorized,
+		},
+		{
+			summary: "should reject token from another issuer",
+			token:   signToken(t, key, "key-1", otherIssuer),
+			status:  http.StatusUnauthorized,
+		},
+		{
+			summary: "should reject token of an unknown tenant",
+			token:   signToken(t, key, "key-1", validClaims("team-b", core.ScopeCitiesWrite)),
+			status:  http.StatusUnauthorized,
+		},
+		{
+			summary: "should reject malformed token",
+			token:   "not.a.token",
+			status:  http.StatusUnauthorized,
+		},
+	}
+
+	sources := map[string]*auth.JWKS{
+		"url":  auth.NewJWKS(jwksServer.URL),
+		"file": auth.NewJWKS(jwksFile),
+	}
+
+	for source, keys := range sources {
+		authenticator := auth.JWT(keys, auth.JWTConfig{Issuer: testIssuer, Audience: "weather-monster"}, ts)
+
+		for _, tc := range tests {
+			t.Run(source+" "+tc.summary, func(t *testing.T) {
+				r := gin.New()
+				r.GET("/", auth.Middleware(authenticator), auth.RequireScope(core.ScopeCitiesWrite), func(c *gin.Context) {
+					p, _ := auth.PrincipalFromContext(c.Request.Context())
+					tenantID, _ := core.TenantFromContext(c.Request.Context())
+					assert.Equal(t, "jwt:user-1", p.Subject)
+					assert.Equal(t, int64(2), tenantID)
+					c.Status(http.StatusOK)
+				})
+
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+
+				assert.Equal(t, tc.status, w.Code)
+			})
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/walez/weather-monster/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware authenticates requests with the first authenticator finding credentials,
+// requests no authenticator accepts are rejected with 401
+func Middleware(authenticators ...Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, a := range authenticators {
+			p, err := a.Authenticate(c.Request)
+			if err == ErrNoCredentials {
+				continue
+			}
+
+			if err != nil {
+				logging.FromContext(c.Request.Context()).WithError(err).Warning("auth: rejected credentials")
+				break
+			}
+
+			c.Request = c.Request.WithContext(WithPrincipal(c.Request.Context(), p))
+			c.Next()
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
+	}
+}
+
+// RequireScope rejects requests whose principal was not granted scope with 403,
+// it must run after Middleware
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := PrincipalFromContext(c.Request.Context())
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
+			return
+		}
+
+		if !p.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
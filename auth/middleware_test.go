@@ -0,0 +1,85 @@
+package auth_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/auth"
+	mocks "github.com/walez/weather-monster/mocks"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ts := mocks.NewMockTenantService(mockCtrl)
+	ts.EXPECT().FindTenantByName(gomock.Any(), "team-a").Return(&core.Tenant{ID: 2, Name: "team-a"}, nil).AnyTimes()
+	ts.EXPECT().FindTenantByName(gomock.Any(), gomock.Any()).Return(nil, errors.New("record not found")).AnyTimes()
+
+	type test struct {
+		summary        string
+		authenticators []auth.Authenticator
+		tenant         string
+		status         int
+		tenantID       string
+	}
+
+	tests := []test{
+		{
+			summary:        "should scope request to tenant header",
+			authenticators: []auth.Authenticator{auth.TrustedTenantHeader(ts), auth.DefaultTenant(&core.Tenant{ID: 1})},
+			tenant:         "team-a",
+			status:         http.StatusOK,
+			tenantID:       "2",
+		},
+		{
+			summary:        "should fall back to default tenant without credentials",
+			authenticators: []auth.Authenticator{auth.TrustedTenantHeader(ts), auth.DefaultTenant(&core.Tenant{ID: 1})},
+			status:         http.StatusOK,
+			tenantID:       "1",
+		},
+		{
+			summary:        "should reject unknown tenant instead of falling back",
+			authenticators: []auth.Authenticator{auth.TrustedTenantHeader(ts), auth.DefaultTenant(&core.Tenant{ID: 1})},
+			tenant:         "team-b",
+			status:         http.StatusUnauthorized,
+		},
+		{
+			summary:        "should reject request without credentials",
+			authenticators: []auth.Authenticator{auth.TrustedTenantHeader(ts)},
+			status:         http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.summary, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", auth.Middleware(tc.authenticators...), func(c *gin.Context) {
+				tenantID, _ := core.TenantFromContext(c.Request.Context())
+				c.String(http.StatusOK, strconv.FormatInt(tenantID, 10))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.tenant != "" {
+				req.Header.Set(auth.TenantHeader, tc.tenant)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			if tc.tenantID != "" {
+				assert.Equal(t, tc.tenantID, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	core "github.com/walez/weather-monster"
+
+	"github.com/pkg/errors"
+)
+
+// Request headers set by a trusted gateway
+const (
+	TenantHeader = "X-Tenant"
+	ScopesHeader = "X-Scopes"
+)
+
+// TrustedTenantHeader scopes requests to the tenant named in the X-Tenant header granting
+// the space separated scopes of the X-Scopes header.
+// It must only be enabled behind a gateway that authenticates callers and sets the headers.
+func TrustedTenantHeader(ts core.TenantService) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+		name := r.Header.Get(TenantHeader)
+		if name == "" {
+			return nil, ErrNoCredentials
+		}
+
+		tenant, err := ts.FindTenantByName(r.Context(), name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "auth: unknown tenant %q", name)
+		}
+
+		return &Principal{
+			Subject:  "tenant:" + tenant.Name,
+			TenantID: tenant.ID,
+			Scopes:   strings.Fields(r.Header.Get(ScopesHeader)),
+		}, nil
+	})
+}
+
+// Anonymous is the subject of requests authenticated by DefaultTenant
+const Anonymous = "anonymous"
+
+// DefaultTenant scopes requests carrying no credentials to a single tenant granting them scopes,
+// keeping single tenant deployments working without credentials
+func DefaultTenant(tenant *core.Tenant, scopes ...string) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+		return &Principal{
+			Subject:  Anonymous,
+			TenantID: tenant.ID,
+			Scopes:   scopes,
+		}, nil
+	})
+}
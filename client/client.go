@@ -0,0 +1,278 @@
+// Package client calls the v1 weather API from Go programs, e.g the wmctl command.
+// Its only dependency on the server is the core package so that other services can import it.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	core "github.com/walez/weather-monster"
+)
+
+// DefaultBaseURL is where a locally started api listens
+const DefaultBaseURL = "http://localhost:8080"
+
+// DefaultTimeout bounds each attempt of a request
+const DefaultTimeout = 30 * time.Second
+
+// v1Path is where the routes of the v1 api are mounted
+const v1Path = "/v1"
+
+// Client calls the weather API on behalf of a tenant, it is safe for concurrent use
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+	timeout    time.Duration
+	retry      RetryPolicy
+}
+
+// Option configures optional behaviour of the client
+type Option func(*Client)
+
+// WithToken authenticates requests with an api key or an OIDC access token
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithHTTPClient sets the client sending requests, http.DefaultClient by default.
+// Its timeout also applies to streams, prefer WithTimeout.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTimeout bounds each attempt of a request, DefaultTimeout by default, 0 disables it.
+// Streams are only bounded by their context.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetry sets how failed requests are retried, DefaultRetryPolicy by default
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// New returns a client of the api served at baseURL, e.g https://weather.example.com
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("client: invalid base url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("client: invalid base url %q, expected an http or https url", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    strings.TrimSuffix(u.String(), "/"),
+		httpClient: http.DefaultClient,
+		timeout:    DefaultTimeout,
+		retry:      DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// CityParams are the fields of a city, fields left nil are not changed by UpdateCity
+type CityParams struct {
+	Name      *string  `json:"name,omitempty"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+}
+
+// CityPage is a page of cities, NextCursor fetches the next page when not 0
+type CityPage struct {
+	Cities     []*core.City `json:"cities"`
+	NextCursor int64        `json:"next_cursor,omitempty"`
+}
+
+// ListCities returns up to limit cities ordered by id starting after the city of id cursor,
+// the api picks the page size when limit is 0
+func (c *Client) ListCities(ctx context.Context, cursor int64, limit int) (*CityPage, error) {
+	query := url.Values{}
+	if cursor != 0 {
+		query.Set("cursor", strconv.FormatInt(cursor, 10))
+	}
+	if limit != 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	page := &CityPage{}
+	err := c.do(ctx, http.MethodGet, "/cities", query, nil, page)
+	return page, err
+}
+
+func (c *Client) GetCity(ctx context.Context, id int64) (*core.City, error) {
+	city := &core.City{}
+	err := c.do(ctx, http.MethodGet, "/cities/"+strconv.FormatInt(id, 10), nil, nil, city)
+	return city, err
+}
+
+// CreateCity creates a city, or returns the city of the same name when it exists already
+func (c *Client) CreateCity(ctx context.Context, params CityParams) (*core.City, error) {
+	city := &core.City{}
+	err := c.do(ctx, http.MethodPost, "/cities", nil, params, city)
+	return city, err
+}
+
+func (c *Client) UpdateCity(ctx context.Context, id int64, params CityParams) (*core.City, error) {
+	city := &core.City{}
+	err := c.do(ctx, http.MethodPatch, "/cities/"+strconv.FormatInt(id, 10), nil, params, city)
+	return city, err
+}
+
+func (c *Client) DeleteCity(ctx context.Context, id int64) (*core.City, error) {
+	city := &core.City{}
+	err := c.do(ctx, http.MethodDelete, "/cities/"+strconv.FormatInt(id, 10), nil, nil, city)
+	return city, err
+}
+
+func (c *Client) GetCityForecast(ctx context.Context, cityID int64) (*core.Forecast, error) {
+	forecast := &core.Forecast{}
+	err := c.do(ctx, http.MethodGet, "/forecasts/"+strconv.FormatInt(cityID, 10), nil, nil, forecast)
+	return forecast, err
+}
+
+func (c *Client) CreateTemperature(ctx context.Context, cityID int64, max int, min int) (*core.Temperature, error) {
+	temperature := &core.Temperature{}
+	err := c.do(ctx, http.MethodPost, "/temperatures", nil, map[string]interface{}{
+		"city_id": strconv.FormatInt(cityID, 10),
+		"max":     max,
+		"min":     min,
+	}, temperature)
+	return temperature, err
+}
+
+func (c *Client) ListWebhooks(ctx context.Context, cityID int64) ([]*core.Webhook, error) {
+	var webhooks []*core.Webhook
+	err := c.do(ctx, http.MethodGet, "/webhooks", url.Values{"city_id": {strconv.FormatInt(cityID, 10)}}, nil, &webhooks)
+	return webhooks, err
+}
+
+// CreateWebhook subscribes callbackURL to the temperatures created for the city, posting payloads of
+// payloadVersion, the latest when 0. The secret signing the deliveries is only answered now, keep it
+// for the webhook.Handler receiving them.
+func (c *Client) CreateWebhook(ctx context.Context, cityID int64, callbackURL string, payloadVersion int) (*core.Webhook, error) {
+	created := struct {
+		*core.Webhook
+		Secret string `json:"secret"`
+	}{Webhook: &core.Webhook{}}
+	err := c.do(ctx, http.MethodPost, "/webhooks", nil, map[string]interface{}{
+		"city_id":         strconv.FormatInt(cityID, 10),
+		"callback_url":    callbackURL,
+		"payload_version": payloadVersion,
+	}, &created)
+	created.Webhook.Secret = created.Secret
+	return created.Webhook, err
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, id int64) (*core.Webhook, error) {
+	webhook := &core.Webhook{}
+	err := c.do(ctx, http.MethodDelete, "/webhooks/"+strconv.FormatInt(id, 10), nil, nil, webhook)
+	return webhook, err
+}
+
+// newRequest builds a request to the v1 route at path sending payload, a JSON body when not nil
+func (c *Client) newRequest(ctx context.Context, method string, path string, query url.Values, payload []byte) (*http.Request, error) {
+	target := c.baseURL + v1Path + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return req, nil
+}
+
+// do sends the request, retrying it as the retry policy allows, and decodes the response in out
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body interface{}, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	backoff := c.retry.MinBackoff
+	for attempt := 1; ; attempt++ {
+		err := c.attempt(ctx, method, path, query, payload, out)
+		if err == nil || ctx.Err() != nil || attempt >= c.retry.MaxAttempts || !retryable(method, err) {
+			return err
+		}
+
+		wait := backoff
+		if apiErr, ok := err.(*Error); ok && apiErr.RetryAfter > 0 {
+			wait = apiErr.RetryAfter
+		}
+		if wait > c.retry.MaxBackoff {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		backoff *= 2
+		if backoff > c.retry.MaxBackoff {
+			backoff = c.retry.MaxBackoff
+		}
+	}
+}
+
+// attempt sends the request once, bounded by the timeout of the client
+func (c *Client) attempt(ctx context.Context, method string, path string, query url.Values, payload []byte, out interface{}) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	req, err := c.newRequest(ctx, method, path, query, payload)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return responseError(req, res)
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
@@ -0,0 +1,326 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/auth"
+	"github.com/walez/weather-monster/client"
+	"github.com/walez/weather-monster/events"
+	mocks "github.com/walez/weather-monster/mocks"
+	"github.com/walez/weather-monster/weather"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testToken is the api key granting every scope of the tenant 1 on the test server
+const testToken = "wm_test"
+
+func bearer(r *http.Request) (*auth.Principal, error) {
+	if r.Header.Get("Authorization") != "Bearer "+testToken {
+		return nil, auth.ErrNoCredentials
+	}
+	return &auth.Principal{Subject: "test", TenantID: 1, Scopes: core.Scopes}, nil
+}
+
+// serve serves the v1 routes backed by ws and returns a client of them
+func serve(t *testing.T, ws core.WeatherService, opts ...weather.HandlerOption) *client.Client {
+	return newClient(t, router(t, ws, nil, opts...))
+}
+
+// router returns the v1 routes backed by ws and audit, audit entries are discarded when audit is nil
+func router(t *testing.T, ws core.WeatherService, audit core.AuditService, opts ...weather.HandlerOption) http.Handler {
+	gin.SetMode(gin.TestMode)
+	if audit == nil {
+		mockCtrl := gomock.NewController(t)
+		t.Cleanup(mockCtrl.Finish)
+
+		discard := mocks.NewMockAuditService(mockCtrl)
+		discard.EXPECT().CreateAuditEntry(gomock.Any(), gomock.Any()).AnyTimes()
+		audit = discard
+	}
+	h := weather.NewHandler(ws, events.NewManager(context.Background()), audit, opts...)
+
+	r := gin.New()
+	h.RegisterRoutes(r.Group(weather.V1Path, auth.Middleware(auth.AuthenticatorFunc(bearer))))
+	return r
+}
+
+// newClient serves handler and returns a client of it, retrying quickly
+func newClient(t *testing.T, handler http.Handler, opts ...client.Option) *client.Client {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	opts = append([]client.Option{
+		client.WithToken(testToken),
+		client.WithRetry(client.RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 2 * time.Second}),
+	}, opts...)
+	c, err := client.New(srv.URL, opts...)
+	require.NoError(t, err)
+	return c
+}
+
+// failing answers the first failures requests with status, and the others with next
+type failing struct {
+	next       http.Handler
+	status     int
+	retryAfter string
+	failures   int32
+	requests   int32
+}
+
+func (f *failing) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if atomic.AddInt32(&f.requests, 1) <= f.failures {
+		if f.retryAfter != "" {
+			w.Header().Set("Retry-After", f.retryAfter)
+		}
+		w.Header().Set("X-Request-ID", "req-1")
+		w.WriteHeader(f.status)
+		w.Write([]byte(`{"message":"request failure"}`))
+		return
+	}
+	f.next.ServeHTTP(w, r)
+}
+
+func TestClient(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ctx := context.Background()
+	ws := mocks.NewMockWeatherService(mockCtrl)
+	c := serve(t, ws)
+
+	t.Run("should list cities", func(t *testing.T) {
+		ws.EXPECT().ListCities(gomock.Any(), int64(4), 2).Return([]*core.City{{ID: 5}, {ID: 6}}, nil)
+
+		page, err := c.ListCities(ctx, 4, 2)
+		require.NoError(t, err)
+		assert.Len(t, page.Cities, 2)
+		assert.Equal(t, int64(6), page.NextCursor)
+	})
+
+	t.Run("should update the fields given", func(t *testing.T) {
+		ws.EXPECT().FindCityByID(gomock.Any(), int64(1)).Return(&core.City{ID: 1, Name: "City One", Latitude: 1}, nil)
+		ws.EXPECT().UpdateCity(gomock.Any(), &core.City{ID: 1, Name: "City One", Latitude: 2}).Return(nil)
+
+		latitude := 2.0
+		city, err := c.UpdateCity(ctx, 1, client.CityParams{Latitude: &latitude})
+		require.NoError(t, err)
+		assert.Equal(t, "City One", city.Name)
+		assert.Equal(t, 2.0, city.Latitude)
+	})
+
+	t.Run("should create temperatures", func(t *testing.T) {
+		ws.EXPECT().GetCityWebhooks(gomock.Any(), int64(1)).AnyTimes()
+		ws.EXPECT().CreateTemperature(gomock.Any(), &core.Temperature{CityID: 1, Max: 20, Min: 10}).DoAndReturn(func(ctx context.Context, temperature *core.Temperature) error {
+			temperature.ID = 3
+			return nil
+		})
+
+		temperature, err := c.CreateTemperature(ctx, 1, 20, 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), temperature.ID)
+	})
+
+	t.Run("should answer the secret of created webhooks", func(t *testing.T) {
+		ws.EXPECT().CreateWebhook(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, webhook *core.Webhook) error {
+			webhook.ID = 4
+			return nil
+		})
+
+		webhook, err := c.CreateWebhook(ctx, 1, "https://example.com/callback", 0)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), webhook.ID)
+		assert.Regexp(t, "^whsec_", webhook.Secret)
+		assert.Equal(t, 2, webhook.PayloadVersion, "webhooks pin the latest version by default")
+	})
+
+	t.Run("should return the message of errors", func(t *testing.T) {
+		ws.EXPECT().CreateTemperature(gomock.Any(), gomock.Any()).Return(core.ErrQuotaExceeded)
+
+		_, err := c.CreateTemperature(ctx, 1, 20, 10)
+		assert.EqualError(t, err, "POST /v1/temperatures: 429 Too Many Requests: "+core.ErrQuotaExceeded.Error())
+	})
+}
+
+func TestClient_StreamCity(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ws := mocks.NewMockWeatherService(mockCtrl)
+	ws.EXPECT().FindCitiesByIDs(gomock.Any(), []int64{1}).Return([]*core.City{{ID: 1}}, nil)
+
+	broker := events.NewBroker()
+	require.NoError(t, broker.Publish(ctx, &core.Temperature{ID: 1, TenantID: 1, CityID: 1}))
+	require.NoError(t, broker.Publish(ctx, &core.Temperature{ID: 2, TenantID: 1, CityID: 1}))
+	c := serve(t, ws, weather.WithBroker(broker))
+
+	var received []int64
+	done := errors.New("done")
+	err := c.StreamCity(ctx, 1, 1, func(temperature *core.Temperature) error {
+		received = append(received, temperature.ID)
+		if temperature.ID == 2 {
+			// replayed, the stream is subscribed
+			return broker.Publish(ctx, &core.Temperature{ID: 3, TenantID: 1, CityID: 1})
+		}
+		return done
+	})
+	assert.Equal(t, done, err)
+	assert.Equal(t, []int64{2, 3}, received, "temperatures after the last event id are sent first")
+}
+
+func TestClient_Retry(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ctx := context.Background()
+	ws := mocks.NewMockWeatherService(mockCtrl)
+	r := router(t, ws, nil)
+
+	t.Run("should retry reads while the api is unavailable", func(t *testing.T) {
+		ws.EXPECT().FindCityByID(gomock.Any(), int64(1)).Return(&core.City{ID: 1, Name: "City One"}, nil)
+		h := &failing{next: r, status: http.StatusServiceUnavailable, failures: 2}
+
+		city, err := newClient(t, h).GetCity(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, "City One", city.Name)
+		assert.Equal(t, int32(3), h.requests)
+	})
+
+	t.Run("should give up after the last attempt", func(t *testing.T) {
+		h := &failing{next: r, status: http.StatusBadGateway, failures: 3}
+
+		_, err := newClient(t, h).GetCity(ctx, 1)
+		assert.True(t, client.IsStatus(err, http.StatusBadGateway))
+		assert.Equal(t, int32(3), h.requests)
+	})
+
+	t.Run("should not retry creations while the api is unavailable", func(t *testing.T) {
+		h := &failing{next: r, status: http.StatusServiceUnavailable, failures: 1}
+
+		_, err := newClient(t, h).CreateTemperature(ctx, 1, 20, 10)
+		assert.True(t, client.IsStatus(err, http.StatusServiceUnavailable))
+		assert.Equal(t, int32(1), h.requests)
+	})
+
+	t.Run("should retry creations the rate limiter rejected", func(t *testing.T) {
+		ws.EXPECT().GetCityWebhooks(gomock.Any(), int64(1)).AnyTimes()
+		ws.EXPECT().CreateTemperature(gomock.Any(), &core.Temperature{CityID: 1, Max: 20, Min: 10}).Return(nil)
+		h := &failing{next: r, status: http.StatusTooManyRequests, retryAfter: "1", failures: 1}
+
+		_, err := newClient(t, h).CreateTemperature(ctx, 1, 20, 10)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), h.requests)
+	})
+
+	t.Run("should not wait longer than the max backoff", func(t *testing.T) {
+		h := &failing{next: r, status: http.StatusTooManyRequests, retryAfter: "60", failures: 1}
+
+		_, err := newClient(t, h).GetCity(ctx, 1)
+		var apiErr *client.Error
+		require.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, time.Minute, apiErr.RetryAfter)
+		assert.Equal(t, "req-1", apiErr.RequestID)
+		assert.Equal(t, int32(1), h.requests)
+	})
+
+	t.Run("should not retry the exceeded quota", func(t *testing.T) {
+		ws.EXPECT().CreateTemperature(gomock.Any(), gomock.Any()).Return(core.ErrQuotaExceeded)
+
+		_, err := newClient(t, r).CreateTemperature(ctx, 1, 20, 10)
+		assert.True(t, errors.Is(err, core.ErrQuotaExceeded))
+		assert.True(t, client.IsStatus(err, http.StatusTooManyRequests))
+	})
+}
+
+func TestClient_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+
+	c := newClient(t, slow, client.WithTimeout(20*time.Millisecond), client.WithRetry(client.RetryPolicy{MaxAttempts: 1}))
+	start := time.Now()
+	_, err := c.GetCity(context.Background(), 1)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestClient_Pagination(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ctx := context.Background()
+	ws := mocks.NewMockWeatherService(mockCtrl)
+	c := serve(t, ws)
+
+	t.Run("should iterate over every page of cities", func(t *testing.T) {
+		gomock.InOrder(
+			ws.EXPECT().ListCities(gomock.Any(), int64(0), 2).Return([]*core.City{{ID: 1}, {ID: 2}}, nil),
+			ws.EXPECT().ListCities(gomock.Any(), int64(2), 2).Return([]*core.City{{ID: 3}, {ID: 4}}, nil),
+			ws.EXPECT().ListCities(gomock.Any(), int64(4), 2).Return([]*core.City{{ID: 5}}, nil),
+		)
+
+		var ids []int64
+		it := c.Cities(2)
+		for it.Next(ctx) {
+			ids = append(ids, it.City().ID)
+		}
+		require.NoError(t, it.Err())
+		assert.Equal(t, []int64{1, 2, 3, 4, 5}, ids)
+	})
+
+	t.Run("should stop at the page that failed", func(t *testing.T) {
+		gomock.InOrder(
+			ws.EXPECT().ListCities(gomock.Any(), int64(0), 1).Return([]*core.City{{ID: 1}}, nil),
+			ws.EXPECT().ListCities(gomock.Any(), int64(1), 1).Return(nil, errors.New("connection refused")),
+		)
+
+		it := c.Cities(1)
+		assert.True(t, it.Next(ctx))
+		assert.False(t, it.Next(ctx))
+		assert.True(t, client.IsStatus(it.Err(), http.StatusInternalServerError))
+		assert.False(t, it.Next(ctx))
+	})
+}
+
+func TestClient_AuditEntries(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ctx := context.Background()
+	ws := mocks.NewMockWeatherService(mockCtrl)
+	audit := mocks.NewMockAuditService(mockCtrl)
+	c := newClient(t, router(t, ws, audit))
+
+	filter := core.AuditFilter{Entity: core.AuditEntityCity, EntityID: 1, Limit: 2}
+	gomock.InOrder(
+		audit.EXPECT().ListAuditEntries(gomock.Any(), filter).Return([]*core.AuditEntry{{ID: 9}, {ID: 7}}, nil),
+		audit.EXPECT().ListAuditEntries(gomock.Any(), core.AuditFilter{Entity: core.AuditEntityCity, EntityID: 1, Cursor: 7, Limit: 2}).
+			Return([]*core.AuditEntry{{ID: 4}}, nil),
+	)
+
+	var ids []int64
+	it := c.AuditEntries(filter)
+	for it.Next(ctx) {
+		ids = append(ids, it.Entry().ID)
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, []int64{9, 7, 4}, ids)
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	core "github.com/walez/weather-monster"
+)
+
+// requestIDHeader identifies a request in the api logs
+const requestIDHeader = "X-Request-ID"
+
+// Error is a request the api answered with an error status, check its status with errors.As,
+// or whether the daily quota is exceeded with errors.Is(err, core.ErrQuotaExceeded)
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// Message is the message answered by the api, e.g "request failure" as it only details some errors
+	Message string
+	// RequestID identifies the request in the api logs and audit log
+	RequestID string
+	// RetryAfter is how long the api asked to wait before sending the request again, 0 when it did not
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	status := strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode)
+	if e.Message == "" {
+		return fmt.Sprintf("%s %s: %s", e.Method, e.Path, status)
+	}
+	return fmt.Sprintf("%s %s: %s: %s", e.Method, e.Path, status, e.Message)
+}
+
+// Is matches core.ErrQuotaExceeded when the api rejected the request because of the quota
+func (e *Error) Is(target error) bool {
+	return target == core.ErrQuotaExceeded && e.StatusCode == http.StatusTooManyRequests && e.Message == target.Error()
+}
+
+// IsStatus reports whether err is an Error of the status code, e.g http.StatusForbidden
+func IsStatus(err error, code int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == code
+}
+
+// responseError describes an error response with the message answered by the api
+func responseError(req *http.Request, res *http.Response) error {
+	body := struct {
+		Message string `json:"message"`
+	}{}
+	json.NewDecoder(res.Body).Decode(&body)
+
+	err := &Error{
+		Method:     req.Method,
+		Path:       req.URL.Path,
+		StatusCode: res.StatusCode,
+		Message:    body.Message,
+		RequestID:  res.Header.Get(requestIDHeader),
+	}
+	if seconds, parseErr := strconv.Atoi(res.Header.Get("Retry-After")); parseErr == nil && seconds > 0 {
+		err.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return err
+}
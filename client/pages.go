@@ -0,0 +1,152 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	core "github.com/walez/weather-monster"
+)
+
+// AuditPage is a page of audit entries, NextCursor fetches the next page when not 0
+type AuditPage struct {
+	Entries    []*core.AuditEntry `json:"entries"`
+	NextCursor int64              `json:"next_cursor,omitempty"`
+}
+
+// ListAuditEntries returns the audit entries matching filter, most recent first
+func (c *Client) ListAuditEntries(ctx context.Context, filter core.AuditFilter) (*AuditPage, error) {
+	query := url.Values{}
+	set := func(name string, value string) {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	set("entity", filter.Entity)
+	set("actor", filter.Actor)
+	set("action", filter.Action)
+	if filter.EntityID != 0 {
+		set("id", strconv.FormatInt(filter.EntityID, 10))
+	}
+	if filter.Cursor != 0 {
+		set("cursor", strconv.FormatInt(filter.Cursor, 10))
+	}
+	if filter.Limit != 0 {
+		set("limit", strconv.Itoa(filter.Limit))
+	}
+
+	page := &AuditPage{}
+	err := c.do(ctx, http.MethodGet, "/audit", query, nil, page)
+	return page, err
+}
+
+// CityIterator walks the cities ordered by id, fetching a page when it runs out of cities:
+//
+//	it := c.Cities(100)
+//	for it.Next(ctx) {
+//		city := it.City()
+//	}
+//	if it.Err() != nil {
+//	}
+type CityIterator struct {
+	c      *Client
+	limit  int
+	cursor int64
+	page   []*core.City
+	city   *core.City
+	last   bool
+	err    error
+}
+
+// Cities returns an iterator over every city fetching pages of limit cities, the api picks the page size when 0
+func (c *Client) Cities(limit int) *CityIterator {
+	return &CityIterator{c: c, limit: limit}
+}
+
+// Next moves to the next city, it returns false once every city was read or a page failed to load
+func (it *CityIterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+
+	if len(it.page) == 0 {
+		if it.last {
+			return false
+		}
+
+		page, err := it.c.ListCities(ctx, it.cursor, it.limit)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page, it.cursor, it.last = page.Cities, page.NextCursor, page.NextCursor == 0
+		if len(it.page) == 0 {
+			return false
+		}
+	}
+
+	it.city, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// City returns the current city
+func (it *CityIterator) City() *core.City {
+	return it.city
+}
+
+// Err returns the error of the page that failed to load
+func (it *CityIterator) Err() error {
+	return it.err
+}
+
+// AuditIterator walks the audit entries matching a filter, most recent first, like CityIterator
+type AuditIterator struct {
+	c      *Client
+	filter core.AuditFilter
+	page   []*core.AuditEntry
+	entry  *core.AuditEntry
+	last   bool
+	err    error
+}
+
+// AuditEntries returns an iterator over the audit entries matching filter, its limit is the page size
+func (c *Client) AuditEntries(filter core.AuditFilter) *AuditIterator {
+	return &AuditIterator{c: c, filter: filter}
+}
+
+// Next moves to the next entry, it returns false once every entry was read or a page failed to load
+func (it *AuditIterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+
+	if len(it.page) == 0 {
+		if it.last {
+			return false
+		}
+
+		page, err := it.c.ListAuditEntries(ctx, it.filter)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page, it.filter.Cursor, it.last = page.Entries, page.NextCursor, page.NextCursor == 0
+		if len(it.page) == 0 {
+			return false
+		}
+	}
+
+	it.entry, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Entry returns the current entry
+func (it *AuditIterator) Entry() *core.AuditEntry {
+	return it.entry
+}
+
+// Err returns the error of the page that failed to load
+func (it *AuditIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RetryPolicy decides how many times and how long apart failed requests are sent again
+type RetryPolicy struct {
+	// MaxAttempts bounds the attempts of a request, the first one included, 1 disables retries
+	MaxAttempts int
+	// MinBackoff is the wait before the first retry, it doubles at each retry
+	MinBackoff time.Duration
+	// MaxBackoff bounds the wait between attempts, requests the api asks to retry later than it are not retried
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy sends requests up to 3 times
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  200 * time.Millisecond,
+	MaxBackoff:  5 * time.Second,
+}
+
+// retryable reports whether a request that failed with err can be sent again: requests rejected by the
+// rate limiter were not handled, and requests of idempotent methods are retried on network errors and
+// when the api or its gateway is unavailable. Requests creating entities are not retried on such errors as
+// the api may have created them already.
+func retryable(method string, err error) bool {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		if apiErr.StatusCode == http.StatusTooManyRequests {
+			// the quota is exceeded until the next day while the rate limiter asks to retry after a while
+			return apiErr.RetryAfter > 0
+		}
+
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return idempotent(method)
+		}
+		return false
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) && idempotent(method)
+}
+
+// idempotent methods have the same effect when sent again, deleting again fails as the entity is gone though
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
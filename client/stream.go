@@ -0,0 +1,68 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	core "github.com/walez/weather-monster"
+)
+
+// StreamCity calls fn with the temperatures created for the city as they are, until ctx is done, fn fails
+// or the api closes the stream. With lastEventID set, the temperatures created after the one of that id
+// are sent first when the api still has them, resume a closed stream with the id of the last temperature.
+// The http client must not time out requests as streams last.
+func (c *Client) StreamCity(ctx context.Context, cityID int64, lastEventID int64, fn func(*core.Temperature) error) error {
+	req, err := c.newRequest(ctx, http.MethodGet, "/cities/"+strconv.FormatInt(cityID, 10)+"/stream", nil, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if lastEventID != 0 {
+		req.Header.Set("Last-Event-ID", strconv.FormatInt(lastEventID, 10))
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return responseError(req, res)
+	}
+
+	// events are fields lines ended by an empty line, lines starting with a colon are heartbeats
+	var event, data string
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event == "temperature" && data != "" {
+				temperature := &core.Temperature{}
+				err := json.Unmarshal([]byte(data), temperature)
+				if err != nil {
+					return fmt.Errorf("stream: invalid temperature: %w", err)
+				}
+				if err := fn(temperature); err != nil {
+					return err
+				}
+			}
+			event, data = "", ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/walez/weather-monster/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// command is a one-shot task run instead of the server e.g `api migrate up`
+type command func(ctx context.Context, cfg *config.Config, args []string) error
+
+var commands = map[string]command{
+	"config":    configCommand,
+	"keys":      keysCommand,
+	"migrate":   migrateCommand,
+	"retention": retentionCommand,
+	"rollups":   rollupsCommand,
+	"tenants":   tenantsCommand,
+}
+
+func runCommand(name string, args []string) {
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q, available commands: %v\n", name, commandNames())
+		os.Exit(2)
+	}
+
+	// commands are configured by the environment and the configuration file, their arguments are their own
+	cfg := loadConfig(nil)
+
+	err := cmd(context.Background(), cfg, args)
+	if err != nil {
+		log.Errorf("%s: %v", name, err)
+		os.Exit(1)
+	}
+}
+
+// configCommand prints the effective configuration once validated
+func configCommand(ctx context.Context, cfg *config.Config, args []string) error {
+	fmt.Print(cfg)
+	return nil
+}
+
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/auth"
+	"github.com/walez/weather-monster/config"
+	"github.com/walez/weather-monster/datastore/postgres"
+
+	"github.com/pkg/errors"
+)
+
+const keysUsage = `usage: keys create -tenant <name> -name <name> -scopes "<scope> ..." | list [-tenant <name>] | revoke <id>`
+
+// keysCommand creates, lists and revokes api keys
+func keysCommand(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(keysUsage)
+	}
+
+	database := connectPostgres(ctx, cfg.Postgres)
+	defer database.Close()
+
+	tenantService := postgres.NewTenantService(ctx, database)
+	apiKeyService := postgres.NewAPIKeyService(ctx, database)
+
+	flags := flag.NewFlagSet("keys "+args[0], flag.ContinueOnError)
+	tenantName := flags.String("tenant", "", "tenant the key belongs to")
+	name := flags.String("name", "", "name describing the key usage")
+	scopes := flags.String("scopes", "", fmt.Sprintf("space separated scopes among %v", core.Scopes))
+	if err := flags.Parse(args[1:]); err != nil {
+		return errors.New(keysUsage)
+	}
+
+	var tenant *core.Tenant
+	if *tenantName != "" {
+		var err error
+		tenant, err = tenantService.FindTenantByName(ctx, *tenantName)
+		if err != nil {
+			return errors.Wrapf(err, "unknown tenant %q", *tenantName)
+		}
+	}
+
+	switch args[0] {
+	case "create":
+		if tenant == nil || *name == "" {
+			return errors.New(keysUsage)
+		}
+
+		err := core.ValidateScopes(*scopes)
+		if err != nil {
+			return err
+		}
+
+		key, prefix, hash, err := auth.GenerateAPIKey()
+		if err != nil {
+			return err
+		}
+
+		apiKey := &core.APIKey{
+			TenantID: tenant.ID,
+			Name:     *name,
+			Prefix:   prefix,
+			Hash:     hash,
+			Scopes:   strings.Join(strings.Fields(*scopes), " "),
+		}
+		err = apiKeyService.CreateAPIKey(ctx, apiKey)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Created key %d for tenant %q, it will not be shown again:\n%s\n", apiKey.ID, tenant.Name, key)
+
+	case "list":
+		var tenantID int64
+		if tenant != nil {
+			tenantID = tenant.ID
+		}
+
+		keys, err := apiKeyService.ListAPIKeys(ctx, tenantID)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTENANT\tNAME\tPREFIX\tSCOPES\tCREATED\tREVOKED")
+		for _, k := range keys {
+			revoked := ""
+			if k.RevokedAt != nil {
+				revoked = k.RevokedAt.Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\t%s\t%s\n", k.ID, k.TenantID, k.Name, k.Prefix, k.Scopes, k.CreatedAt.Format("2006-01-02 15:04"), revoked)
+		}
+		return w.Flush()
+
+	case "revoke":
+		if flags.NArg() != 1 {
+			return errors.New(keysUsage)
+		}
+
+		id, err := strconv.ParseInt(flags.Arg(0), 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "invalid key id")
+		}
+
+		err = apiKeyService.RevokeAPIKey(ctx, id)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Revoked key %d\n", id)
+
+	default:
+		return errors.New(keysUsage)
+	}
+
+	return nil
+}
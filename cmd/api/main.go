@@ -2,51 +2,186 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/auth"
+	"github.com/walez/weather-monster/config"
+	"github.com/walez/weather-monster/datastore/cache"
 	"github.com/walez/weather-monster/datastore/postgres"
+	"github.com/walez/weather-monster/datastore/postgres/migrations"
 	"github.com/walez/weather-monster/events"
+	"github.com/walez/weather-monster/health"
+	"github.com/walez/weather-monster/logging"
+	"github.com/walez/weather-monster/metrics"
+	"github.com/walez/weather-monster/ratelimit"
+	"github.com/walez/weather-monster/rpc"
+	"github.com/walez/weather-monster/tracing"
 	"github.com/walez/weather-monster/weather"
 
+	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	_ "github.com/joho/godotenv/autoload"
+	"github.com/opentracing/opentracing-go"
 	log "github.com/sirupsen/logrus"
 )
 
 var commit string
 var branchName string
+var buildTime string
+
+// maxEventsSaturation is the events queue usage above which the api reports it is not ready
+const maxEventsSaturation = 0.9
+
+// rootAliasesDeprecatedAt is when the v1 routes served at the root were deprecated in favour of /v1
+var rootAliasesDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
 
 func main() {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
+	serve(loadConfig(os.Args[1:]))
+}
+
+// loadConfig loads and validates the configuration then sets up logging, exiting on invalid configuration
+func loadConfig(args []string) *config.Config {
+	cfg, err := config.Load(args)
+	if config.IsHelp(err) {
+		fmt.Println(err)
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	level, _ := log.ParseLevel(cfg.Log.Level)
+	log.SetLevel(level)
+
+	var formatter log.Formatter = &log.TextFormatter{}
+	if cfg.Log.Format == "json" {
+		formatter = &log.JSONFormatter{}
+	}
+	log.SetFormatter(logging.Redact(formatter))
+	return cfg
+}
+
+func serve(cfg *config.Config) {
 
 	initContext := context.Background()
 
+	// serverContext outlives individual requests and is cancelled once the server exits
+	serverContext, cancelServer := context.WithCancel(initContext)
+	defer cancelServer()
+
 	log.Info("Starting the Weather Service!")
 	log.Infof("COMMIT: %s", commit)
 	log.Infof("BRANCH: %s", branchName)
+	log.Infof("BUILD TIME: %s", buildTime)
+	log.Infof("CONFIGURATION:\n%s", cfg)
 
-	log.Info("Connecting to postgres")
-	postgresURI := os.Getenv("POSTGRES_URI")
-	database := postgres.New(initContext, postgresURI)
-	defer database.Close()
+	tracingExporter := setupTracing(cfg.Tracing)
+
+	database := connectPostgres(initContext, cfg.Postgres)
+	closers := []io.Closer{database}
+
+	if cfg.Features.AutoMigrate {
+		log.Info("Applying migrations")
+		migrator, err := postgres.NewMigrator(database, migrations.FS)
+		if err != nil {
+			log.Panicf("loading migrations: %v", err)
+		}
+
+		applied, err := migrator.Up(initContext)
+		if err != nil {
+			log.Panicf("applying migrations: %v", err)
+		}
+		log.Infof("Applied %d migrations", applied)
+	}
 
 	log.Info("Registering events manager")
-	eventsManager := events.NewManager()
+	eventsManager := events.NewManager(serverContext, events.WithWorkers(cfg.Events.Workers), events.WithQueueSize(cfg.Events.QueueSize))
+
+	retentionPolicy := cfg.Retention.Policy()
+	var weatherService core.WeatherService = postgres.NewWeatherService(initContext, database, postgres.WithRetention(retentionPolicy))
+
+	var retentionDatabase *postgres.Client
+	if !retentionPolicy.IsZero() {
+		// retention runs on its own connection as compaction may outlast the query timeout
+		retentionDatabase = connectPostgres(initContext, cfg.Postgres, postgres.WithQueryTimeout(0))
+		closers = append(closers, retentionDatabase)
+
+		log.Infof("Applying retention policy every %s", cfg.Retention.Interval)
+		retentionService := postgres.NewWeatherService(initContext, retentionDatabase, postgres.WithRetention(retentionPolicy))
+		go runRetentionJob(serverContext, retentionService, cfg.Retention.Interval)
+	}
 
-	weatherService := postgres.NewWeatherService(initContext, database)
+	forecastCache := newForecastCache(cfg.Cache)
+	if forecastCache != nil {
+		cachedService := cache.NewWeatherService(weatherService, forecastCache, cfg.Cache.TTL)
+		eventsManager.RegisterTemperatureListener(events.TemperatureCreated, cachedService.InvalidateForecast)
+		weatherService = cachedService
+	}
+
+	// overflow is validated with the configuration
+	overflow, _ := events.ParseOverflowPolicy(cfg.Stream.Overflow)
+	broker := events.NewBroker(events.WithHistory(cfg.Stream.History), events.WithHistoryRetention(cfg.Stream.HistoryRetention))
+
+	auditService := postgres.NewAuditService(initContext, database)
+	weatherHandler := weather.NewHandler(weatherService, eventsManager, auditService,
+		weather.WithHTTPClient(&http.Client{Timeout: cfg.Webhooks.Timeout}),
+		weather.WithBroker(broker),
+		weather.WithStream(cfg.Stream.Heartbeat, events.WithBuffer(cfg.Stream.Buffer), events.WithOverflow(overflow)))
+
+	tenantService := postgres.NewTenantService(initContext, database)
+	apiKeyService := postgres.NewAPIKeyService(initContext, database)
+	authenticators := newAuthenticators(initContext, cfg.Auth, tenantService, apiKeyService)
 
-	weatherHandler := weather.NewHandler(weatherService, eventsManager)
+	checker := health.NewChecker(health.BuildInfo{Commit: commit, Branch: branchName, BuildTime: buildTime})
+	checker.AddCheck("postgres", database.Ping)
+	checker.AddCheck("events", func(ctx context.Context) error {
+		if saturation := eventsManager.Saturation(); saturation > maxEventsSaturation {
+			return fmt.Errorf("events queue %.0f%% full", saturation*100)
+		}
+		return nil
+	})
 
-	r := gin.Default()
+	if cfg.Features.Metrics {
+		metrics.RegisterDBStats(database.DB().DB(), "api")
+		if retentionDatabase != nil {
+			metrics.RegisterDBStats(retentionDatabase.DB().DB(), "retention")
+		}
+	}
 
-	weatherHandler.RegisterRoutes(r.Group(weather.BasePath))
+	// versions and the gRPC api share the rate limiters so that a client has the same budget on a route
+	limiter, ipLimiter := ratelimit.New(), ratelimit.New()
+	r := newRouter(cfg, routerDeps{
+		weatherHandler: weatherHandler,
+		weatherService: weatherService,
+		authenticators: authenticators,
+		checker:        checker,
+		limiter:        limiter,
+		ipLimiter:      ipLimiter,
+	})
 
-	address := os.Getenv("ADDRESS")
 	srv := &http.Server{
-		Addr:    address,
-		Handler: r,
+		Addr:              cfg.Server.Address,
+		Handler:           r,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
 	}
 	go func() {
 		// service connections
@@ -54,14 +189,162 @@ func main() {
 			log.Panicf("listen: %s\n", err)
 		}
 	}()
+	servers := []server{srv}
+
+	if cfg.Server.GRPCAddress != "" {
+		lis, err := net.Listen("tcp", cfg.Server.GRPCAddress)
+		if err != nil {
+			log.Panicf("listen grpc: %v", err)
+		}
+
+		log.Infof("Serving gRPC on %s", cfg.Server.GRPCAddress)
+		grpcServer := rpc.NewServer(weatherHandler, authenticators, newRPCRateLimit(cfg.RateLimit, ipLimiter, limiter))
+		go func() {
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Panicf("serve grpc: %v", err)
+			}
+		}()
+		servers = append(servers, grpcServer)
+	}
 
 	// Wait for interrupt signal to gracefully shutdown the server
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	// kill (no param) default send syscanll.SIGTERM
 	// kill -2 is syscall.SIGINT
 	// kill -9 is syscall. SIGKILL but can"t be catch, so don't need add it
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
+	// fail readiness first so the orchestrator stops routing traffic before the server stops
+	log.Infof("Draining for %s", cfg.Server.DrainDelay)
+	checker.Drain()
+	time.Sleep(cfg.Server.DrainDelay)
+
+	// spans are flushed last as shutting down is traced too
+	shutdown(cfg.Server.ShutdownTimeout, servers, broker, eventsManager, cancelServer, append(closers, tracingExporter)...)
 	log.Info("Server exiting")
 }
+
+// newAuthenticators authenticates requests with api keys, then with bearer tokens, the tenant header and
+// the default tenant when configured
+func newAuthenticators(
+	ctx context.Context,
+	cfg config.Auth,
+	tenantService core.TenantService,
+	apiKeyService core.APIKeyService,
+) []auth.Authenticator {
+	authenticators := []auth.Authenticator{auth.APIKeys(apiKeyService)}
+	if cfg.OIDC.JWKS != "" {
+		log.Infof("Validating bearer tokens against %s", cfg.OIDC.JWKS)
+		authenticators = append(authenticators, auth.JWT(auth.NewJWKS(cfg.OIDC.JWKS), auth.JWTConfig{
+			Issuer:      cfg.OIDC.Issuer,
+			Audience:    cfg.OIDC.Audience,
+			TenantClaim: cfg.OIDC.TenantClaim,
+			ScopeClaim:  cfg.OIDC.ScopeClaim,
+			Leeway:      cfg.OIDC.Leeway,
+		}, tenantService))
+	}
+
+	if cfg.TrustTenantHeader {
+		log.Infof("Trusting tenant from %s header", auth.TenantHeader)
+		authenticators = append(authenticators, auth.TrustedTenantHeader(tenantService))
+	}
+
+	if cfg.DefaultTenant != "" {
+		tenant, err := tenantService.FindTenantByName(ctx, cfg.DefaultTenant)
+		if err != nil {
+			log.Panicf("unknown default tenant %q: %v", cfg.DefaultTenant, err)
+		}
+		scopes := strings.Fields(cfg.DefaultTenantScopes)
+		log.Infof("Scoping requests without credentials to tenant %q with scopes %v", tenant.Name, scopes)
+		authenticators = append(authenticators, auth.DefaultTenant(tenant, scopes...))
+	}
+	return authenticators
+}
+
+// setupTracing installs the global tracer exporting spans as configured,
+// the returned closer flushes the exporter
+func setupTracing(cfg config.Tracing) io.Closer {
+	var exporter tracing.Exporter
+	var closer io.Closer = ioutil.NopCloser(nil)
+	switch cfg.Exporter {
+	case "noop":
+		exporter = tracing.NoopExporter{}
+	case "stdout":
+		exporter = tracing.NewJSONExporter(os.Stdout)
+	case "file":
+		fileExporter, file, err := tracing.NewFileExporter(cfg.File)
+		if err != nil {
+			log.Panicf("opening tracing file: %v", err)
+		}
+		exporter = fileExporter
+		closer = file
+	}
+
+	log.Infof("Tracing %.0f%% of requests with %T", cfg.SampleRate*100, exporter)
+	opentracing.SetGlobalTracer(tracing.New(exporter, cfg.SampleRate))
+	return closer
+}
+
+// newRateLimiter limits every client to the configured requests per minute on each route
+func newRateLimiter(cfg config.RateLimit, l *ratelimit.Limiter, proxies ratelimit.Proxies, opts ...ratelimit.MiddlewareOption) gin.HandlerFunc {
+	// routes are validated with the configuration
+	routes, _ := ratelimit.ParseRouteLimits(cfg.Routes)
+
+	log.Infof("Rate limiting clients to %d requests per minute, routes: %v", cfg.PerMinute, routes)
+	return ratelimit.Middleware(l, ratelimit.PerMinute(cfg.PerMinute), routes, append(opts, ratelimit.WithProxies(proxies))...)
+}
+
+// newIPRateLimiter limits every IP to the same requests per minute on each route, before authentication
+func newIPRateLimiter(cfg config.RateLimit, l *ratelimit.Limiter, proxies ratelimit.Proxies, opts ...ratelimit.MiddlewareOption) gin.HandlerFunc {
+	log.Infof("Rate limiting IPs to %d requests per minute before authentication", cfg.PerIP)
+	return ratelimit.Middleware(l, ratelimit.PerMinute(cfg.PerIP), nil, append(opts, ratelimit.ByIP(), ratelimit.WithProxies(proxies))...)
+}
+
+// newRPCRateLimit limits gRPC calls with the limiters of the REST api
+func newRPCRateLimit(cfg config.RateLimit, ipLimiter *ratelimit.Limiter, limiter *ratelimit.Limiter) rpc.Option {
+	// routes are validated with the configuration
+	routes, _ := ratelimit.ParseRouteLimits(cfg.Routes)
+	return rpc.WithRateLimit(rpc.RateLimit{
+		IPLimiter: ipLimiter,
+		IPLimit:   ratelimit.PerMinute(cfg.PerIP),
+		Limiter:   limiter,
+		Default:   ratelimit.PerMinute(cfg.PerMinute),
+		Routes:    routes,
+	})
+}
+
+// newCORS answers preflight requests and sets the CORS headers of allowed origins
+func newCORS(cfg config.CORS) gin.HandlerFunc {
+	return cors.New(cors.Config{
+		AllowOrigins:  cfg.AllowedOrigins,
+		AllowMethods:  cfg.AllowedMethods,
+		AllowHeaders:  cfg.AllowedHeaders,
+		ExposeHeaders: cfg.ExposedHeaders,
+		MaxAge:        cfg.MaxAge,
+	})
+}
+
+// newForecastCache returns the configured store, nil when caching is disabled
+func newForecastCache(cfg config.Cache) cache.Store {
+	switch cfg.Backend {
+	case "lru":
+		log.Infof("Caching forecasts in process, size: %d", cfg.LRUSize)
+		return cache.NewLRU(cfg.LRUSize)
+	case "redis":
+		log.Info("Caching forecasts in redis")
+		return cache.NewRedis(cfg.RedisAddr, "weather-monster:")
+	default:
+		return nil
+	}
+}
+
+// connectPostgres connects as configured, opts override the configuration
+func connectPostgres(ctx context.Context, cfg config.Postgres, opts ...postgres.Option) *postgres.Client {
+	log.Info("Connecting to postgres")
+	opts = append([]postgres.Option{
+		postgres.WithQueryTimeout(cfg.QueryTimeout),
+		postgres.WithPool(cfg.MaxOpenConns, cfg.MaxIdleConns, cfg.ConnMaxLifetime),
+	}, opts...)
+	return postgres.New(ctx, cfg.URI, opts...)
+}
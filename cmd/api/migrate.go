@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/walez/weather-monster/config"
+	"github.com/walez/weather-monster/datastore/postgres"
+	"github.com/walez/weather-monster/datastore/postgres/migrations"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const migrateUsage = "usage: migrate up | down [steps] | status"
+
+// migrateCommand applies, reverts or lists the embedded schema migrations
+func migrateCommand(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	database := connectPostgres(ctx, cfg.Postgres)
+	defer database.Close()
+
+	migrator, err := postgres.NewMigrator(database, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		log.Infof("Applied %d migrations", applied)
+
+	case "down":
+		steps := 0
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return errors.Errorf("invalid steps %q, %s", args[1], migrateUsage)
+			}
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		log.Infof("Reverted %d migrations", reverted)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			fmt.Fprintf(w, "%d\t%s\t%t\n", s.Version, s.Name, s.Applied)
+		}
+		return w.Flush()
+
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/walez/weather-monster/config"
+	"github.com/walez/weather-monster/datastore/postgres"
+	"github.com/walez/weather-monster/metrics"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const retentionUsage = "usage: retention run"
+
+// retentionCommand applies the retention policy once
+func retentionCommand(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) != 1 || args[0] != "run" {
+		return errors.New(retentionUsage)
+	}
+
+	policy := cfg.Retention.Policy()
+	database := connectPostgres(ctx, cfg.Postgres, postgres.WithQueryTimeout(0))
+	defer database.Close()
+
+	weatherService := postgres.NewWeatherService(ctx, database, postgres.WithRetention(policy))
+	return applyRetention(ctx, weatherService)
+}
+
+// runRetentionJob applies the retention policy every interval until ctx is cancelled
+func runRetentionJob(ctx context.Context, weatherService *postgres.WeatherService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := applyRetention(ctx, weatherService)
+		if err != nil {
+			log.WithError(err).Error("retention: run failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func applyRetention(ctx context.Context, weatherService *postgres.WeatherService) error {
+	result, err := weatherService.ApplyRetention(ctx, time.Now())
+	if err != nil {
+		metrics.RetentionRuns.WithLabelValues("failed").Inc()
+		return err
+	}
+
+	if result == nil {
+		metrics.RetentionRuns.WithLabelValues("skipped").Inc()
+		log.Info("retention: skipped, already running on another instance")
+		return nil
+	}
+
+	metrics.RetentionRuns.WithLabelValues("completed").Inc()
+	metrics.RetentionRows.WithLabelValues("raw_deleted").Add(float64(result.RawDeleted))
+	metrics.RetentionRows.WithLabelValues("hourly_compacted").Add(float64(result.HourlyCompacted))
+	metrics.RetentionRows.WithLabelValues("daily_deleted").Add(float64(result.DailyDeleted))
+	log.WithFields(log.Fields{
+		"raw_deleted":      result.RawDeleted,
+		"hourly_compacted": result.HourlyCompacted,
+		"daily_deleted":    result.DailyDeleted,
+	}).Info("retention: run completed")
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+
+	"github.com/walez/weather-monster/config"
+	"github.com/walez/weather-monster/datastore/postgres"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const rollupsUsage = "usage: rollups backfill"
+
+// rollupsCommand maintains the hourly temperature rollups used by forecasts
+func rollupsCommand(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) != 1 || args[0] != "backfill" {
+		return errors.New(rollupsUsage)
+	}
+
+	policy := cfg.Retention.Policy()
+	// backfilling scans the whole temperatures table so it is not bound by the query timeout
+	database := connectPostgres(ctx, cfg.Postgres, postgres.WithQueryTimeout(0))
+	defer database.Close()
+
+	weatherService := postgres.NewWeatherService(ctx, database, postgres.WithRetention(policy))
+	buckets, err := weatherService.BackfillRollups(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Backfilled %d hourly rollups", buckets)
+	return nil
+}
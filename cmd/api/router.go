@@ -0,0 +1,74 @@
+package main
+
+import (
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/apidocs"
+	"github.com/walez/weather-monster/auth"
+	"github.com/walez/weather-monster/config"
+	"github.com/walez/weather-monster/deprecation"
+	"github.com/walez/weather-monster/gql"
+	"github.com/walez/weather-monster/health"
+	"github.com/walez/weather-monster/logging"
+	"github.com/walez/weather-monster/metrics"
+	"github.com/walez/weather-monster/ratelimit"
+	"github.com/walez/weather-monster/requestid"
+	"github.com/walez/weather-monster/tracing"
+	"github.com/walez/weather-monster/weather"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// routerDeps are the services the routes of the api are served by
+type routerDeps struct {
+	weatherHandler *weather.Handler
+	weatherService core.WeatherService
+	authenticators []auth.Authenticator
+	checker        *health.Checker
+	// limiter and ipLimiter are shared with the gRPC api
+	limiter   *ratelimit.Limiter
+	ipLimiter *ratelimit.Limiter
+}
+
+// newRouter registers every route of the api as configured
+func newRouter(cfg *config.Config, deps routerDeps) *gin.Engine {
+	r := gin.New()
+	// clients are identified by the peer address unless it is a trusted proxy, see ratelimit.Proxies
+	r.ForwardedByClientIP = false
+	r.Use(gin.Recovery(), requestid.Middleware(), logging.Middleware())
+	if cfg.Features.Metrics {
+		r.Use(metrics.Middleware())
+		r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	}
+	r.Use(tracing.Middleware())
+	if cfg.CORS.Enabled() {
+		log.Infof("Allowing cross-origin requests from %v", cfg.CORS.AllowedOrigins)
+		r.Use(newCORS(cfg.CORS))
+	}
+
+	// versions share the rate limiters so that a client has the same budget on a route whatever its path,
+	// IPs are limited before authentication so that requests with invalid credentials are throttled too
+	limiter, ipLimiter, authenticators := deps.limiter, deps.ipLimiter, deps.authenticators
+	proxies, _ := ratelimit.ParseProxies(cfg.Server.TrustedProxies) // validated with the configuration
+	deps.weatherHandler.RegisterRoutes(r.Group(weather.V1Path, newIPRateLimiter(cfg.RateLimit, ipLimiter, proxies, ratelimit.TrimPrefix(weather.V1Path)),
+		auth.Middleware(authenticators...), newRateLimiter(cfg.RateLimit, limiter, proxies, ratelimit.TrimPrefix(weather.V1Path))))
+	// mutations are also charged to their REST route so that aliasing them does not bypass its limit
+	routes, _ := ratelimit.ParseRouteLimits(cfg.RateLimit.Routes) // validated with the configuration
+	gqlRateLimit := gql.WithRateLimit(gql.RateLimit{
+		Limiter: limiter,
+		Default: ratelimit.PerMinute(cfg.RateLimit.PerMinute),
+		Routes:  routes,
+		Proxies: proxies,
+	})
+	gql.NewHandler(deps.weatherHandler, deps.weatherService, gqlRateLimit).RegisterRoutes(r.Group("/", newIPRateLimiter(cfg.RateLimit, ipLimiter, proxies),
+		auth.Middleware(authenticators...), newRateLimiter(cfg.RateLimit, limiter, proxies)))
+	if cfg.API.RootAliases {
+		log.Infof("Serving deprecated v1 routes at the root until %s", cfg.API.RootAliasesSunset)
+		deps.weatherHandler.RegisterRoutes(r.Group("/", deprecation.Middleware(rootAliasesDeprecatedAt, cfg.API.Sunset(), weather.V1Path),
+			newIPRateLimiter(cfg.RateLimit, ipLimiter, proxies), auth.Middleware(authenticators...), newRateLimiter(cfg.RateLimit, limiter, proxies)))
+	}
+
+	deps.checker.RegisterRoutes(r)
+	apidocs.RegisterRoutes(r)
+	return r
+}
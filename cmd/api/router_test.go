@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/walez/weather-monster/apidocs"
+	"github.com/walez/weather-monster/config"
+	"github.com/walez/weather-monster/events"
+	"github.com/walez/weather-monster/health"
+	mocks "github.com/walez/weather-monster/mocks"
+	"github.com/walez/weather-monster/ratelimit"
+	"github.com/walez/weather-monster/weather"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+func TestNewRouter_Spec(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(apidocs.Spec(), &spec))
+	documented := map[string]bool{}
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			if method != "parameters" {
+				documented[strings.ToUpper(method)+" "+path] = true
+			}
+		}
+	}
+
+	cfg := config.Default()
+	cfg.Features.Metrics = true
+	cfg.API.RootAliases = true
+
+	ws := mocks.NewMockWeatherService(mockCtrl)
+	r := newRouter(cfg, routerDeps{
+		weatherHandler: weather.NewHandler(ws, events.NewManager(context.Background()), mocks.NewMockAuditService(mockCtrl)),
+		weatherService: ws,
+		checker:        health.NewChecker(health.BuildInfo{}),
+		limiter:        ratelimit.New(),
+		ipLimiter:      ratelimit.New(),
+	})
+
+	served := map[string]bool{}
+	for _, route := range r.Routes() {
+		served[route.Method+" "+pathParam.ReplaceAllString(route.Path, "{$1}")] = true
+	}
+
+	for route := range served {
+		if documented[route] {
+			continue
+		}
+		// the root aliases are documented by the v1 routes they alias
+		method, path := splitRoute(route)
+		assert.True(t, documented[method+" "+weather.V1Path+path], "%s is not documented in openapi.json", route)
+	}
+
+	for route := range documented {
+		assert.True(t, served[route], "%s is documented but not served", route)
+
+		method, path := splitRoute(route)
+		if strings.HasPrefix(path, weather.V1Path+"/") {
+			alias := method + " " + strings.TrimPrefix(path, weather.V1Path)
+			assert.True(t, served[alias], "%s is not served at the root", route)
+		}
+	}
+}
+
+func splitRoute(route string) (string, string) {
+	parts := strings.SplitN(route, " ", 2)
+	return parts[0], parts[1]
+}
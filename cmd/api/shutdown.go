@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/walez/weather-monster/events"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// server is implemented by the http and gRPC servers
+type server interface {
+	Shutdown(ctx context.Context) error
+}
+
+// shutdown stops the api in order within timeout: live temperature streams are closed, the servers stop
+// accepting connections and wait for in-flight requests, then the queued events and their webhook deliveries
+// are flushed, then background jobs are cancelled and finally closers, e.g the databases, are closed
+func shutdown(
+	timeout time.Duration,
+	servers []server,
+	broker *events.Broker,
+	eventsManager *events.Manager,
+	cancelServer context.CancelFunc,
+	closers ...io.Closer,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// streams only end once their subscription is closed
+	broker.Close()
+
+	log.Info("Shutting down: waiting for in-flight requests")
+	for _, srv := range servers {
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			log.WithError(err).Errorf("Shutting down: %T requests still running at deadline", srv)
+		}
+	}
+
+	log.Info("Shutting down: flushing pending events")
+	err := eventsManager.Shutdown(ctx)
+	if err != nil {
+		log.WithError(err).Error("Shutting down: events still pending at deadline, aborting them")
+	}
+
+	// aborts listeners still running past the deadline and background jobs
+	cancelServer()
+
+	log.Info("Shutting down: closing connections")
+	for _, closer := range closers {
+		err := closer.Close()
+		if err != nil {
+			log.WithError(err).Error("Shutting down: close failed")
+		}
+	}
+}
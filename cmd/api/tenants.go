@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/config"
+	"github.com/walez/weather-monster/datastore/postgres"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const tenantsUsage = "usage: tenants create <name> | list | quota <name> <temperatures per day, 0 for unlimited> | usage <name>"
+
+// tenantsCommand manages the tenants sharing the deployment
+func tenantsCommand(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(tenantsUsage)
+	}
+
+	database := connectPostgres(ctx, cfg.Postgres)
+	defer database.Close()
+
+	tenantService := postgres.NewTenantService(ctx, database)
+
+	switch {
+	case args[0] == "create" && len(args) == 2:
+		tenant := &core.Tenant{Name: args[1]}
+		err := tenantService.CreateTenant(ctx, tenant)
+		if err != nil {
+			return err
+		}
+		log.Infof("Created tenant %q with id %d", tenant.Name, tenant.ID)
+
+	case args[0] == "list" && len(args) == 1:
+		tenants, err := tenantService.ListTenants(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tDAILY TEMPERATURE QUOTA")
+		for _, tenant := range tenants {
+			fmt.Fprintf(w, "%d\t%s\t%d\n", tenant.ID, tenant.Name, tenant.DailyTemperatureQuota)
+		}
+		return w.Flush()
+
+	case args[0] == "quota" && len(args) == 3:
+		quota, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil || quota < 0 {
+			return errors.Errorf("invalid quota %q", args[2])
+		}
+
+		tenant, err := tenantService.FindTenantByName(ctx, args[1])
+		if err != nil {
+			return errors.Wrapf(err, "unknown tenant %q", args[1])
+		}
+
+		err = tenantService.SetDailyTemperatureQuota(ctx, tenant.ID, quota)
+		if err != nil {
+			return err
+		}
+		log.Infof("Set daily temperature quota of tenant %q to %d", tenant.Name, quota)
+
+	case args[0] == "usage" && len(args) == 2:
+		tenant, err := tenantService.FindTenantByName(ctx, args[1])
+		if err != nil {
+			return errors.Wrapf(err, "unknown tenant %q", args[1])
+		}
+
+		usage, err := tenantService.GetTenantUsage(ctx, tenant.ID, time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d/%d temperatures on %s\n", tenant.Name, usage.Temperatures, tenant.DailyTemperatureQuota, usage.Day.Format("2006-01-02"))
+
+	default:
+		return errors.New(tenantsUsage)
+	}
+
+	return nil
+}
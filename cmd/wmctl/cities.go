@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/client"
+)
+
+// citiesCommand lists, shows, creates, updates and deletes cities
+func citiesCommand(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	flags := flag.NewFlagSet("cities "+args[0], flag.ContinueOnError)
+	switch args[0] {
+	case "list":
+		cursor := flags.Int64("cursor", 0, "id of the last city of the previous page")
+		limit := flags.Int("limit", 0, "size of the page")
+		all := flags.Bool("all", false, "list the cities of every page")
+		if err := parseFlags(flags, args[1:]); err != nil {
+			return err
+		}
+
+		var cities []*core.City
+		for {
+			page, err := e.client.ListCities(ctx, *cursor, *limit)
+			if err != nil {
+				return err
+			}
+			cities = append(cities, page.Cities...)
+
+			if !*all || page.NextCursor == 0 {
+				if cities == nil {
+					cities = []*core.City{}
+				}
+				return e.out.cities(cities, cities...)
+			}
+			*cursor = page.NextCursor
+		}
+
+	case "get":
+		if err := parseFlags(flags, args[1:]); err != nil {
+			return err
+		}
+		id, err := idArg(flags)
+		if err != nil {
+			return err
+		}
+
+		city, err := e.client.GetCity(ctx, id)
+		if err != nil {
+			return err
+		}
+		return e.out.cities(city, city)
+
+	case "create":
+		name := flags.String("name", "", "name of the city")
+		latitude := flags.Float64("lat", 0, "latitude of the city")
+		longitude := flags.Float64("lon", 0, "longitude of the city")
+		if err := parseFlags(flags, args[1:]); err != nil {
+			return err
+		}
+		if *name == "" || flags.NArg() != 0 {
+			return errUsage
+		}
+
+		city, err := e.client.CreateCity(ctx, client.CityParams{Name: name, Latitude: latitude, Longitude: longitude})
+		if err != nil {
+			return err
+		}
+		return e.out.cities(city, city)
+
+	case "update":
+		name := flags.String("name", "", "new name of the city")
+		latitude := flags.Float64("lat", 0, "new latitude of the city")
+		longitude := flags.Float64("lon", 0, "new longitude of the city")
+		if err := parseFlags(flags, args[1:]); err != nil {
+			return err
+		}
+		id, err := idArg(flags)
+		if err != nil {
+			return err
+		}
+
+		// only the flags set are updated
+		params := client.CityParams{}
+		flags.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "name":
+				params.Name = name
+			case "lat":
+				params.Latitude = latitude
+			case "lon":
+				params.Longitude = longitude
+			}
+		})
+
+		city, err := e.client.UpdateCity(ctx, id, params)
+		if err != nil {
+			return err
+		}
+		return e.out.cities(city, city)
+
+	case "delete":
+		if err := parseFlags(flags, args[1:]); err != nil {
+			return err
+		}
+		id, err := idArg(flags)
+		if err != nil {
+			return err
+		}
+
+		city, err := e.client.DeleteCity(ctx, id)
+		if err != nil {
+			return err
+		}
+		return e.out.cities(city, city)
+
+	default:
+		return errUsage
+	}
+}
+
+// forecastCommand shows the forecast of a city
+func forecastCommand(ctx context.Context, e *env, args []string) error {
+	flags := flag.NewFlagSet("forecast", flag.ContinueOnError)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	cityID, err := idArg(flags)
+	if err != nil {
+		return err
+	}
+
+	forecast, err := e.client.GetCityForecast(ctx, cityID)
+	if err != nil {
+		return err
+	}
+	return e.out.forecast(forecast)
+}
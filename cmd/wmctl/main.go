@@ -0,0 +1,139 @@
+// Command wmctl manages the cities, temperatures and webhooks of a tenant through the weather API
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"syscall"
+
+	"github.com/walez/weather-monster/client"
+)
+
+const usage = `usage: wmctl [-url <url>] [-token <token>] [-o table|json] [-timeout <duration>] <command> [args]
+
+commands:
+  cities list [-cursor <id>] [-limit <n>] [-all]
+  cities get <id>
+  cities create -name <name> -lat <latitude> -lon <longitude>
+  cities update [-name <name>] [-lat <latitude>] [-lon <longitude>] <id>
+  cities delete <id>
+  temperatures create -city <id> -max <max> -min <min>
+  temperatures import [-file <csv>]   creates the city_id,max,min rows of the file, stdin by default
+  forecast <city id>
+  webhooks list -city <id>
+  webhooks create -city <id> -url <callback url> [-version <payload version>]
+  webhooks delete <id>
+  tail [-last-event-id <id>] <city id>   prints the temperatures of the city as they are created
+
+flags default to the WEATHER_URL and WEATHER_TOKEN environment variables`
+
+// command runs a subcommand with the arguments following its name
+type command func(ctx context.Context, env *env, args []string) error
+
+var commands = map[string]command{
+	"cities":       citiesCommand,
+	"temperatures": temperaturesCommand,
+	"forecast":     forecastCommand,
+	"webhooks":     webhooksCommand,
+	"tail":         tailCommand,
+}
+
+// env is what commands share, the api client and the output
+type env struct {
+	client *client.Client
+	out    *output
+}
+
+// errUsage makes the command print its usage
+var errUsage = errors.New(usage)
+
+func main() {
+	flags := flag.NewFlagSet("wmctl", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprintln(os.Stderr, usage) }
+	baseURL := flags.String("url", envOr("WEATHER_URL", client.DefaultBaseURL), "url of the weather api")
+	token := flags.String("token", os.Getenv("WEATHER_TOKEN"), "api key or access token")
+	format := flags.String("o", "table", "output format, table or json")
+	timeout := flags.Duration("timeout", client.DefaultTimeout, "timeout of each request, streams excepted")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q, available commands: %v\n", flags.Arg(0), commandNames())
+		os.Exit(2)
+	}
+
+	out, err := newOutput(*format, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	c, err := client.New(*baseURL, client.WithToken(*token), client.WithTimeout(*timeout))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	err = cmd(ctx, &env{client: c, out: out}, flags.Args()[1:])
+	if errors.Is(err, errUsage) {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", flags.Arg(0), err)
+		os.Exit(1)
+	}
+}
+
+func envOr(name string, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseFlags parses the flags of a subcommand, its usage is the one of wmctl
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	flags.Usage = func() {}
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	return nil
+}
+
+// idArg parses the single id argument left once flags are parsed
+func idArg(flags *flag.FlagSet) (int64, error) {
+	if flags.NArg() != 1 {
+		return 0, errUsage
+	}
+
+	id, err := strconv.ParseInt(flags.Arg(0), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid id %q", flags.Arg(0))
+	}
+	return id, nil
+}
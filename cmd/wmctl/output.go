@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	core "github.com/walez/weather-monster"
+)
+
+// output prints the entities returned by the api as a table or as JSON
+type output struct {
+	json bool
+	w    io.Writer
+}
+
+func newOutput(format string, w io.Writer) (*output, error) {
+	switch format {
+	case "table":
+		return &output{w: w}, nil
+	case "json":
+		return &output{json: true, w: w}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q, expected table or json", format)
+	}
+}
+
+// print prints value as indented JSON, or rows under the header as a table
+func (o *output) print(value interface{}, header []string, rows [][]string) error {
+	if o.json {
+		encoder := json.NewEncoder(o.w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+
+	w := tabwriter.NewWriter(o.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+var (
+	cityHeader        = []string{"ID", "NAME", "LATITUDE", "LONGITUDE"}
+	temperatureHeader = []string{"ID", "CITY", "MAX", "MIN", "TIME"}
+	webhookHeader     = []string{"ID", "CITY", "CALLBACK URL", "VERSION"}
+)
+
+func (o *output) cities(value interface{}, cities ...*core.City) error {
+	rows := make([][]string, len(cities))
+	for i, city := range cities {
+		rows[i] = []string{
+			formatID(city.ID),
+			city.Name,
+			strconv.FormatFloat(city.Latitude, 'f', -1, 64),
+			strconv.FormatFloat(city.Longitude, 'f', -1, 64),
+		}
+	}
+	return o.print(value, cityHeader, rows)
+}
+
+func (o *output) temperatures(value interface{}, temperatures ...*core.Temperature) error {
+	rows := make([][]string, len(temperatures))
+	for i, temperature := range temperatures {
+		rows[i] = temperatureRow(temperature)
+	}
+	return o.print(value, temperatureHeader, rows)
+}
+
+func temperatureRow(temperature *core.Temperature) []string {
+	return []string{
+		formatID(temperature.ID),
+		formatID(temperature.CityID),
+		strconv.Itoa(temperature.Max),
+		strconv.Itoa(temperature.Min),
+		time.Unix(temperature.Timestamp, 0).Format(time.RFC3339),
+	}
+}
+
+func (o *output) webhooks(value interface{}, webhooks ...*core.Webhook) error {
+	rows := make([][]string, len(webhooks))
+	for i, webhook := range webhooks {
+		rows[i] = []string{formatID(webhook.ID), formatID(webhook.CityID), webhook.CallbackURL, strconv.Itoa(webhook.PayloadVersion)}
+	}
+	return o.print(value, webhookHeader, rows)
+}
+
+// createdWebhook prints the webhook with its secret, which the api never answers again
+func (o *output) createdWebhook(webhook *core.Webhook) error {
+	value := map[string]interface{}{
+		"id":              webhook.ID,
+		"city_id":         webhook.CityID,
+		"callback_url":    webhook.CallbackURL,
+		"payload_version": webhook.PayloadVersion,
+		"secret":          webhook.Secret,
+	}
+	return o.print(value, []string{"ID", "CITY", "CALLBACK URL", "VERSION", "SECRET"}, [][]string{{
+		formatID(webhook.ID), formatID(webhook.CityID), webhook.CallbackURL, strconv.Itoa(webhook.PayloadVersion), webhook.Secret,
+	}})
+}
+
+func (o *output) forecast(forecast *core.Forecast) error {
+	return o.print(forecast, []string{"CITY", "MAX", "MIN", "SAMPLE"}, [][]string{{
+		formatID(forecast.CityID),
+		strconv.FormatFloat(forecast.Max, 'f', 2, 64),
+		strconv.FormatFloat(forecast.Min, 'f', 2, 64),
+		strconv.FormatInt(forecast.Sample, 10),
+	}})
+}
+
+func formatID(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
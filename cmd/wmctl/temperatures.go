@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	core "github.com/walez/weather-monster"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// reconnectDelay is how long tail waits before resuming a closed stream
+const reconnectDelay = time.Second
+
+// temperaturesCommand creates temperatures, one from its flags or many from a CSV file
+func temperaturesCommand(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	flags := flag.NewFlagSet("temperatures "+args[0], flag.ContinueOnError)
+	switch args[0] {
+	case "create":
+		cityID := flags.Int64("city", 0, "id of the city")
+		max := flags.Int("max", 0, "highest temperature in Celsius")
+		min := flags.Int("min", 0, "lowest temperature in Celsius")
+		if err := parseFlags(flags, args[1:]); err != nil {
+			return err
+		}
+		if *cityID == 0 || flags.NArg() != 0 {
+			return errUsage
+		}
+
+		temperature, err := e.client.CreateTemperature(ctx, *cityID, *max, *min)
+		if err != nil {
+			return err
+		}
+		return e.out.temperatures(temperature, temperature)
+
+	case "import":
+		file := flags.String("file", "-", "CSV file of city_id,max,min rows, - reads stdin")
+		if err := parseFlags(flags, args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() != 0 {
+			return errUsage
+		}
+
+		var r io.Reader = os.Stdin
+		if *file != "-" {
+			f, err := os.Open(*file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+
+		rows, err := readTemperatures(r)
+		if err != nil {
+			return err
+		}
+
+		// rows are created in order, the ones before a failing row stay created
+		temperatures := make([]*core.Temperature, 0, len(rows))
+		for _, row := range rows {
+			temperature, err := e.client.CreateTemperature(ctx, row.CityID, row.Max, row.Min)
+			if err != nil {
+				e.out.temperatures(temperatures, temperatures...)
+				return fmt.Errorf("line %d: %w, %d temperatures created before it", row.line, err, len(temperatures))
+			}
+			temperatures = append(temperatures, temperature)
+		}
+		return e.out.temperatures(temperatures, temperatures...)
+
+	default:
+		return errUsage
+	}
+}
+
+// csvTemperature is a temperature read from a CSV line
+type csvTemperature struct {
+	core.Temperature
+	line int
+}
+
+// readTemperatures reads city_id,max,min rows, a first line that is not a row is taken for a header
+func readTemperatures(r io.Reader) ([]csvTemperature, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	var rows []csvTemperature
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var values [3]int64
+		for i, field := range record {
+			values[i], err = strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: expected city_id,max,min integers, got %q", line, strings.Join(record, ","))
+		}
+
+		rows = append(rows, csvTemperature{
+			Temperature: core.Temperature{CityID: values[0], Max: int(values[1]), Min: int(values[2])},
+			line:        line,
+		})
+	}
+}
+
+// tailCommand prints the temperatures created for a city until interrupted, resuming the stream when it closes
+func tailCommand(ctx context.Context, e *env, args []string) error {
+	flags := flag.NewFlagSet("tail", flag.ContinueOnError)
+	lastEventID := flags.Int64("last-event-id", 0, "also print the temperatures created after the one of this id")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	cityID, err := idArg(flags)
+	if err != nil {
+		return err
+	}
+
+	// rows are printed as they arrive so columns have a fixed width rather than the one of a table
+	printRow := func(row []string) error {
+		_, err := fmt.Fprintf(e.out.w, "%-10s %-10s %-5s %-5s %s\n", row[0], row[1], row[2], row[3], row[4])
+		return err
+	}
+	if !e.out.json {
+		printRow(temperatureHeader)
+	}
+
+	for attempt := 0; ; attempt++ {
+		received := false
+		err := e.client.StreamCity(ctx, cityID, *lastEventID, func(temperature *core.Temperature) error {
+			received = true
+			*lastEventID = temperature.ID
+			if e.out.json {
+				// one temperature per line
+				return json.NewEncoder(e.out.w).Encode(temperature)
+			}
+			return printRow(temperatureRow(temperature))
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		// failing right away is not an interruption, e.g an unknown city
+		if err != nil && attempt == 0 && !received {
+			return err
+		}
+		if err != nil {
+			log.WithError(err).Warning("stream interrupted, resuming")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	core "github.com/walez/weather-monster"
+)
+
+// webhooksCommand lists, creates and deletes the webhooks of cities
+func webhooksCommand(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	flags := flag.NewFlagSet("webhooks "+args[0], flag.ContinueOnError)
+	switch args[0] {
+	case "list":
+		cityID := flags.Int64("city", 0, "id of the city")
+		if err := parseFlags(flags, args[1:]); err != nil {
+			return err
+		}
+		if *cityID == 0 || flags.NArg() != 0 {
+			return errUsage
+		}
+
+		webhooks, err := e.client.ListWebhooks(ctx, *cityID)
+		if err != nil {
+			return err
+		}
+		if webhooks == nil {
+			webhooks = []*core.Webhook{}
+		}
+		return e.out.webhooks(webhooks, webhooks...)
+
+	case "create":
+		cityID := flags.Int64("city", 0, "id of the city")
+		callbackURL := flags.String("url", "", "url the temperatures of the city are posted to")
+		version := flags.Int("version", 0, "version of the payloads, the latest by default")
+		if err := parseFlags(flags, args[1:]); err != nil {
+			return err
+		}
+		if *cityID == 0 || *callbackURL == "" || flags.NArg() != 0 {
+			return errUsage
+		}
+
+		webhook, err := e.client.CreateWebhook(ctx, *cityID, *callbackURL, *version)
+		if err != nil {
+			return err
+		}
+		return e.out.createdWebhook(webhook)
+
+	case "delete":
+		if err := parseFlags(flags, args[1:]); err != nil {
+			return err
+		}
+		id, err := idArg(flags)
+		if err != nil {
+			return err
+		}
+
+		webhook, err := e.client.DeleteWebhook(ctx, id)
+		if err != nil {
+			return err
+		}
+		return e.out.webhooks(webhook, webhook)
+
+	default:
+		return errUsage
+	}
+}
@@ -0,0 +1,75 @@
+// Command wmreceiver receives the webhook callbacks of the weather API and records them for debugging,
+// verifying their signature like the receivers built with the webhook package
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/walez/weather-monster/webhook"
+)
+
+const usage = `usage: wmreceiver [-addr <address>] [-secret <secret>] [-tolerance <duration>] [-keep <n>] [-file <path>]
+
+Receives the callbacks posted to / and prints a JSON line per callback, rejected ones included.
+GET /deliveries lists the last recorded callbacks.
+
+-secret defaults to the WEATHER_WEBHOOK_SECRET environment variable`
+
+func main() {
+	flags := flag.NewFlagSet("wmreceiver", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprintln(os.Stderr, usage) }
+	addr := flags.String("addr", "localhost:9000", "address to listen on")
+	secret := flags.String("secret", os.Getenv("WEATHER_WEBHOOK_SECRET"), "secret answered when the webhook was created")
+	tolerance := flags.Duration("tolerance", webhook.DefaultTolerance, "how old callbacks can be")
+	keep := flags.Int("keep", 100, "number of callbacks listed by GET /deliveries")
+	file := flags.String("file", "", "file the callbacks are appended to, stdout by default")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+	if *secret == "" || flags.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	out := os.Stdout
+	if *file != "" {
+		f, err := os.OpenFile(*file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	rec := newRecorder(out, *keep)
+	handler := webhook.NewHandler(*secret, rec.event, webhook.WithTolerance(*tolerance))
+
+	mux := http.NewServeMux()
+	mux.Handle("/", rec.record(handler))
+	mux.HandleFunc("/deliveries", rec.list)
+	srv := &http.Server{Addr: *addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "wmreceiver: listening on http://%s/\n", *addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
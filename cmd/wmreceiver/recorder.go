@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/walez/weather-monster/webhook"
+)
+
+// callback is a recorded request of the api and how it was answered
+type callback struct {
+	ReceivedAt time.Time `json:"received_at"`
+	DeliveryID string    `json:"delivery_id,omitempty"`
+	Timestamp  string    `json:"timestamp,omitempty"`
+	Signature  string    `json:"signature,omitempty"`
+	Status     int       `json:"status"`
+	// Error is why the callback was rejected
+	Error   string          `json:"error,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	// Event is the payload decoded by the webhook handler, nil when rejected or a duplicate
+	Event     *webhook.Event `json:"event,omitempty"`
+	Duplicate bool           `json:"duplicate,omitempty"`
+}
+
+type callbackKey struct{}
+
+// recorder prints the callbacks and keeps the last ones
+type recorder struct {
+	mu        sync.Mutex
+	out       *json.Encoder
+	callbacks []*callback
+	keep      int
+}
+
+func newRecorder(out io.Writer, keep int) *recorder {
+	return &recorder{out: json.NewEncoder(out), keep: keep}
+}
+
+// record records the requests served by next
+func (rec *recorder) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(payload))
+
+		cb := &callback{
+			ReceivedAt: time.Now().UTC(),
+			DeliveryID: r.Header.Get(webhook.DeliveryHeader),
+			Timestamp:  r.Header.Get(webhook.TimestampHeader),
+			Signature:  r.Header.Get(webhook.SignatureHeader),
+		}
+		if json.Valid(payload) {
+			cb.Payload = payload
+		} else if len(payload) > 0 {
+			cb.Payload, _ = json.Marshal(string(payload))
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), callbackKey{}, cb)))
+		cb.Status = sw.status
+		cb.Duplicate = sw.status == http.StatusOK && cb.Event == nil
+		if sw.status >= http.StatusBadRequest {
+			cb.Error = strings.TrimSpace(sw.body.String())
+		}
+
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.out.Encode(cb)
+		rec.callbacks = append(rec.callbacks, cb)
+		if len(rec.callbacks) > rec.keep {
+			rec.callbacks = rec.callbacks[len(rec.callbacks)-rec.keep:]
+		}
+	})
+}
+
+// event is the callback of the webhook handler, it records the decoded event
+func (rec *recorder) event(ctx context.Context, event *webhook.Event) error {
+	if cb, ok := ctx.Value(callbackKey{}).(*callback); ok {
+		cb.Event = event
+	}
+	return nil
+}
+
+// list answers the last callbacks, most recent first
+func (rec *recorder) list(w http.ResponseWriter, r *http.Request) {
+	rec.mu.Lock()
+	callbacks := make([]*callback, len(rec.callbacks))
+	for i, cb := range rec.callbacks {
+		callbacks[len(callbacks)-1-i] = cb
+	}
+	rec.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(callbacks)
+}
+
+// statusWriter keeps the status and the error message answered
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
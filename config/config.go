@@ -0,0 +1,305 @@
+// Package config loads the api configuration from flags, environment variables and an optional YAML file
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the api configuration, every option can be set with a flag, an environment variable
+// or in the YAML file given with --config
+type Config struct {
+	File string `long:"config" env:"CONFIG_FILE" description:"YAML file to read the configuration from" yaml:"-"`
+
+	Server    Server    `group:"Server" namespace:"server" yaml:"server"`
+	API       API       `group:"API" namespace:"api" yaml:"api"`
+	Postgres  Postgres  `group:"Postgres" namespace:"postgres" yaml:"postgres"`
+	Log       Log       `group:"Logging" namespace:"log" yaml:"log"`
+	CORS      CORS      `group:"CORS" namespace:"cors" yaml:"cors"`
+	Cache     Cache     `group:"Forecast cache" namespace:"cache" yaml:"cache"`
+	Retention Retention `group:"Retention" namespace:"retention" yaml:"retention"`
+	Auth      Auth      `group:"Authentication" namespace:"auth" yaml:"auth"`
+	RateLimit RateLimit `group:"Rate limiting" namespace:"rate-limit" yaml:"rate_limit"`
+	Events    Events    `group:"Events" namespace:"events" yaml:"events"`
+	Webhooks  Webhooks  `group:"Webhooks" namespace:"webhooks" yaml:"webhooks"`
+	Stream    Stream    `group:"Live streams" namespace:"stream" yaml:"stream"`
+	Tracing   Tracing   `group:"Tracing" namespace:"tracing" yaml:"tracing"`
+	Features  Features  `group:"Features" namespace:"features" yaml:"features"`
+}
+
+type Server struct {
+	Address           string        `long:"address" env:"ADDRESS" description:"address the api listens on" yaml:"address"`
+	GRPCAddress       string        `long:"grpc-address" env:"GRPC_ADDRESS" description:"address the gRPC api listens on, empty disables it" yaml:"grpc_address"`
+	ReadTimeout       time.Duration `long:"read-timeout" env:"SERVER_READ_TIMEOUT" description:"maximum duration to read a request, 0 disables it" yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `long:"read-header-timeout" env:"SERVER_READ_HEADER_TIMEOUT" description:"maximum duration to read request headers" yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `long:"write-timeout" env:"SERVER_WRITE_TIMEOUT" description:"maximum duration to write a response, 0 disables it and is required by live streams" yaml:"write_timeout"`
+	IdleTimeout       time.Duration `long:"idle-timeout" env:"SERVER_IDLE_TIMEOUT" description:"how long keep-alive connections are kept idle" yaml:"idle_timeout"`
+	DrainDelay        time.Duration `long:"drain-delay" env:"SHUTDOWN_DRAIN_DELAY" description:"how long readiness fails before shutting down" yaml:"drain_delay"`
+	ShutdownTimeout   time.Duration `long:"shutdown-timeout" env:"SHUTDOWN_TIMEOUT" description:"deadline to finish requests and events once shutting down" yaml:"shutdown_timeout"`
+	TrustedProxies    []string      `long:"trusted-proxy" env:"SERVER_TRUSTED_PROXIES" env-delim:"," description:"IPs or CIDRs of the reverse proxies trusted to forward the address of clients, the forwarded headers of other peers are ignored" yaml:"trusted_proxies"`
+}
+
+// API serves the current version under its own path, root aliases of v1 routes are kept for clients
+// predating versioning until the sunset date
+type API struct {
+	RootAliases       bool   `long:"root-aliases" env:"API_ROOT_ALIASES" description:"also serve the deprecated v1 routes at the root" yaml:"root_aliases"`
+	RootAliasesSunset string `long:"root-aliases-sunset" env:"API_ROOT_ALIASES_SUNSET" description:"date the root aliases stop being served, as YYYY-MM-DD" yaml:"root_aliases_sunset"`
+}
+
+// SunsetLayout is the layout of API.RootAliasesSunset
+const SunsetLayout = "2006-01-02"
+
+// Sunset returns the date the root aliases stop being served, zero when unset
+func (a API) Sunset() time.Time {
+	sunset, _ := time.Parse(SunsetLayout, a.RootAliasesSunset)
+	return sunset
+}
+
+type Postgres struct {
+	URI             string        `long:"uri" env:"POSTGRES_URI" description:"postgres connection string" yaml:"uri"`
+	QueryTimeout    time.Duration `long:"query-timeout" env:"POSTGRES_QUERY_TIMEOUT" description:"maximum duration of a query, 0 disables it" yaml:"query_timeout"`
+	MaxOpenConns    int           `long:"max-open-conns" env:"POSTGRES_MAX_OPEN_CONNS" description:"maximum open connections, 0 is unlimited" yaml:"max_open_conns"`
+	MaxIdleConns    int           `long:"max-idle-conns" env:"POSTGRES_MAX_IDLE_CONNS" description:"maximum idle connections" yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `long:"conn-max-lifetime" env:"POSTGRES_CONN_MAX_LIFETIME" description:"maximum duration a connection is reused, 0 reuses it forever" yaml:"conn_max_lifetime"`
+}
+
+type Log struct {
+	Level  string `long:"level" env:"LOG_LEVEL" description:"minimum level logged: trace, debug, info, warn or error" yaml:"level"`
+	Format string `long:"format" env:"LOG_FORMAT" description:"log output format: text or json" yaml:"format"`
+}
+
+// CORS is disabled unless allowed origins are set
+type CORS struct {
+	AllowedOrigins []string      `long:"allowed-origin" env:"CORS_ALLOWED_ORIGINS" env-delim:"," description:"origins allowed to call the api, * allows any" yaml:"allowed_origins"`
+	AllowedMethods []string      `long:"allowed-method" env:"CORS_ALLOWED_METHODS" env-delim:"," description:"methods allowed in cross-origin requests" yaml:"allowed_methods"`
+	AllowedHeaders []string      `long:"allowed-header" env:"CORS_ALLOWED_HEADERS" env-delim:"," description:"headers allowed in cross-origin requests" yaml:"allowed_headers"`
+	ExposedHeaders []string      `long:"exposed-header" env:"CORS_EXPOSED_HEADERS" env-delim:"," description:"response headers readable by cross-origin clients" yaml:"exposed_headers"`
+	MaxAge         time.Duration `long:"max-age" env:"CORS_MAX_AGE" description:"how long preflight responses are cached" yaml:"max_age"`
+}
+
+// Enabled reports whether cross-origin requests are allowed
+func (c CORS) Enabled() bool {
+	return len(c.AllowedOrigins) > 0
+}
+
+// Cache is disabled when the backend is empty
+type Cache struct {
+	Backend   string        `long:"backend" env:"CACHE_BACKEND" description:"forecast cache backend: lru, redis or empty to disable it" yaml:"backend"`
+	LRUSize   int           `long:"lru-size" env:"CACHE_LRU_SIZE" description:"forecasts kept by the lru backend" yaml:"lru_size"`
+	RedisAddr string        `long:"redis-addr" env:"REDIS_ADDR" description:"address of the redis backend" yaml:"redis_addr"`
+	TTL       time.Duration `long:"ttl" env:"FORECAST_CACHE_TTL" description:"how long forecasts are cached" yaml:"ttl"`
+}
+
+// Retention keeps every resolution forever when its days are 0
+type Retention struct {
+	RawDays    int           `long:"raw-days" env:"RETENTION_RAW_DAYS" description:"days raw temperatures are kept" yaml:"raw_days"`
+	HourlyDays int           `long:"hourly-days" env:"RETENTION_HOURLY_DAYS" description:"days hourly rollups are kept" yaml:"hourly_days"`
+	DailyDays  int           `long:"daily-days" env:"RETENTION_DAILY_DAYS" description:"days daily rollups are kept" yaml:"daily_days"`
+	Interval   time.Duration `long:"interval" env:"RETENTION_INTERVAL" description:"how often the retention policy is applied" yaml:"interval"`
+}
+
+type Auth struct {
+	DefaultTenant       string `long:"default-tenant" env:"DEFAULT_TENANT" description:"tenant of requests without credentials, empty rejects them" yaml:"default_tenant"`
+	DefaultTenantScopes string `long:"default-tenant-scopes" env:"DEFAULT_TENANT_SCOPES" description:"space separated scopes granted to requests without credentials" yaml:"default_tenant_scopes"`
+	TrustTenantHeader   bool   `long:"trust-tenant-header" env:"TRUST_TENANT_HEADER" description:"trust the tenant sent in the X-Tenant header" yaml:"trust_tenant_header"`
+	OIDC                OIDC   `group:"OIDC" namespace:"oidc" yaml:"oidc"`
+}
+
+// OIDC validates bearer tokens when JWKS is set
+type OIDC struct {
+	JWKS        string        `long:"jwks" env:"OIDC_JWKS" description:"URL or file of the keys signing bearer tokens" yaml:"jwks"`
+	Issuer      string        `long:"issuer" env:"OIDC_ISSUER" description:"required token issuer" yaml:"issuer"`
+	Audience    string        `long:"audience" env:"OIDC_AUDIENCE" description:"required token audience" yaml:"audience"`
+	TenantClaim string        `long:"tenant-claim" env:"OIDC_TENANT_CLAIM" description:"claim holding the tenant name" yaml:"tenant_claim"`
+	ScopeClaim  string        `long:"scope-claim" env:"OIDC_SCOPE_CLAIM" description:"claim holding the granted scopes" yaml:"scope_claim"`
+	Leeway      time.Duration `long:"leeway" env:"OIDC_LEEWAY" description:"clock skew tolerated on token times" yaml:"leeway"`
+}
+
+type RateLimit struct {
+	PerMinute int    `long:"per-minute" env:"RATE_LIMIT_PER_MINUTE" description:"requests per minute allowed to a client on each route, 0 is unlimited" yaml:"per_minute"`
+	Routes    string `long:"routes" env:"RATE_LIMIT_ROUTES" description:"per route overrides e.g \"POST /temperatures=1200\"" yaml:"routes"`
+	PerIP     int    `long:"per-ip" env:"RATE_LIMIT_PER_IP" description:"requests per minute allowed to an IP on each route before authentication, whatever its credentials, 0 is unlimited" yaml:"per_ip"`
+}
+
+type Events struct {
+	Workers   int `long:"workers" env:"EVENTS_WORKERS" description:"listeners run concurrently" yaml:"workers"`
+	QueueSize int `long:"queue-size" env:"EVENTS_QUEUE_SIZE" description:"events queued before notifying blocks" yaml:"queue_size"`
+}
+
+type Webhooks struct {
+	Timeout time.Duration `long:"timeout" env:"WEBHOOK_TIMEOUT" description:"maximum duration of a webhook callback" yaml:"timeout"`
+}
+
+// Stream configures the live temperature streams
+type Stream struct {
+	Buffer           int           `long:"buffer" env:"STREAM_BUFFER" description:"temperatures waiting for a slow client before the overflow policy applies" yaml:"buffer"`
+	Overflow         string        `long:"overflow" env:"STREAM_OVERFLOW" description:"what happens once the buffer of a slow client is full: drop temperatures or disconnect the client" yaml:"overflow"`
+	History          int           `long:"history" env:"STREAM_HISTORY" description:"most recent temperatures of each city kept for clients resuming a stream" yaml:"history"`
+	HistoryRetention time.Duration `long:"history-retention" env:"STREAM_HISTORY_RETENTION" description:"how long the history of a city without clients is kept after its last temperature, 0 keeps it" yaml:"history_retention"`
+	Heartbeat        time.Duration `long:"heartbeat" env:"STREAM_HEARTBEAT" description:"how often idle streams send a heartbeat" yaml:"heartbeat"`
+}
+
+type Tracing struct {
+	Exporter   string  `long:"exporter" env:"TRACING_EXPORTER" description:"where spans are exported: noop, stdout or file" yaml:"exporter"`
+	File       string  `long:"file" env:"TRACING_FILE" description:"file spans are appended to by the file exporter" yaml:"file"`
+	SampleRate float64 `long:"sample-rate" env:"TRACING_SAMPLE_RATE" description:"share of requests traced, between 0 and 1" yaml:"sample_rate"`
+}
+
+type Features struct {
+	AutoMigrate bool `long:"auto-migrate" env:"AUTO_MIGRATE" description:"apply migrations on startup" yaml:"auto_migrate"`
+	Metrics     bool `long:"metrics" env:"FEATURE_METRICS" description:"serve prometheus metrics on /metrics" yaml:"metrics"`
+}
+
+// Default returns the configuration used for options that are not set
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Address:           "0.0.0.0:8080",
+			GRPCAddress:       "0.0.0.0:9090",
+			ReadHeaderTimeout: 10 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		API: API{
+			RootAliases:       true,
+			RootAliasesSunset: "2027-04-30",
+		},
+		Postgres: Postgres{
+			QueryTimeout:    5 * time.Second,
+			MaxOpenConns:    20,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "text",
+		},
+		CORS: CORS{
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+			AllowedHeaders: []string{"Authorization", "Content-Type", "X-Request-ID", "X-Tenant"},
+			ExposedHeaders: []string{"X-Request-ID", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After", "Deprecation", "Sunset", "Link"},
+			MaxAge:         12 * time.Hour,
+		},
+		Cache: Cache{
+			LRUSize: 1000,
+			TTL:     time.Minute,
+		},
+		Retention: Retention{
+			Interval: time.Hour,
+		},
+		Auth: Auth{
+			OIDC: OIDC{
+				TenantClaim: "tenant",
+				ScopeClaim:  "scope",
+				Leeway:      30 * time.Second,
+			},
+		},
+		Events: Events{
+			Workers:   10,
+			QueueSize: 1000,
+		},
+		Webhooks: Webhooks{
+			Timeout: 10 * time.Second,
+		},
+		Stream: Stream{
+			Buffer:           16,
+			Overflow:         "disconnect",
+			History:          100,
+			HistoryRetention: time.Hour,
+			Heartbeat:        15 * time.Second,
+		},
+		Tracing: Tracing{
+			Exporter:   "noop",
+			SampleRate: 1,
+		},
+		Features: Features{
+			Metrics: true,
+		},
+	}
+}
+
+// Load reads the configuration from args, the environment and the file given with --config or CONFIG_FILE.
+// Flags take precedence over the environment, which takes precedence over the file, which takes precedence
+// over the defaults. The loaded configuration is validated.
+func Load(args []string) (*Config, error) {
+	c := Default()
+
+	file, err := findFile(args)
+	if err != nil {
+		return nil, err
+	}
+	if file != "" {
+		err = c.readFile(file)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// options without a default tag keep the value read from the file unless set by a flag or the environment
+	parser := flags.NewParser(c, flags.HelpFlag|flags.PassDoubleDash)
+	parser.NamespaceDelimiter = "-"
+	ignoreEmptyEnv(parser.Group)
+	rest, err := parser.ParseArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.Errorf("unexpected arguments %v", rest)
+	}
+
+	return c, c.Validate()
+}
+
+// IsHelp reports whether err was returned by Load because help was requested, err then holds the usage
+func IsHelp(err error) bool {
+	flagsErr, ok := err.(*flags.Error)
+	return ok && flagsErr.Type == flags.ErrHelp
+}
+
+// findFile looks up the configuration file before the other options as they override it
+func findFile(args []string) (string, error) {
+	var options struct {
+		File string `long:"config" env:"CONFIG_FILE"`
+	}
+	_, err := flags.NewParser(&options, flags.IgnoreUnknown).ParseArgs(args)
+	return options.File, err
+}
+
+// ignoreEmptyEnv treats empty environment variables as unset, as left by blank entries of .env files
+func ignoreEmptyEnv(g *flags.Group) {
+	for _, opt := range g.Options() {
+		if value, ok := os.LookupEnv(opt.EnvDefaultKey); ok && value == "" {
+			opt.EnvDefaultKey = ""
+		}
+	}
+	for _, group := range g.Groups() {
+		ignoreEmptyEnv(group)
+	}
+}
+
+func (c *Config) readFile(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return errors.Wrap(err, "reading configuration file")
+	}
+
+	err = yaml.UnmarshalStrict(data, c)
+	if err != nil {
+		return errors.Wrapf(err, "parsing configuration file %s", file)
+	}
+	return nil
+}
+
+// String prints the configuration as YAML with secrets redacted
+func (c Config) String() string {
+	out, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return err.Error()
+	}
+	return string(out)
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/datastore/postgres"
+	"github.com/walez/weather-monster/events"
+	"github.com/walez/weather-monster/ratelimit"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// redacted replaces secrets when printing the configuration
+const redacted = "REDACTED"
+
+var passwordParam = regexp.MustCompile(`(password=)[^\s&]*`)
+
+// Validate returns an error listing every invalid option
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+	checkErr := func(err error, option string) {
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", option, err))
+		}
+	}
+	positive := func(d time.Duration, option string) {
+		check(d >= 0, "%s can not be negative", option)
+	}
+
+	check(c.Server.Address != "", "server.address is required")
+	check(c.Server.GRPCAddress == "" || c.Server.GRPCAddress != c.Server.Address, "server.grpc_address must differ from server.address")
+	positive(c.Server.ReadTimeout, "server.read_timeout")
+	positive(c.Server.ReadHeaderTimeout, "server.read_header_timeout")
+	positive(c.Server.WriteTimeout, "server.write_timeout")
+	positive(c.Server.IdleTimeout, "server.idle_timeout")
+	positive(c.Server.DrainDelay, "server.drain_delay")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	_, err := ratelimit.ParseProxies(c.Server.TrustedProxies)
+	checkErr(err, "server.trusted_proxies")
+
+	if c.API.RootAliases && c.API.RootAliasesSunset != "" {
+		_, err := time.Parse(SunsetLayout, c.API.RootAliasesSunset)
+		check(err == nil, "api.root_aliases_sunset must be a date formatted as YYYY-MM-DD, got %q", c.API.RootAliasesSunset)
+	}
+
+	check(c.Postgres.URI != "", "postgres.uri is required")
+	positive(c.Postgres.QueryTimeout, "postgres.query_timeout")
+	check(c.Postgres.MaxOpenConns >= 0, "postgres.max_open_conns can not be negative")
+	check(c.Postgres.MaxIdleConns >= 0, "postgres.max_idle_conns can not be negative")
+	check(c.Postgres.MaxOpenConns == 0 || c.Postgres.MaxIdleConns <= c.Postgres.MaxOpenConns,
+		"postgres.max_idle_conns can not exceed postgres.max_open_conns")
+	positive(c.Postgres.ConnMaxLifetime, "postgres.conn_max_lifetime")
+
+	_, err = log.ParseLevel(c.Log.Level)
+	checkErr(err, "log.level")
+	check(c.Log.Format == "text" || c.Log.Format == "json", "log.format must be text or json, got %q", c.Log.Format)
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		check(origin == "*" || strings.HasPrefix(origin, "http://") || strings.HasPrefix(origin, "https://"),
+			"cors.allowed_origins must be * or start with http:// or https://, got %q", origin)
+	}
+	check(!c.CORS.Enabled() || len(c.CORS.AllowedMethods) > 0, "cors.allowed_methods is required when cors is enabled")
+	positive(c.CORS.MaxAge, "cors.max_age")
+
+	switch c.Cache.Backend {
+	case "":
+	case "lru":
+		check(c.Cache.LRUSize > 0, "cache.lru_size must be positive")
+	case "redis":
+		check(c.Cache.RedisAddr != "", "cache.redis_addr is required by the redis backend")
+	default:
+		problems = append(problems, fmt.Sprintf("cache.backend must be lru, redis or empty, got %q", c.Cache.Backend))
+	}
+	check(c.Cache.Backend == "" || c.Cache.TTL > 0, "cache.ttl must be positive")
+
+	checkErr(c.Retention.Policy().Validate(), "retention")
+	check(c.Retention.Policy().IsZero() || c.Retention.Interval > 0, "retention.interval must be positive")
+
+	checkErr(core.ValidateScopes(c.Auth.DefaultTenantScopes), "auth.default_tenant_scopes")
+	positive(c.Auth.OIDC.Leeway, "auth.oidc.leeway")
+
+	check(c.RateLimit.PerMinute >= 0, "rate_limit.per_minute can not be negative")
+	check(c.RateLimit.PerIP >= 0, "rate_limit.per_ip can not be negative")
+	_, err = ratelimit.ParseRouteLimits(c.RateLimit.Routes)
+	checkErr(err, "rate_limit.routes")
+
+	check(c.Events.Workers > 0, "events.workers must be positive")
+	check(c.Events.QueueSize >= 0, "events.queue_size can not be negative")
+
+	check(c.Webhooks.Timeout > 0, "webhooks.timeout must be positive")
+
+	check(c.Stream.Buffer > 0, "stream.buffer must be positive")
+	_, err = events.ParseOverflowPolicy(c.Stream.Overflow)
+	checkErr(err, "stream.overflow")
+	check(c.Stream.History >= 0, "stream.history can not be negative")
+	check(c.Stream.HistoryRetention >= 0, "stream.history_retention can not be negative")
+	check(c.Stream.Heartbeat > 0, "stream.heartbeat must be positive")
+
+	switch c.Tracing.Exporter {
+	case "noop", "stdout":
+	case "file":
+		check(c.Tracing.File != "", "tracing.file is required by the file exporter")
+	default:
+		problems = append(problems, fmt.Sprintf("tracing.exporter must be noop, stdout or file, got %q", c.Tracing.Exporter))
+	}
+	check(c.Tracing.SampleRate >= 0 && c.Tracing.SampleRate <= 1, "tracing.sample_rate must be between 0 and 1")
+
+	if len(problems) > 0 {
+		return errors.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// Policy is the retention policy applied to temperatures
+func (r Retention) Policy() postgres.RetentionPolicy {
+	day := 24 * time.Hour
+	return postgres.RetentionPolicy{
+		Raw:    time.Duration(r.RawDays) * day,
+		Hourly: time.Duration(r.HourlyDays) * day,
+		Daily:  time.Duration(r.DailyDays) * day,
+	}
+}
+
+// Redacted returns a copy of the configuration with secrets replaced
+func (c Config) Redacted() Config {
+	c.Postgres.URI = redactURI(c.Postgres.URI)
+	c.Cache.RedisAddr = redactURI(c.Cache.RedisAddr)
+	return c
+}
+
+// redactURI hides the password of URLs and of key=value connection strings
+func redactURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			uri = u.String()
+		}
+	}
+	return passwordParam.ReplaceAllString(uri, "${1}"+redacted)
+}
@@ -0,0 +1,15 @@
+// Package cache provides read-through caching in front of the weather datastore
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Store is a key value store where every entry expires after its ttl
+type Store interface {
+	// Get returns the value stored at key, ok is false when the key is missing or expired
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,62 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/walez/weather-monster/datastore/cache"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should return stored value", func(t *testing.T) {
+		c := cache.NewLRU(2)
+		require.NoError(t, c.Set(ctx, "one", []byte("1"), time.Minute))
+
+		value, ok, err := c.Get(ctx, "one")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []byte("1"), value)
+	})
+
+	t.Run("should evict least recently used entry", func(t *testing.T) {
+		c := cache.NewLRU(2)
+		require.NoError(t, c.Set(ctx, "one", []byte("1"), time.Minute))
+		require.NoError(t, c.Set(ctx, "two", []byte("2"), time.Minute))
+
+		_, ok, _ := c.Get(ctx, "one")
+		require.True(t, ok)
+		require.NoError(t, c.Set(ctx, "three", []byte("3"), time.Minute))
+
+		_, ok, _ = c.Get(ctx, "two")
+		assert.False(t, ok)
+		_, ok, _ = c.Get(ctx, "one")
+		assert.True(t, ok)
+		assert.Equal(t, 2, c.Len())
+	})
+
+	t.Run("should not return expired entry", func(t *testing.T) {
+		c := cache.NewLRU(2)
+		require.NoError(t, c.Set(ctx, "one", []byte("1"), 10*time.Millisecond))
+		time.Sleep(20 * time.Millisecond)
+
+		_, ok, err := c.Get(ctx, "one")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, 0, c.Len())
+	})
+
+	t.Run("should delete entry", func(t *testing.T) {
+		c := cache.NewLRU(2)
+		require.NoError(t, c.Set(ctx, "one", []byte("1"), time.Minute))
+		require.NoError(t, c.Delete(ctx, "one"))
+
+		_, ok, _ := c.Get(ctx, "one")
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+// Redis is a Store backed by any server speaking the redis protocol, shared between api instances
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedis creates a store for the redis server at addr, keys are namespaced with prefix
+func NewRedis(addr string, prefix string) *Redis {
+	return &Redis{
+		client: redis.NewClient(&redis.Options{Addr: addr}),
+		prefix: prefix,
+	}
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.WithContext(ctx).Get(r.prefix + key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.WithContext(ctx).Set(r.prefix+key, value, ttl).Err()
+}
+
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	return r.client.WithContext(ctx).Del(r.prefix + key).Err()
+}
+
+// Ping checks the server is reachable
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.WithContext(ctx).Ping().Err()
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,61 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/walez/weather-monster/datastore/cache"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedis(t *testing.T) {
+	ctx := context.Background()
+
+	server, err := miniredis.Run()
+	require.NoError(t, err)
+	defer server.Close()
+
+	c := cache.NewRedis(server.Addr(), "test:")
+	defer c.Close()
+	require.NoError(t, c.Ping(ctx))
+
+	t.Run("should return stored value under prefixed key", func(t *testing.T) {
+		require.NoError(t, c.Set(ctx, "one", []byte("1"), time.Minute))
+
+		value, ok, err := c.Get(ctx, "one")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []byte("1"), value)
+		assert.True(t, server.Exists("test:one"))
+	})
+
+	t.Run("should not return expired entry", func(t *testing.T) {
+		require.NoError(t, c.Set(ctx, "two", []byte("2"), time.Minute))
+		server.FastForward(2 * time.Minute)
+
+		_, ok, err := c.Get(ctx, "two")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("should delete entry", func(t *testing.T) {
+		require.NoError(t, c.Set(ctx, "three", []byte("3"), time.Minute))
+		require.NoError(t, c.Delete(ctx, "three"))
+
+		_, ok, err := c.Get(ctx, "three")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("should return error when server is unreachable", func(t *testing.T) {
+		unreachable := cache.NewRedis("127.0.0.1:1", "test:")
+		defer unreachable.Close()
+
+		_, _, err := unreachable.Get(ctx, "one")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	core "github.com/walez/weather-monster"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// WeatherService decorates a core.WeatherService caching city forecasts for ttl
+// Cache failures are logged and the wrapped service is used instead
+type WeatherService struct {
+	core.WeatherService
+	store Store
+	ttl   time.Duration
+}
+
+func NewWeatherService(ws core.WeatherService, store Store, ttl time.Duration) *WeatherService {
+	return &WeatherService{
+		WeatherService: ws,
+		store:          store,
+		ttl:            ttl,
+	}
+}
+
+func (ws *WeatherService) GetCityForecast(ctx context.Context, cityID int64) (*core.Forecast, error) {
+	tenantID, ok := core.TenantFromContext(ctx)
+	if !ok {
+		return nil, core.ErrNoTenant
+	}
+	key := forecastKey(tenantID, cityID)
+
+	cached, ok, err := ws.store.Get(ctx, key)
+	if err != nil {
+		log.WithError(err).Warning("forecast cache: unable to read cached forecast")
+	}
+
+	if ok {
+		forecast := &core.Forecast{}
+		err := json.Unmarshal(cached, forecast)
+		if err == nil {
+			return forecast, nil
+		}
+		log.WithError(err).Warning("forecast cache: unable to decode cached forecast")
+	}
+
+	forecast, err := ws.WeatherService.GetCityForecast(ctx, cityID)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(forecast)
+	if err != nil {
+		return forecast, nil
+	}
+
+	err = ws.store.Set(ctx, key, encoded, ws.ttl)
+	if err != nil {
+		log.WithError(err).Warning("forecast cache: unable to cache forecast")
+	}
+	return forecast, nil
+}
+
+// InvalidateForecast drops the cached forecast of the temperature's city,
+// it is meant to be registered for events.TemperatureCreated
+func (ws *WeatherService) InvalidateForecast(ctx context.Context, temperature *core.Temperature) error {
+	err := ws.store.Delete(ctx, forecastKey(temperature.TenantID, temperature.CityID))
+	if err != nil {
+		return errors.Wrap(err, "forecast cache: unable to invalidate forecast")
+	}
+	return nil
+}
+
+func forecastKey(tenantID int64, cityID int64) string {
+	return "forecast:" + strconv.FormatInt(tenantID, 10) + ":" + strconv.FormatInt(cityID, 10)
+}
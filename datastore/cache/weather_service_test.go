@@ -0,0 +1,68 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/datastore/cache"
+	mocks "github.com/walez/weather-monster/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeatherService_GetCityForecast(t *testing.T) {
+	ctx := core.WithTenant(context.Background(), 1)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	forecast := &core.Forecast{CityID: 1, Max: 10.5, Min: 6.5, Sample: 2}
+	updated := &core.Forecast{CityID: 1, Max: 12, Min: 7, Sample: 3}
+
+	ws := mocks.NewMockWeatherService(mockCtrl)
+	gomock.InOrder(
+		ws.EXPECT().GetCityForecast(gomock.Any(), int64(1)).Return(forecast, nil),
+		ws.EXPECT().GetCityForecast(gomock.Any(), int64(1)).Return(updated, nil),
+	)
+	ws.EXPECT().GetCityForecast(gomock.Any(), int64(2)).Return(nil, errors.New("record not found")).Times(2)
+
+	service := cache.NewWeatherService(ws, cache.NewLRU(10), time.Minute)
+
+	t.Run("should serve repeated forecast from cache", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			got, err := service.GetCityForecast(ctx, 1)
+			require.NoError(t, err)
+			assert.Equal(t, forecast, got)
+		}
+	})
+
+	t.Run("should refetch forecast once city temperature is created", func(t *testing.T) {
+		err := service.InvalidateForecast(ctx, &core.Temperature{TenantID: 1, CityID: 1})
+		require.NoError(t, err)
+
+		got, err := service.GetCityForecast(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, updated, got)
+	})
+
+	t.Run("should not share cached forecasts between tenants", func(t *testing.T) {
+		other := core.WithTenant(context.Background(), 2)
+		ws.EXPECT().GetCityForecast(other, int64(1)).Return(&core.Forecast{CityID: 1}, nil)
+
+		got, err := service.GetCityForecast(other, 1)
+		require.NoError(t, err)
+		assert.Equal(t, &core.Forecast{CityID: 1}, got)
+	})
+
+	t.Run("should not cache errors", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			_, err := service.GetCityForecast(ctx, 2)
+			assert.Error(t, err)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	core "github.com/walez/weather-monster"
+)
+
+type APIKeyService struct {
+	client *Client
+}
+
+func NewAPIKeyService(ctx context.Context, client *Client) *APIKeyService {
+	return &APIKeyService{client: client}
+}
+
+func (ks *APIKeyService) FindAPIKeyByHash(ctx context.Context, hash string) (*core.APIKey, error) {
+	db, cancel := ks.client.WithContext(ctx)
+	defer cancel()
+
+	key := &core.APIKey{}
+	err := db.First(key, "hash = ? AND revoked_at IS NULL", hash).Error
+	return key, err
+}
+
+func (ks *APIKeyService) ListAPIKeys(ctx context.Context, tenantID int64) ([]*core.APIKey, error) {
+	db, cancel := ks.client.WithContext(ctx)
+	defer cancel()
+
+	query := db.Order("id")
+	if tenantID != 0 {
+		query = query.Where("tenant_id = ?", tenantID)
+	}
+
+	var keys []*core.APIKey
+	err := query.Find(&keys).Error
+	return keys, err
+}
+
+func (ks *APIKeyService) CreateAPIKey(ctx context.Context, key *core.APIKey) error {
+	db, cancel := ks.client.WithContext(ctx)
+	defer cancel()
+
+	return db.Create(key).Error
+}
+
+func (ks *APIKeyService) RevokeAPIKey(ctx context.Context, id int64) error {
+	db, cancel := ks.client.WithContext(ctx)
+	defer cancel()
+
+	res := db.Table("api_keys").Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", time.Now())
+	return affectedOne(res)
+}
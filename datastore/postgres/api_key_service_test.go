@@ -0,0 +1,58 @@
+package postgres_test
+
+import (
+	"context"
+	"testing"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/auth"
+	"github.com/walez/weather-monster/datastore/postgres"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyService(t *testing.T) {
+	requireDB(t)
+	ctx := context.Background()
+	service := postgres.NewAPIKeyService(ctx, client)
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	require.NoError(t, err)
+
+	apiKey := &core.APIKey{
+		TenantID: defaultTenant,
+		Name:     "sensor gateway",
+		Prefix:   prefix,
+		Hash:     hash,
+		Scopes:   core.ScopeTemperaturesWrite,
+	}
+	require.NoError(t, service.CreateAPIKey(ctx, apiKey))
+
+	t.Run("should find key by hash", func(t *testing.T) {
+		found, err := service.FindAPIKeyByHash(ctx, auth.HashAPIKey(key))
+		assert.NoError(t, err)
+		assert.Equal(t, apiKey.ID, found.ID)
+		assert.Equal(t, []string{core.ScopeTemperaturesWrite}, found.ScopeList())
+	})
+
+	t.Run("should list keys by tenant", func(t *testing.T) {
+		keys, err := service.ListAPIKeys(ctx, defaultTenant)
+		assert.NoError(t, err)
+		assert.Len(t, keys, 1)
+
+		keys, err = service.ListAPIKeys(ctx, defaultTenant+100)
+		assert.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("should not find revoked key", func(t *testing.T) {
+		require.NoError(t, service.RevokeAPIKey(ctx, apiKey.ID))
+
+		_, err := service.FindAPIKeyByHash(ctx, hash)
+		assert.EqualError(t, err, noRecordErr)
+
+		err = service.RevokeAPIKey(ctx, apiKey.ID)
+		assert.EqualError(t, err, noRecordErr, "a key is revoked once")
+	})
+}
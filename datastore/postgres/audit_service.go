@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+
+	core "github.com/walez/weather-monster"
+)
+
+// maxAuditEntries bounds a page of audit entries
+const maxAuditEntries = 100
+
+type AuditService struct {
+	client *Client
+}
+
+func NewAuditService(ctx context.Context, client *Client) *AuditService {
+	return &AuditService{client: client}
+}
+
+func (as *AuditService) CreateAuditEntry(ctx context.Context, entry *core.AuditEntry) error {
+	tenantID, err := tenantFrom(ctx)
+	if err != nil {
+		return err
+	}
+
+	db, cancel := as.client.WithContext(ctx)
+	defer cancel()
+
+	entry.TenantID = tenantID
+	return db.Create(entry).Error
+}
+
+func (as *AuditService) ListAuditEntries(ctx context.Context, filter core.AuditFilter) ([]*core.AuditEntry, error) {
+	tenantID, err := tenantFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db, cancel := as.client.WithContext(ctx)
+	defer cancel()
+
+	query := db.Where("tenant_id = ?", tenantID)
+	if filter.Entity != "" {
+		query = query.Where("entity = ?", filter.Entity)
+	}
+	if filter.EntityID != 0 {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Cursor != 0 {
+		query = query.Where("id < ?", filter.Cursor)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > maxAuditEntries {
+		limit = maxAuditEntries
+	}
+
+	var entries []*core.AuditEntry
+	err = query.Order("id DESC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
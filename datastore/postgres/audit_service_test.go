@@ -0,0 +1,67 @@
+package postgres_test
+
+import (
+	"context"
+	"testing"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/datastore/postgres"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditService(t *testing.T) {
+	requireDB(t)
+	ctx := context.Background()
+	tenantCtx := core.WithTenant(ctx, defaultTenant)
+	service := postgres.NewAuditService(ctx, client)
+
+	for i, action := range []string{core.AuditCreate, core.AuditUpdate, core.AuditDelete} {
+		after, err := core.MarshalJSONValue(&core.City{ID: 70, Name: "Audited"})
+		require.NoError(t, err)
+		if action == core.AuditDelete {
+			after = nil
+		}
+
+		require.NoError(t, service.CreateAuditEntry(tenantCtx, &core.AuditEntry{
+			Actor:     "api_key:1",
+			Action:    action,
+			Entity:    core.AuditEntityCity,
+			EntityID:  70 + int64(i%2),
+			After:     after,
+			RequestID: "request-1",
+		}))
+	}
+
+	t.Run("should require a tenant", func(t *testing.T) {
+		_, err := service.ListAuditEntries(ctx, core.AuditFilter{})
+		assert.Equal(t, core.ErrNoTenant, err)
+	})
+
+	t.Run("should filter by entity", func(t *testing.T) {
+		entries, err := service.ListAuditEntries(tenantCtx, core.AuditFilter{Entity: core.AuditEntityCity, EntityID: 70})
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, core.AuditDelete, entries[0].Action, "most recent first")
+		assert.Nil(t, entries[0].After)
+		assert.JSONEq(t, `{"id": 70, "name": "Audited", "latitude": 0, "longitude": 0}`, string(entries[1].After))
+	})
+
+	t.Run("should paginate with cursor", func(t *testing.T) {
+		page, err := service.ListAuditEntries(tenantCtx, core.AuditFilter{Entity: core.AuditEntityCity, Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, page, 2)
+
+		next, err := service.ListAuditEntries(tenantCtx, core.AuditFilter{Entity: core.AuditEntityCity, Limit: 2, Cursor: page[1].ID})
+		require.NoError(t, err)
+		require.Len(t, next, 1)
+		assert.Equal(t, core.AuditCreate, next[0].Action)
+	})
+
+	t.Run("should not list other tenant entries", func(t *testing.T) {
+		entries, err := service.ListAuditEntries(core.WithTenant(ctx, defaultTenant+100), core.AuditFilter{})
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
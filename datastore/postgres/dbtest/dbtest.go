@@ -2,23 +2,54 @@ package dbtest
 
 import (
 	"context"
+	"database/sql"
 
-	core "github.com/walez/weather-monster"
 	"github.com/walez/weather-monster/datastore/postgres"
+	"github.com/walez/weather-monster/datastore/postgres/migrations"
+
+	log "github.com/sirupsen/logrus"
 )
 
 // Package dbtest allows connect to the running postgres instance
 // It setups up a different database to be used for unit/integration testing
 
+// Ping checks the instance at uri accepts connections, so that tests needing it are skipped without one
+func Ping(ctx context.Context, uri string) error {
+	db, err := sql.Open("postgres", uri)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.PingContext(ctx)
+}
+
 // NewTestDatabase returns a db instance pointing to the test db name
+// The schema is created with the same migrations applied in production
 func NewTestDatabase(ctx context.Context, uri string) *postgres.Client {
 	client := postgres.New(ctx, uri)
-	client.DB().AutoMigrate(&core.City{}, &core.Temperature{}, &core.Webhook{})
+
+	migrator, err := postgres.NewMigrator(client, migrations.FS)
+	if err != nil {
+		log.Panicf("Loading test migrations, err=%v", err)
+	}
+
+	_, err = migrator.Up(ctx)
+	if err != nil {
+		log.Panicf("Migrating test database, err=%v", err)
+	}
 	return client
 }
 
 // Stop drops the database and disconnects from the instance
 func Stop(ctx context.Context, client *postgres.Client) error {
-	client.DB().DropTableIfExists(&core.Webhook{}, &core.Temperature{}, &core.City{})
+	migrator, err := postgres.NewMigrator(client, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	_, err = migrator.Down(ctx, 0)
+	if err != nil {
+		return err
+	}
 	return client.Close()
 }
@@ -0,0 +1,240 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// migrationsTable matches the table golang-migrate uses so databases migrated with its CLI keep working
+const migrationsTable = "schema_migrations"
+
+// migrationLockID is an arbitrary key for the advisory lock serialising concurrent migrators
+const migrationLockID = 7241902
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a single versioned schema change
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied to the database
+type MigrationStatus struct {
+	Version uint64
+	Name    string
+	Applied bool
+}
+
+// Migrator applies versioned SQL migrations
+type Migrator struct {
+	client     *Client
+	migrations []Migration
+}
+
+// NewMigrator loads the migrations found at the root of source
+func NewMigrator(client *Client, source fs.FS) (*Migrator, error) {
+	files, err := fs.ReadDir(source, ".")
+	if err != nil {
+		return nil, errors.Wrap(err, "migrate: unable to read migrations")
+	}
+
+	byVersion := map[uint64]*Migration{}
+	for _, f := range files {
+		match := migrationFile.FindStringSubmatch(f.Name())
+		if f.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "migrate: invalid version in %s", f.Name())
+		}
+
+		content, err := fs.ReadFile(source, f.Name())
+		if err != nil {
+			return nil, errors.Wrapf(err, "migrate: unable to read %s", f.Name())
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return &Migrator{client: client, migrations: migrations}, nil
+}
+
+// Up applies every pending migration and returns how many were applied
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if migration.Version <= current {
+				continue
+			}
+
+			log.Infof("migrate: applying %d_%s", migration.Version, migration.Name)
+			err := apply(ctx, conn, migration.Up, migration.Version)
+			if err != nil {
+				return errors.Wrapf(err, "migrate: applying %d_%s", migration.Version, migration.Name)
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts up to steps applied migrations, all of them when steps is not positive
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	reverted := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if migration.Version > current {
+				continue
+			}
+			if steps > 0 && reverted == steps {
+				break
+			}
+
+			var previous uint64
+			if i > 0 {
+				previous = m.migrations[i-1].Version
+			}
+
+			log.Infof("migrate: reverting %d_%s", migration.Version, migration.Name)
+			err := apply(ctx, conn, migration.Down, previous)
+			if err != nil {
+				return errors.Wrapf(err, "migrate: reverting %d_%s", migration.Version, migration.Name)
+			}
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every known migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			statuses = append(statuses, MigrationStatus{
+				Version: migration.Version,
+				Name:    migration.Name,
+				Applied: migration.Version <= current,
+			})
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+func (m *Migrator) withLock(ctx context.Context, f func(conn *sql.Conn) error) error {
+	conn, err := m.client.db.DB().Conn(ctx)
+	if err != nil {
+		return errors.Wrap(err, "migrate: unable to acquire connection")
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID)
+	if err != nil {
+		return errors.Wrap(err, "migrate: unable to acquire lock")
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	_, err = conn.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)",
+		migrationsTable,
+	))
+	if err != nil {
+		return errors.Wrap(err, "migrate: unable to create migrations table")
+	}
+
+	return f(conn)
+}
+
+// currentVersion returns the last applied migration version, zero when none has been applied
+func currentVersion(ctx context.Context, conn *sql.Conn) (uint64, error) {
+	var version uint64
+	var dirty bool
+	err := conn.QueryRowContext(ctx, fmt.Sprintf("SELECT version, dirty FROM %s LIMIT 1", migrationsTable)).Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "migrate: unable to read current version")
+	}
+	if dirty {
+		return 0, errors.Errorf("migrate: database is dirty at version %d, fix it manually", version)
+	}
+	return version, nil
+}
+
+// apply runs a migration script and records the resulting version in one transaction
+func apply(ctx context.Context, conn *sql.Conn, script string, version uint64) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s", migrationsTable)); err != nil {
+		return err
+	}
+
+	if version > 0 {
+		_, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (version, dirty) VALUES ($1, false)", migrationsTable), version)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,246 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// migrationsDB is a database/sql connector keeping the version and the scripts committed by the migrator,
+// so that it is tested without postgres. Scripts containing FAIL fail like an invalid statement.
+type migrationsDB struct {
+	mu      sync.Mutex
+	version int64
+	scripts []string
+}
+
+func (d *migrationsDB) Connect(ctx context.Context) (driver.Conn, error) {
+	return &migrationsConn{db: d}, nil
+}
+
+func (d *migrationsDB) Driver() driver.Driver {
+	return nil
+}
+
+// committed returns the version and the scripts committed so far
+func (d *migrationsDB) committed() (int64, []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.version, append([]string(nil), d.scripts...)
+}
+
+// migrationsConn keeps the statements of a transaction until it is committed
+type migrationsConn struct {
+	db      *migrationsDB
+	tx      bool
+	version int64
+	scripts []string
+}
+
+func (c *migrationsConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *migrationsConn) Close() error {
+	return nil
+}
+
+func (c *migrationsConn) Begin() (driver.Tx, error) {
+	c.db.mu.Lock()
+	c.tx, c.version, c.scripts = true, c.db.version, nil
+	c.db.mu.Unlock()
+	return c, nil
+}
+
+func (c *migrationsConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.version = c.version
+	c.db.scripts = append(c.db.scripts, c.scripts...)
+	c.tx = false
+	return nil
+}
+
+func (c *migrationsConn) Rollback() error {
+	c.tx = false
+	return nil
+}
+
+func (c *migrationsConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	switch {
+	case strings.HasPrefix(query, "SELECT pg_advisory"), strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS "+migrationsTable):
+		return driver.ResultNoRows, nil
+	case !c.tx:
+		return nil, errors.New("migrations are applied in transactions")
+	case query == "DELETE FROM "+migrationsTable:
+		c.version = 0
+	case strings.HasPrefix(query, "INSERT INTO "+migrationsTable):
+		c.version = args[0].Value.(int64)
+	case strings.Contains(query, "FAIL"):
+		return nil, errors.New("syntax error")
+	default:
+		c.scripts = append(c.scripts, query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *migrationsConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	return &versionRows{version: c.db.version, done: c.db.version == 0}, nil
+}
+
+// versionRows answers the version recorded in the migrations table, no row when none was applied
+type versionRows struct {
+	version int64
+	done    bool
+}
+
+func (r *versionRows) Columns() []string {
+	return []string{"version", "dirty"}
+}
+
+func (r *versionRows) Close() error {
+	return nil
+}
+
+func (r *versionRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0], dest[1] = r.version, false
+	return nil
+}
+
+// newTestMigrator returns a migrator of the migrations in files applied to db
+func newTestMigrator(t *testing.T, db *migrationsDB, files fstest.MapFS) *Migrator {
+	conn, err := gorm.Open(dialect, sql.OpenDB(db))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	m, err := NewMigrator(&Client{db: conn}, files)
+	require.NoError(t, err)
+	return m
+}
+
+func migrationFiles(scripts map[string]string) fstest.MapFS {
+	files := fstest.MapFS{"readme.md": {Data: []byte("not a migration")}}
+	for name, script := range scripts {
+		files[name] = &fstest.MapFile{Data: []byte(script)}
+	}
+	return files
+}
+
+func TestMigrator_Up(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should apply migrations in version order", func(t *testing.T) {
+		db := &migrationsDB{}
+		m := newTestMigrator(t, db, migrationFiles(map[string]string{
+			"10_third.up.sql":   "third",
+			"2_second.up.sql":   "second",
+			"1_first.up.sql":    "first",
+			"1_first.down.sql":  "revert first",
+			"2_second.down.sql": "revert second",
+		}))
+
+		applied, err := m.Up(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 3, applied)
+
+		version, scripts := db.committed()
+		assert.Equal(t, int64(10), version)
+		assert.Equal(t, []string{"first", "second", "third"}, scripts)
+	})
+
+	t.Run("should only apply the migrations after the current version", func(t *testing.T) {
+		db := &migrationsDB{}
+		files := migrationFiles(map[string]string{"1_first.up.sql": "first", "2_second.up.sql": "second"})
+
+		_, err := newTestMigrator(t, db, files).Up(ctx)
+		require.NoError(t, err)
+
+		applied, err := newTestMigrator(t, db, files).Up(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, applied, "applied migrations are not applied again")
+
+		files["3_third.up.sql"] = &fstest.MapFile{Data: []byte("third")}
+		applied, err = newTestMigrator(t, db, files).Up(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, applied)
+
+		version, scripts := db.committed()
+		assert.Equal(t, int64(3), version)
+		assert.Equal(t, []string{"first", "second", "third"}, scripts)
+	})
+
+	t.Run("should roll back a failed migration and stop", func(t *testing.T) {
+		db := &migrationsDB{}
+		files := migrationFiles(map[string]string{
+			"1_first.up.sql":  "first",
+			"2_second.up.sql": "second; FAIL",
+			"3_third.up.sql":  "third",
+		})
+
+		applied, err := newTestMigrator(t, db, files).Up(ctx)
+		assert.EqualError(t, err, "migrate: applying 2_second: syntax error")
+		assert.Equal(t, 1, applied)
+
+		version, scripts := db.committed()
+		assert.Equal(t, int64(1), version, "the version of the failed migration is not recorded")
+		assert.Equal(t, []string{"first"}, scripts)
+
+		files["2_second.up.sql"] = &fstest.MapFile{Data: []byte("second")}
+		applied, err = newTestMigrator(t, db, files).Up(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, applied, "the failed migration is applied again once fixed")
+
+		version, scripts = db.committed()
+		assert.Equal(t, int64(3), version)
+		assert.Equal(t, []string{"first", "second", "third"}, scripts)
+	})
+}
+
+func TestMigrator_Down(t *testing.T) {
+	ctx := context.Background()
+	db := &migrationsDB{}
+	m := newTestMigrator(t, db, migrationFiles(map[string]string{
+		"1_first.up.sql":    "first",
+		"1_first.down.sql":  "revert first",
+		"2_second.up.sql":   "second",
+		"2_second.down.sql": "revert second",
+	}))
+
+	_, err := m.Up(ctx)
+	require.NoError(t, err)
+
+	reverted, err := m.Down(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, reverted)
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []MigrationStatus{
+		{Version: 1, Name: "first", Applied: true},
+		{Version: 2, Name: "second", Applied: false},
+	}, statuses)
+
+	reverted, err = m.Down(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, reverted)
+
+	version, scripts := db.committed()
+	assert.Zero(t, version)
+	assert.Equal(t, []string{"first", "second", "revert second", "revert first"}, scripts)
+}
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS temperatures_city_id_timestamp_idx;
+DROP INDEX IF EXISTS webhooks_city_id_idx;
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS temperatures_city_id_timestamp_idx ON temperatures (city_id, timestamp);
+CREATE INDEX IF NOT EXISTS webhooks_city_id_idx ON webhooks (city_id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS temperature_rollups(
+   city_id     integer NOT NULL REFERENCES cities (id),
+   bucket      integer NOT NULL,
+   max_sum     bigint NOT NULL,
+   min_sum     bigint NOT NULL,
+   sample      bigint NOT NULL,
+   highest     integer NOT NULL,
+   lowest      integer NOT NULL,
+   PRIMARY KEY (city_id, bucket)
+);
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS webhooks_tenant_id_city_id_idx;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS tenant_id;
+
+ALTER TABLE temperatures DROP COLUMN IF EXISTS tenant_id;
+
+DROP INDEX IF EXISTS cities_tenant_id_name_idx;
+ALTER TABLE cities DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE cities ADD CONSTRAINT cities_name_key UNIQUE (name);
+
+DROP TABLE IF EXISTS tenants;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS tenants(
+   id SERIAL PRIMARY KEY,
+   name VARCHAR (300) UNIQUE NOT NULL
+);
+
+-- existing data belongs to the default tenant
+INSERT INTO tenants (id, name) VALUES (1, 'default') ON CONFLICT DO NOTHING;
+SELECT setval('tenants_id_seq', (SELECT MAX(id) FROM tenants));
+
+ALTER TABLE cities ADD COLUMN tenant_id integer NOT NULL DEFAULT 1 REFERENCES tenants (id);
+ALTER TABLE cities ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE cities DROP CONSTRAINT IF EXISTS cities_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS cities_tenant_id_name_idx ON cities (tenant_id, name);
+
+ALTER TABLE temperatures ADD COLUMN tenant_id integer NOT NULL DEFAULT 1 REFERENCES tenants (id);
+ALTER TABLE temperatures ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE webhooks ADD COLUMN tenant_id integer NOT NULL DEFAULT 1 REFERENCES tenants (id);
+ALTER TABLE webhooks ALTER COLUMN tenant_id DROP DEFAULT;
+CREATE INDEX IF NOT EXISTS webhooks_tenant_id_city_id_idx ON webhooks (tenant_id, city_id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS api_keys(
+   id SERIAL PRIMARY KEY,
+   tenant_id   integer NOT NULL REFERENCES tenants (id),
+   name        VARCHAR (300) NOT NULL,
+   prefix      VARCHAR (32) NOT NULL,
+   hash        CHAR (64) UNIQUE NOT NULL,
+   scopes      TEXT NOT NULL DEFAULT '',
+   created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+   revoked_at  TIMESTAMPTZ
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS tenant_usages;
+
+ALTER TABLE tenants DROP COLUMN IF EXISTS daily_temperature_quota;
@@ -0,0 +1,8 @@
+ALTER TABLE tenants ADD COLUMN daily_temperature_quota bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS tenant_usages(
+   tenant_id    integer NOT NULL REFERENCES tenants (id),
+   day          DATE NOT NULL,
+   temperatures bigint NOT NULL DEFAULT 0,
+   PRIMARY KEY (tenant_id, day)
+);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS audit_entries(
+   id SERIAL PRIMARY KEY,
+   tenant_id   integer NOT NULL REFERENCES tenants (id),
+   actor       VARCHAR (300) NOT NULL,
+   action      VARCHAR (32) NOT NULL,
+   entity      VARCHAR (32) NOT NULL,
+   entity_id   bigint NOT NULL,
+   before      JSONB,
+   after       JSONB,
+   request_id  VARCHAR (128) NOT NULL DEFAULT '',
+   created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS audit_entries_tenant_id_entity_idx ON audit_entries (tenant_id, entity, entity_id, id DESC);
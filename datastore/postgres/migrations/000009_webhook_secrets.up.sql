@@ -0,0 +1 @@
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS secret VARCHAR (64) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- existing webhooks keep receiving the payload they were built against
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS payload_version integer NOT NULL DEFAULT 1;
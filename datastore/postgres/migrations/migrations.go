@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL schema migrations so they ship with the binary
+package migrations
+
+import "embed"
+
+// FS holds the up and down migrations named using the golang-migrate convention
+// e.g 000001_initial_schema.up.sql
+//
+//go:embed *.sql
+var FS embed.FS
@@ -2,29 +2,63 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"time"
+
+	"github.com/walez/weather-monster/logging"
 
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 	log "github.com/sirupsen/logrus"
 )
 
+const dialect = "postgres"
+
 type Client struct {
-	db *gorm.DB
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+// Option configures optional behaviour of the postgres client
+type Option func(*Client)
+
+// WithQueryTimeout bounds how long a single query may run, a zero value disables the timeout
+func WithQueryTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.queryTimeout = timeout
+	}
+}
+
+// WithPool bounds the open and idle connections and how long a connection is reused, zero values are unlimited
+func WithPool(maxOpen int, maxIdle int, maxLifetime time.Duration) Option {
+	return func(c *Client) {
+		c.db.DB().SetMaxOpenConns(maxOpen)
+		c.db.DB().SetMaxIdleConns(maxIdle)
+		c.db.DB().SetConnMaxLifetime(maxLifetime)
+	}
 }
 
 // New is a postgress database constructor
 func New(
 	ctx context.Context,
 	uri string,
+	opts ...Option,
 ) *Client {
 
-	db, err := gorm.Open("postgres", uri)
+	db, err := gorm.Open(dialect, uri)
 	if err != nil {
 		log.Panicf("Creating postgres connection, err=%v", err)
 	}
 
+	c := &Client{db: db}
+	for _, opt := range opts {
+		opt(c)
+	}
+
 	log.Info("Connected to postgres")
-	return &Client{db: db}
+	return c
 }
 
 func (c *Client) Close() error {
@@ -34,3 +68,147 @@ func (c *Client) Close() error {
 func (c *Client) DB() *gorm.DB {
 	return c.db
 }
+
+// Ping verifies a connection to the database can be established
+func (c *Client) Ping(ctx context.Context) error {
+	return c.db.DB().PingContext(ctx)
+}
+
+// WithContext returns a gorm handle whose statements are bound to ctx and the configured query timeout.
+// The returned cancel func must be called once the query results have been consumed.
+func (c *Client) WithContext(ctx context.Context) (*gorm.DB, context.CancelFunc) {
+	ctx, cancel := c.queryContext(ctx)
+	return bind(ctx, c.db.DB()), cancel
+}
+
+// Transaction runs f in a transaction bound to ctx and the configured query timeout,
+// the transaction is committed when f returns nil and rolled back otherwise
+func (c *Client) Transaction(ctx context.Context, f func(db *gorm.DB) error) error {
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+
+	tx, err := c.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = f(bind(ctx, tx))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (c *Client) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.queryTimeout > 0 {
+		return context.WithTimeout(ctx, c.queryTimeout)
+	}
+	return ctx, func() {}
+}
+
+func bind(ctx context.Context, db executor) *gorm.DB {
+	bound, err := gorm.Open(dialect, &contextDB{ctx: ctx, db: db})
+	if err != nil {
+		// gorm only fails to open a SQLCommon source of an unknown type
+		log.Panicf("Binding postgres connection to context, err=%v", err)
+	}
+	bound.SetLogger(queryLogger{ctx: ctx})
+	return bound
+}
+
+// queryLogger logs the statements run in debug mode, e.g with db.Debug(), with the request logger of ctx.
+// The values bound to statements are left out as they may hold secrets.
+type queryLogger struct {
+	ctx context.Context
+}
+
+func (l queryLogger) Print(values ...interface{}) {
+	if len(values) < 3 {
+		return
+	}
+
+	entry := logging.FromContext(l.ctx).WithField("caller", values[1])
+	if values[0] == "sql" && len(values) == 6 {
+		duration, _ := values[2].(time.Duration)
+		entry.WithFields(log.Fields{
+			"duration_ms": duration.Milliseconds(),
+			"rows":        values[5],
+		}).Debugf("postgres: %v", values[3])
+		return
+	}
+
+	// failed statements are reported to the caller, only trace them
+	entry.Debugf("postgres: %v", values[2:])
+}
+
+// executor is implemented by both *sql.DB and *sql.Tx
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// contextDB implements gorm.SQLCommon issuing every statement with a fixed context
+// so that cancelling the context aborts in-flight queries
+type contextDB struct {
+	ctx context.Context
+	db  executor
+}
+
+func (c *contextDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	span := c.trace(query)
+	defer span.Finish()
+
+	res, err := c.db.ExecContext(c.ctx, query, args...)
+	traceError(span, err)
+	return res, err
+}
+
+func (c *contextDB) Prepare(query string) (*sql.Stmt, error) {
+	span := c.trace(query)
+	defer span.Finish()
+
+	stmt, err := c.db.PrepareContext(c.ctx, query)
+	traceError(span, err)
+	return stmt, err
+}
+
+func (c *contextDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	span := c.trace(query)
+	defer span.Finish()
+
+	rows, err := c.db.QueryContext(c.ctx, query, args...)
+	traceError(span, err)
+	return rows, err
+}
+
+func (c *contextDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	span := c.trace(query)
+	defer span.Finish()
+
+	return c.db.QueryRowContext(c.ctx, query, args...)
+}
+
+// trace starts a span for a statement when the context is traced, e.g by a request or a listener
+func (c *contextDB) trace(query string) opentracing.Span {
+	parent := opentracing.SpanFromContext(c.ctx)
+	if parent == nil {
+		return opentracing.NoopTracer{}.StartSpan("")
+	}
+
+	span := parent.Tracer().StartSpan("postgres.query", opentracing.ChildOf(parent.Context()))
+	ext.SpanKindRPCClient.Set(span)
+	ext.DBType.Set(span, "sql")
+	ext.DBInstance.Set(span, dialect)
+	ext.DBStatement.Set(span, query)
+	return span
+}
+
+func traceError(span opentracing.Span, err error) {
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogKV("error", err.Error())
+	}
+}
@@ -0,0 +1,142 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+// dailyInterval is the width in seconds of a temperature_daily_rollups bucket
+const dailyInterval = int64(24 * time.Hour / time.Second)
+
+// retentionLockID is an arbitrary key preventing concurrent retention runs across instances
+const retentionLockID = 7241903
+
+// compactHourlyRollups folds hourly rollups older than the cutoff into daily rollups
+const compactHourlyRollups = `
+INSERT INTO temperature_daily_rollups (city_id, bucket, max_sum, min_sum, sample, highest, lowest)
+SELECT city_id, bucket - bucket % ?, SUM(max_sum), SUM(min_sum), SUM(sample), MAX(highest), MIN(lowest)
+FROM temperature_rollups
+WHERE bucket < ?
+GROUP BY 1, 2
+ON CONFLICT (city_id, bucket) DO UPDATE SET
+	max_sum = temperature_daily_rollups.max_sum + EXCLUDED.max_sum,
+	min_sum = temperature_daily_rollups.min_sum + EXCLUDED.min_sum,
+	sample = temperature_daily_rollups.sample + EXCLUDED.sample,
+	highest = GREATEST(temperature_daily_rollups.highest, EXCLUDED.highest),
+	lowest = LEAST(temperature_daily_rollups.lowest, EXCLUDED.lowest)`
+
+// RetentionPolicy sets how long readings are kept at each resolution, a zero duration keeps them forever.
+// Raw readings are already summarised in hourly rollups so they are simply deleted once expired,
+// hourly rollups are compacted into daily rollups and daily rollups are deleted.
+type RetentionPolicy struct {
+	Raw    time.Duration
+	Hourly time.Duration
+	Daily  time.Duration
+}
+
+// RetentionResult counts the rows affected by a retention run
+type RetentionResult struct {
+	RawDeleted      int64
+	HourlyCompacted int64
+	DailyDeleted    int64
+}
+
+// IsZero reports whether the policy keeps everything forever
+func (p RetentionPolicy) IsZero() bool {
+	return p.Raw == 0 && p.Hourly == 0 && p.Daily == 0
+}
+
+// Validate ensures forecasts can still be served and every resolution outlives the finer one
+func (p RetentionPolicy) Validate() error {
+	if p.Raw < 0 || p.Hourly < 0 || p.Daily < 0 {
+		return errors.New("retention: durations can not be negative")
+	}
+
+	// forecasts read the last 24 hours from raw readings and hourly rollups
+	if p.Raw != 0 && p.Raw < 24*time.Hour {
+		return errors.New("retention: raw readings must be kept for at least 24h")
+	}
+
+	if p.Hourly != 0 && (p.Raw == 0 || p.Hourly < p.Raw) {
+		return errors.New("retention: hourly rollups must be kept at least as long as raw readings")
+	}
+
+	if p.Daily != 0 && (p.Hourly == 0 || p.Daily < p.Hourly) {
+		return errors.New("retention: daily rollups must be kept at least as long as hourly rollups")
+	}
+	return nil
+}
+
+// cutoffs are the unix timestamps, aligned to days, before which each resolution is dropped, zero when kept forever
+type cutoffs struct {
+	raw    int64
+	hourly int64
+	daily  int64
+}
+
+func (p RetentionPolicy) cutoffs(now time.Time) cutoffs {
+	cutoff := func(d time.Duration) int64 {
+		if d == 0 {
+			return 0
+		}
+		return floor(now.Add(-d).Unix(), dailyInterval)
+	}
+
+	return cutoffs{
+		raw:    cutoff(p.Raw),
+		hourly: cutoff(p.Hourly),
+		daily:  cutoff(p.Daily),
+	}
+}
+
+// ApplyRetention deletes and compacts readings past the configured retention policy.
+// When another instance is already applying it the run is skipped and a nil result returned.
+func (ws *WeatherService) ApplyRetention(ctx context.Context, now time.Time) (*RetentionResult, error) {
+	c := ws.retention.cutoffs(now)
+	result := &RetentionResult{}
+	locked := true
+
+	err := ws.client.Transaction(ctx, func(db *gorm.DB) error {
+		err := db.Raw("SELECT pg_try_advisory_xact_lock(?)", retentionLockID).Row().Scan(&locked)
+		if err != nil || !locked {
+			return err
+		}
+
+		if c.raw != 0 {
+			res := db.Exec("DELETE FROM temperatures WHERE timestamp < ?", c.raw)
+			if res.Error != nil {
+				return errors.Wrap(res.Error, "retention: deleting raw readings")
+			}
+			result.RawDeleted = res.RowsAffected
+		}
+
+		if c.hourly != 0 {
+			err := db.Exec(compactHourlyRollups, dailyInterval, c.hourly).Error
+			if err != nil {
+				return errors.Wrap(err, "retention: compacting hourly rollups")
+			}
+
+			res := db.Exec("DELETE FROM temperature_rollups WHERE bucket < ?", c.hourly)
+			if res.Error != nil {
+				return errors.Wrap(res.Error, "retention: deleting hourly rollups")
+			}
+			result.HourlyCompacted = res.RowsAffected
+		}
+
+		if c.daily != 0 {
+			res := db.Exec("DELETE FROM temperature_daily_rollups WHERE bucket < ?", c.daily)
+			if res.Error != nil {
+				return errors.Wrap(res.Error, "retention: deleting daily rollups")
+			}
+			result.DailyDeleted = res.RowsAffected
+		}
+		return nil
+	})
+	if err != nil || !locked {
+		return nil, err
+	}
+	return result, nil
+}
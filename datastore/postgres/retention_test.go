@@ -0,0 +1,131 @@
+package postgres_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/datastore/postgres"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetentionPolicyValidate(t *testing.T) {
+	day := 24 * time.Hour
+
+	type test struct {
+		summary   string
+		input     postgres.RetentionPolicy
+		shouldErr bool
+	}
+
+	tests := []test{
+		{
+			summary: "should accept keeping everything forever",
+			input:   postgres.RetentionPolicy{},
+		},
+		{
+			summary: "should accept increasing retention per resolution",
+			input:   postgres.RetentionPolicy{Raw: 7 * day, Hourly: 30 * day, Daily: 365 * day},
+		},
+		{
+			summary:   "should reject raw retention shorter than a forecast",
+			input:     postgres.RetentionPolicy{Raw: time.Hour},
+			shouldErr: true,
+		},
+		{
+			summary:   "should reject hourly retention shorter than raw retention",
+			input:     postgres.RetentionPolicy{Raw: 7 * day, Hourly: 2 * day},
+			shouldErr: true,
+		},
+		{
+			summary:   "should reject daily retention without hourly retention",
+			input:     postgres.RetentionPolicy{Raw: 7 * day, Daily: 30 * day},
+			shouldErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.summary, func(t *testing.T) {
+			err := tc.input.Validate()
+			if tc.shouldErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	requireDB(t)
+	ctx := core.WithTenant(context.Background(), defaultTenant)
+	day := 24 * time.Hour
+
+	city := &core.City{
+		ID:       50,
+		TenantID: defaultTenant,
+		Name:     "City Fifty",
+	}
+	err := client.DB().Create(city).Error
+	require.NoError(t, err)
+
+	now := time.Now()
+	for i := 0; i < 120; i++ {
+		temperature := &core.Temperature{
+			ID:        int64(600 + i),
+			TenantID:  defaultTenant,
+			CityID:    city.ID,
+			Max:       20 + i%7,
+			Min:       i % 5,
+			Timestamp: now.Add(-time.Duration(i) * 2 * time.Hour).Unix(),
+		}
+		err := client.DB().Create(temperature).Error
+		require.NoError(t, err)
+	}
+
+	_, err = testWeatherService(ctx, client).BackfillRollups(ctx)
+	require.NoError(t, err)
+
+	today := now.Truncate(day)
+	start := today.Add(-8 * day)
+	end := today.Add(-6 * day).Add(-time.Second)
+
+	expected := &core.Forecast{}
+	err = client.DB().Table("temperatures").Select("city_id, AVG(max) as max, AVG(min) as min, COUNT(timestamp) as sample").Group("city_id").Where("city_id = ? AND timestamp >= ? AND timestamp <= ?", city.ID, start.Unix(), end.Unix()).Scan(expected).Error
+	require.NoError(t, err)
+
+	policy := postgres.RetentionPolicy{Raw: 2 * day, Hourly: 4 * day, Daily: 30 * day}
+	require.NoError(t, policy.Validate())
+	service := postgres.NewWeatherService(ctx, client, postgres.WithRetention(policy))
+
+	forecast, err := service.GetCityForecast(ctx, city.ID)
+	require.NoError(t, err)
+
+	result, err := service.ApplyRetention(ctx, now)
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.True(t, result.RawDeleted > 0)
+	assert.True(t, result.HourlyCompacted > 0)
+
+	t.Run("should delete raw readings past retention", func(t *testing.T) {
+		var remaining int64
+		err := client.DB().Table("temperatures").Where("city_id = ? AND timestamp < ?", city.ID, today.Add(-2*day).Unix()).Count(&remaining).Error
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), remaining)
+	})
+
+	t.Run("should read compacted ranges from daily rollups", func(t *testing.T) {
+		found, err := service.ForecastBetween(ctx, city.ID, start, end)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, found)
+	})
+
+	t.Run("should keep serving forecasts from retained readings", func(t *testing.T) {
+		found, err := service.GetCityForecast(ctx, city.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, forecast, found)
+	})
+}
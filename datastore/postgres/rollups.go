@@ -0,0 +1,161 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	core "github.com/walez/weather-monster"
+
+	"github.com/jinzhu/gorm"
+)
+
+// rollupInterval is the width in seconds of a temperature_rollups bucket
+const rollupInterval = int64(time.Hour / time.Second)
+
+// upsertRollup adds a reading to the hourly rollup of its city
+const upsertRollup = `
+INSERT INTO temperature_rollups (city_id, bucket, max_sum, min_sum, sample, highest, lowest)
+VALUES (?, ?, ?, ?, 1, ?, ?)
+ON CONFLICT (city_id, bucket) DO UPDATE SET
+	max_sum = temperature_rollups.max_sum + EXCLUDED.max_sum,
+	min_sum = temperature_rollups.min_sum + EXCLUDED.min_sum,
+	sample = temperature_rollups.sample + EXCLUDED.sample,
+	highest = GREATEST(temperature_rollups.highest, EXCLUDED.highest),
+	lowest = LEAST(temperature_rollups.lowest, EXCLUDED.lowest)`
+
+// backfillRollups recomputes the rollups of every bucket that still has raw readings
+// and has not yet been compacted into daily rollups
+const backfillRollups = `
+INSERT INTO temperature_rollups (city_id, bucket, max_sum, min_sum, sample, highest, lowest)
+SELECT city_id, timestamp - timestamp % ?, SUM(max), SUM(min), COUNT(*), MAX(max), MIN(min)
+FROM temperatures
+WHERE timestamp >= ?
+GROUP BY 1, 2
+ON CONFLICT (city_id, bucket) DO UPDATE SET
+	max_sum = EXCLUDED.max_sum,
+	min_sum = EXCLUDED.min_sum,
+	sample = EXCLUDED.sample,
+	highest = EXCLUDED.highest,
+	lowest = EXCLUDED.lowest`
+
+// rollupForecast averages readings of cities combining raw readings for partial hours,
+// hourly rollups for whole hours and daily rollups for days older than the hourly retention
+const rollupForecast = `
+SELECT city_id, SUM(max_sum)::numeric / SUM(sample) AS max, SUM(min_sum)::numeric / SUM(sample) AS min, SUM(sample)::bigint AS sample
+FROM (
+	SELECT city_id, SUM(max) AS max_sum, SUM(min) AS min_sum, COUNT(*) AS sample
+	FROM temperatures
+	WHERE city_id IN (?) AND ((timestamp >= ? AND timestamp < ?) OR (timestamp >= ? AND timestamp <= ?))
+	GROUP BY city_id
+	UNION ALL
+	SELECT city_id, SUM(max_sum), SUM(min_sum), SUM(sample)
+	FROM temperature_rollups
+	WHERE city_id IN (?) AND bucket >= ? AND bucket < ?
+	GROUP BY city_id
+	UNION ALL
+	SELECT city_id, SUM(max_sum), SUM(min_sum), SUM(sample)
+	FROM temperature_daily_rollups
+	WHERE city_id IN (?) AND bucket < ? AND bucket > ? AND bucket <= ?
+	GROUP BY city_id
+) AS parts
+WHERE city_id IN (SELECT id FROM cities WHERE tenant_id = ?)
+GROUP BY city_id`
+
+// addToRollup records temperature in its hourly rollup, it must run in the transaction creating temperature
+func addToRollup(db *gorm.DB, temperature *core.Temperature) error {
+	bucket := temperature.Timestamp - temperature.Timestamp%rollupInterval
+	return db.Exec(
+		upsertRollup,
+		temperature.CityID,
+		bucket,
+		temperature.Max,
+		temperature.Min,
+		temperature.Max,
+		temperature.Min,
+	).Error
+}
+
+// forecastBetween averages the readings of a tenant's city taken between start and end inclusive.
+// Partial hours are read from raw readings while they are retained, otherwise the whole
+// hour or, past the hourly retention, the whole day containing them is used.
+func forecastBetween(db *gorm.DB, tenantID int64, cityID int64, start int64, end int64, c cutoffs) (*core.Forecast, error) {
+	forecast := &core.Forecast{}
+	err := forecastsQuery(db, tenantID, []int64{cityID}, start, end, c).Scan(forecast).Error
+	return forecast, err
+}
+
+// forecastsBetween is forecastBetween for several cities, cities without readings are omitted
+func forecastsBetween(db *gorm.DB, tenantID int64, cityIDs []int64, start int64, end int64, c cutoffs) ([]*core.Forecast, error) {
+	var forecasts []*core.Forecast
+	err := forecastsQuery(db, tenantID, cityIDs, start, end, c).Scan(&forecasts).Error
+	return forecasts, err
+}
+
+// forecastsQuery selects the forecast of each city with readings between start and end
+func forecastsQuery(db *gorm.DB, tenantID int64, cityIDs []int64, start int64, end int64, c cutoffs) *gorm.DB {
+	firstBucket := ceil(start, rollupInterval)
+	lastBucket := floor(end, rollupInterval)
+
+	// empty ranges are encoded as [1, 0]
+	leadingFrom, leadingTo := int64(1), int64(0)
+	trailingFrom, trailingTo := int64(1), int64(0)
+
+	hourlyFrom := floor(start, rollupInterval)
+	if start >= c.raw {
+		hourlyFrom = firstBucket
+		leadingFrom, leadingTo = start, min(firstBucket, end+1)
+	}
+
+	hourlyTo := lastBucket + rollupInterval
+	if end >= c.raw {
+		hourlyTo = lastBucket
+		trailingFrom, trailingTo = max(lastBucket, hourlyFrom), end
+	}
+
+	return db.Raw(
+		rollupForecast,
+		cityIDs, leadingFrom, leadingTo, trailingFrom, trailingTo,
+		cityIDs, hourlyFrom, hourlyTo,
+		cityIDs, c.hourly, start-dailyInterval, end,
+		tenantID,
+	)
+}
+
+// BackfillRollups rebuilds the hourly rollups from the raw readings, e.g after upgrading existing data.
+// Buckets whose raw readings are gone or older than the hourly retention are left untouched. Temperature writes are blocked while it runs.
+func (ws *WeatherService) BackfillRollups(ctx context.Context) (int64, error) {
+	var affected int64
+	err := ws.client.Transaction(ctx, func(db *gorm.DB) error {
+		err := db.Exec("LOCK TABLE temperatures IN SHARE MODE").Error
+		if err != nil {
+			return err
+		}
+
+		res := db.Exec(backfillRollups, rollupInterval, ws.retention.cutoffs(time.Now()).hourly)
+		affected = res.RowsAffected
+		return res.Error
+	})
+	return affected, err
+}
+
+func floor(t int64, interval int64) int64 {
+	return t - t%interval
+}
+
+func ceil(t int64, interval int64) int64 {
+	return floor(t+interval-1, interval)
+}
+
+func min(a int64, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a int64, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
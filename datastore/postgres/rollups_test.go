@@ -0,0 +1,112 @@
+package postgres_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	core "github.com/walez/weather-monster"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type rollup struct {
+	MaxSum  int64
+	MinSum  int64
+	Sample  int64
+	Highest int
+	Lowest  int
+}
+
+func TestCreateTemperatureRollup(t *testing.T) {
+	requireDB(t)
+	ctx := core.WithTenant(context.Background(), defaultTenant)
+
+	city := &core.City{
+		ID:       40,
+		TenantID: defaultTenant,
+		Name:     "City Forty",
+	}
+	err := client.DB().Create(city).Error
+	require.NoError(t, err)
+
+	service := testWeatherService(ctx, client)
+	readings := []*core.Temperature{
+		{ID: 400, CityID: city.ID, Max: 10, Min: 2},
+		{ID: 401, CityID: city.ID, Max: 14, Min: -3},
+		{ID: 402, CityID: city.ID, Max: 12, Min: 5},
+	}
+	for _, temperature := range readings {
+		err := service.CreateTemperature(ctx, temperature)
+		require.NoError(t, err)
+	}
+
+	found := &rollup{}
+	err = client.DB().Table("temperature_rollups").Where("city_id = ?", city.ID).Scan(found).Error
+	require.NoError(t, err)
+
+	assert.Equal(t, &rollup{MaxSum: 36, MinSum: 4, Sample: 3, Highest: 14, Lowest: -3}, found)
+}
+
+func TestGetCityForecastMatchesAverage(t *testing.T) {
+	requireDB(t)
+	ctx := core.WithTenant(context.Background(), defaultTenant)
+
+	city := &core.City{
+		ID:       41,
+		TenantID: defaultTenant,
+		Name:     "City FortyOne",
+	}
+	err := client.DB().Create(city).Error
+	require.NoError(t, err)
+
+	// readings spread over 30 hours so every kind of bucket is covered,
+	// staying clear of the 24 hour boundary which moves while the test runs
+	random := rand.New(rand.NewSource(41))
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		age := time.Duration(i)*18*time.Minute + 7*time.Second
+		if age > 24*time.Hour-time.Minute && age < 24*time.Hour+time.Minute {
+			continue
+		}
+
+		temperature := &core.Temperature{
+			ID:        int64(410 + i),
+			TenantID:  defaultTenant,
+			CityID:    city.ID,
+			Max:       random.Intn(40),
+			Min:       random.Intn(40) - 20,
+			Timestamp: now.Add(-age).Unix(),
+		}
+		err := client.DB().Create(temperature).Error
+		require.NoError(t, err)
+	}
+
+	service := testWeatherService(ctx, client)
+	_, err = service.BackfillRollups(ctx)
+	require.NoError(t, err)
+
+	// readings created afterwards are only known to the rollups through CreateTemperature
+	for i := 0; i < 5; i++ {
+		err := service.CreateTemperature(ctx, &core.Temperature{
+			ID:       int64(510 + i),
+			TenantID: defaultTenant,
+			CityID:   city.ID,
+			Max:      random.Intn(40),
+			Min:      random.Intn(40) - 20,
+		})
+		require.NoError(t, err)
+	}
+
+	end := time.Now().Unix()
+	start := time.Now().Add(-24 * time.Hour).Unix()
+	expected := &core.Forecast{}
+	err = client.DB().Table("temperatures").Select("city_id, AVG(max) as max, AVG(min) as min, COUNT(timestamp) as sample").Group("city_id").Where("city_id = ? AND timestamp >= ? AND timestamp <= ?", city.ID, start, end).Scan(expected).Error
+	require.NoError(t, err)
+
+	found, err := service.GetCityForecast(ctx, city.ID)
+	require.NoError(t, err)
+	assert.Equal(t, expected, found)
+}
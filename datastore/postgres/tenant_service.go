@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	core "github.com/walez/weather-monster"
+
+	"github.com/jinzhu/gorm"
+)
+
+type TenantService struct {
+	client *Client
+}
+
+func NewTenantService(ctx context.Context, client *Client) *TenantService {
+	return &TenantService{client: client}
+}
+
+func (ts *TenantService) FindTenantByID(ctx context.Context, id int64) (*core.Tenant, error) {
+	db, cancel := ts.client.WithContext(ctx)
+	defer cancel()
+
+	tenant := &core.Tenant{}
+	err := db.First(tenant, "id = ?", id).Error
+	return tenant, err
+}
+
+func (ts *TenantService) FindTenantByName(ctx context.Context, name string) (*core.Tenant, error) {
+	db, cancel := ts.client.WithContext(ctx)
+	defer cancel()
+
+	tenant := &core.Tenant{}
+	err := db.First(tenant, "name = ?", name).Error
+	return tenant, err
+}
+
+func (ts *TenantService) ListTenants(ctx context.Context) ([]*core.Tenant, error) {
+	db, cancel := ts.client.WithContext(ctx)
+	defer cancel()
+
+	var tenants []*core.Tenant
+	err := db.Order("id").Find(&tenants).Error
+	return tenants, err
+}
+
+func (ts *TenantService) CreateTenant(ctx context.Context, tenant *core.Tenant) error {
+	db, cancel := ts.client.WithContext(ctx)
+	defer cancel()
+
+	return db.Debug().Create(tenant).Error
+}
+
+func (ts *TenantService) SetDailyTemperatureQuota(ctx context.Context, tenantID int64, quota int64) error {
+	db, cancel := ts.client.WithContext(ctx)
+	defer cancel()
+
+	res := db.Debug().Table("tenants").Where("id = ?", tenantID).Update("daily_temperature_quota", quota)
+	return affectedOne(res)
+}
+
+func (ts *TenantService) GetTenantUsage(ctx context.Context, tenantID int64, day time.Time) (*core.TenantUsage, error) {
+	db, cancel := ts.client.WithContext(ctx)
+	defer cancel()
+
+	usage := &core.TenantUsage{TenantID: tenantID, Day: usageDay(day)}
+	err := db.Table("tenant_usages").
+		Select("temperatures").
+		Where("tenant_id = ? AND day = ?", tenantID, usage.Day.Format(dayLayout)).
+		Row().
+		Scan(&usage.Temperatures)
+	if err == sql.ErrNoRows {
+		return usage, nil
+	}
+	return usage, err
+}
+
+// dayLayout formats the DATE column of tenant_usages
+const dayLayout = "2006-01-02"
+
+// consumeQuota counts one temperature against the daily quota of the tenant
+const consumeQuota = `
+INSERT INTO tenant_usages (tenant_id, day, temperatures) VALUES (?, ?, 1)
+ON CONFLICT (tenant_id, day) DO UPDATE SET temperatures = tenant_usages.temperatures + 1
+RETURNING temperatures, (SELECT daily_temperature_quota FROM tenants WHERE id = ?) AS quota`
+
+// useQuota counts a temperature taken at timestamp against the tenant daily quota,
+// it fails with core.ErrQuotaExceeded once the quota is used up so the caller must roll back
+func useQuota(db *gorm.DB, tenantID int64, timestamp int64) error {
+	var usage struct {
+		Temperatures int64
+		Quota        int64
+	}
+
+	day := usageDay(time.Unix(timestamp, 0)).Format(dayLayout)
+	err := db.Raw(consumeQuota, tenantID, day, tenantID).Scan(&usage).Error
+	if err != nil {
+		return err
+	}
+
+	if usage.Quota > 0 && usage.Temperatures > usage.Quota {
+		return core.ErrQuotaExceeded
+	}
+	return nil
+}
+
+// usageDay returns the UTC day containing t, quotas reset at midnight UTC
+func usageDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
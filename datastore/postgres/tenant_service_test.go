@@ -0,0 +1,130 @@
+package postgres_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/datastore/postgres"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	requireDB(t)
+	ctx := context.Background()
+
+	tenants := postgres.NewTenantService(ctx, client)
+	other := &core.Tenant{Name: "Other Team"}
+	err := tenants.CreateTenant(ctx, other)
+	require.NoError(t, err)
+
+	found, err := tenants.FindTenantByName(ctx, "Other Team")
+	require.NoError(t, err)
+	assert.Equal(t, other.ID, found.ID)
+
+	ownCtx := core.WithTenant(ctx, defaultTenant)
+	otherCtx := core.WithTenant(ctx, other.ID)
+	service := testWeatherService(ctx, client)
+
+	berlin := &core.City{ID: 60, Name: "Berlin"}
+	require.NoError(t, service.CreateCity(ownCtx, berlin))
+	otherBerlin := &core.City{ID: 61, Name: "Berlin"}
+	require.NoError(t, service.CreateCity(otherCtx, otherBerlin), "city names are unique per tenant")
+
+	require.NoError(t, service.CreateTemperature(ownCtx, &core.Temperature{ID: 900, CityID: berlin.ID, Max: 10, Min: 5}))
+	require.NoError(t, service.CreateWebhook(ownCtx, &core.Webhook{ID: 700, CityID: berlin.ID, CallbackURL: "callbackberlin"}))
+
+	t.Run("should require a tenant", func(t *testing.T) {
+		_, err := service.FindCityByID(ctx, berlin.ID)
+		assert.Equal(t, core.ErrNoTenant, err)
+	})
+
+	t.Run("should find own city by name", func(t *testing.T) {
+		found, err := service.FindCityByName(otherCtx, "Berlin")
+		assert.NoError(t, err)
+		assert.Equal(t, otherBerlin.ID, found.ID)
+	})
+
+	t.Run("should not find other tenant city", func(t *testing.T) {
+		_, err := service.FindCityByID(otherCtx, berlin.ID)
+		assert.EqualError(t, err, noRecordErr)
+	})
+
+	t.Run("should not update or delete other tenant city", func(t *testing.T) {
+		err := service.UpdateCity(otherCtx, &core.City{ID: berlin.ID, Name: "Hijacked"})
+		assert.EqualError(t, err, noRecordErr)
+
+		err = service.DeleteCity(otherCtx, &core.City{ID: berlin.ID})
+		assert.EqualError(t, err, noRecordErr)
+
+		found, err := service.FindCityByID(ownCtx, berlin.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Berlin", found.Name)
+	})
+
+	t.Run("should not read other tenant forecast", func(t *testing.T) {
+		_, err := service.GetCityForecast(ownCtx, berlin.ID)
+		assert.NoError(t, err)
+
+		_, err = service.GetCityForecast(otherCtx, berlin.ID)
+		assert.EqualError(t, err, noRecordErr)
+	})
+
+	t.Run("should not read or delete other tenant webhooks", func(t *testing.T) {
+		webhooks, err := service.GetCityWebhooks(otherCtx, berlin.ID)
+		assert.NoError(t, err)
+		assert.Empty(t, webhooks)
+
+		_, err = service.FindWebhookByID(otherCtx, 700)
+		assert.EqualError(t, err, noRecordErr)
+
+		err = service.DeleteWebhook(otherCtx, &core.Webhook{ID: 700})
+		assert.EqualError(t, err, noRecordErr)
+	})
+
+	t.Run("should not attach entities to other tenant city", func(t *testing.T) {
+		err := service.CreateTemperature(otherCtx, &core.Temperature{ID: 901, CityID: berlin.ID, Max: 10, Min: 5})
+		assert.EqualError(t, err, noRecordErr)
+
+		err = service.CreateWebhook(otherCtx, &core.Webhook{ID: 701, CityID: berlin.ID, CallbackURL: "callbackother"})
+		assert.EqualError(t, err, noRecordErr)
+	})
+}
+
+func TestTenantQuota(t *testing.T) {
+	requireDB(t)
+	ctx := context.Background()
+
+	tenants := postgres.NewTenantService(ctx, client)
+	tenant := &core.Tenant{Name: "Quota Team"}
+	require.NoError(t, tenants.CreateTenant(ctx, tenant))
+	require.NoError(t, tenants.SetDailyTemperatureQuota(ctx, tenant.ID, 2))
+
+	tenantCtx := core.WithTenant(ctx, tenant.ID)
+	service := testWeatherService(ctx, client)
+
+	city := &core.City{ID: 62, Name: "Quota City"}
+	require.NoError(t, service.CreateCity(tenantCtx, city))
+
+	t.Run("should create temperatures within quota", func(t *testing.T) {
+		assert.NoError(t, service.CreateTemperature(tenantCtx, &core.Temperature{ID: 902, CityID: city.ID, Max: 10, Min: 5}))
+		assert.NoError(t, service.CreateTemperature(tenantCtx, &core.Temperature{ID: 903, CityID: city.ID, Max: 10, Min: 5}))
+	})
+
+	t.Run("should reject temperatures over quota", func(t *testing.T) {
+		err := service.CreateTemperature(tenantCtx, &core.Temperature{ID: 904, CityID: city.ID, Max: 10, Min: 5})
+		assert.Equal(t, core.ErrQuotaExceeded, err)
+
+		usage, err := tenants.GetTenantUsage(ctx, tenant.ID, time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), usage.Temperatures, "rejected temperatures are not counted")
+	})
+
+	t.Run("should lift quota", func(t *testing.T) {
+		require.NoError(t, tenants.SetDailyTemperatureQuota(ctx, tenant.ID, 0))
+		assert.NoError(t, service.CreateTemperature(tenantCtx, &core.Temperature{ID: 905, CityID: city.ID, Max: 10, Min: 5}))
+	})
+}
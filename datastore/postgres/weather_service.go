@@ -5,72 +5,336 @@ import (
 	"time"
 
 	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/logging"
+	"github.com/walez/weather-monster/metrics"
+
+	"github.com/jinzhu/gorm"
 )
 
 type WeatherService struct {
-	client *Client
+	client    *Client
+	retention RetentionPolicy
+}
+
+// ServiceOption configures optional behaviour of the weather service
+type ServiceOption func(*WeatherService)
+
+// WithRetention makes queries aware of readings compacted by ApplyRetention
+func WithRetention(policy RetentionPolicy) ServiceOption {
+	return func(ws *WeatherService) {
+		ws.retention = policy
+	}
 }
 
-func NewWeatherService(ctx context.Context, client *Client) *WeatherService {
-	ws := &WeatherService{client}
+func NewWeatherService(ctx context.Context, client *Client, opts ...ServiceOption) *WeatherService {
+	ws := &WeatherService{client: client}
+	for _, opt := range opts {
+		opt(ws)
+	}
 	return ws
 }
 
 func (ws *WeatherService) FindCityByID(ctx context.Context, id int64) (*core.City, error) {
+	tenantID, err := tenantFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db, cancel := ws.client.WithContext(ctx)
+	defer cancel()
+
 	city := &core.City{}
-	err := ws.client.db.First(city, "id = ? AND is_deleted = ?", id, false).Error
-	return city, err
+	err = db.First(city, "id = ? AND tenant_id = ? AND is_deleted = ?", id, tenantID, false).Error
+	return city, notFound(err)
 }
 
 func (ws *WeatherService) FindCityByName(ctx context.Context, name string) (*core.City, error) {
+	tenantID, err := tenantFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db, cancel := ws.client.WithContext(ctx)
+	defer cancel()
+
 	city := &core.City{}
-	err := ws.client.db.First(city, "name = ? AND is_deleted = ?", name, false).Error
-	return city, err
+	err = db.First(city, "name = ? AND tenant_id = ? AND is_deleted = ?", name, tenantID, false).Error
+	return city, notFound(err)
 }
 
 func (ws *WeatherService) CreateCity(ctx context.Context, city *core.City) error {
-	return ws.client.db.Debug().Create(city).Error
+	tenantID, err := tenantFrom(ctx)
+	if err != nil {
+		return err
+	}
+
+	db, cancel := ws.client.WithContext(ctx)
+	defer cancel()
+
+	city.TenantID = tenantID
+	return db.Debug().Create(city).Error
 }
 
 func (ws *WeatherService) UpdateCity(ctx context.Context, city *core.City) error {
-	return ws.client.db.Debug().Save(city).Error
+	tenantID, err := tenantFrom(ctx)
+	if err != nil {
+		return err
+	}
+
+	db, cancel := ws.client.WithContext(ctx)
+	defer cancel()
+
+	res := db.Debug().Table("cities").Where("id = ? AND tenant_id = ?", city.ID, tenantID).Updates(map[string]interface{}{
+		"name":      city.Name,
+		"latitude":  city.Latitude,
+		"longitude": city.Longitude,
+	})
+	return affectedOne(res)
 }
 
 func (ws *WeatherService) DeleteCity(ctx context.Context, city *core.City) error {
+	tenantID, err := tenantFrom(ctx)
+	if err != nil {
+		return err
+	}
+
+	db, cancel := ws.client.WithContext(ctx)
+	defer cancel()
+
 	city.IsDeleted = true
-	return ws.client.db.Debug().Save(city).Error
+	res := db.Debug().Table("cities").Where("id = ? AND tenant_id = ?", city.ID, tenantID).Update("is_deleted", true)
+	return affectedOne(res)
 }
 
 func (ws *WeatherService) GetCityForecast(ctx context.Context, cityID int64) (*core.Forecast, error) {
-	start := time.Now().Add(-24 * time.Hour).Unix()
-	end := time.Now().Unix()
+	end := time.Now()
+	return ws.ForecastBetween(ctx, cityID, end.Add(-24*time.Hour), end)
+}
 
-	forecast := &core.Forecast{}
-	err := ws.client.db.Debug().Table("temperatures").Select("city_id, AVG(max) as max, AVG(min) as min, COUNT(timestamp) as sample").Group("city_id").Where("city_id = ? AND timestamp >= ? AND timestamp <= ?", cityID, start, end).Scan(forecast).Error
-	return forecast, err
+// ForecastBetween averages the readings of a city taken between start and end.
+// Ranges older than the raw retention are rounded to the hours or days containing them.
+func (ws *WeatherService) ForecastBetween(ctx context.Context, cityID int64, start time.Time, end time.Time) (*core.Forecast, error) {
+	tenantID, err := tenantFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db, cancel := ws.client.WithContext(ctx)
+	defer cancel()
+
+	defer metrics.Since(metrics.ForecastDuration, time.Now())
+	return forecastBetween(db.Debug(), tenantID, cityID, start.Unix(), end.Unix(), ws.retention.cutoffs(time.Now()))
 }
 
 func (ws *WeatherService) GetCityWebhooks(ctx context.Context, cityID int64) ([]*core.Webhook, error) {
+	tenantID, err := tenantFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db, cancel := ws.client.WithContext(ctx)
+	defer cancel()
+
+	var webhooks []*core.Webhook
+	err = db.Debug().Where("city_id = ? AND tenant_id = ?", cityID, tenantID).Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (ws *WeatherService) ListCities(ctx context.Context, cursor int64, limit int) ([]*core.City, error) {
+	tenantID, err := tenantFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db, cancel := ws.client.WithContext(ctx)
+	defer cancel()
+
+	var cities []*core.City
+	err = db.Debug().Where("id > ? AND tenant_id = ? AND is_deleted = ?", cursor, tenantID, false).
+		Order("id").Limit(limit).Find(&cities).Error
+	return cities, err
+}
+
+func (ws *WeatherService) FindCitiesByIDs(ctx context.Context, ids []int64) ([]*core.City, error) {
+	tenantID, err := tenantFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db, cancel := ws.client.WithContext(ctx)
+	defer cancel()
+
+	var cities []*core.City
+	err = db.Debug().Where("id IN (?) AND tenant_id = ? AND is_deleted = ?", ids, tenantID, false).Find(&cities).Error
+	return cities, err
+}
+
+// GetCitiesForecasts averages the readings of the last 24 hours of each city, like GetCityForecast
+func (ws *WeatherService) GetCitiesForecasts(ctx context.Context, cityIDs []int64) ([]*core.Forecast, error) {
+	tenantID, err := tenantFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db, cancel := ws.client.WithContext(ctx)
+	defer cancel()
+
+	defer metrics.Since(metrics.ForecastDuration, time.Now())
+	end := time.Now()
+	return forecastsBetween(db.Debug(), tenantID, cityIDs, end.Add(-24*time.Hour).Unix(), end.Unix(), ws.retention.cutoffs(end))
+}
+
+func (ws *WeatherService) GetCitiesWebhooks(ctx context.Context, cityIDs []int64) ([]*core.Webhook, error) {
+	tenantID, err := tenantFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db, cancel := ws.client.WithContext(ctx)
+	defer cancel()
+
 	var webhooks []*core.Webhook
-	err := ws.client.db.Debug().Where("city_id = ?", cityID).Find(&webhooks).Error
+	err = db.Debug().Where("city_id IN (?) AND tenant_id = ?", cityIDs, tenantID).Order("id").Find(&webhooks).Error
 	return webhooks, err
 }
 
+// latestTemperatures ranks the temperatures of each city from the most recent one
+const latestTemperatures = `
+SELECT id, tenant_id, city_id, max, min, timestamp
+FROM (
+	SELECT *, row_number() OVER (PARTITION BY city_id ORDER BY timestamp DESC, id DESC) AS rank
+	FROM temperatures
+	WHERE city_id IN (?) AND tenant_id = ?
+) AS ranked
+WHERE rank <= ?
+ORDER BY city_id, rank`
+
+func (ws *WeatherService) GetCitiesTemperatures(ctx context.Context, cityIDs []int64, limit int) ([]*core.Temperature, error) {
+	tenantID, err := tenantFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db, cancel := ws.client.WithContext(ctx)
+	defer cancel()
+
+	var temperatures []*core.Temperature
+	err = db.Debug().Raw(latestTemperatures, cityIDs, tenantID, limit).Scan(&temperatures).Error
+	return temperatures, err
+}
+
 func (ws *WeatherService) CreateTemperature(ctx context.Context, temperature *core.Temperature) error {
+	tenantID, err := tenantFrom(ctx)
+	if err != nil {
+		return err
+	}
+
+	temperature.TenantID = tenantID
 	temperature.Timestamp = time.Now().Unix()
-	return ws.client.db.Debug().Create(temperature).Error
+	return ws.client.Transaction(ctx, func(db *gorm.DB) error {
+		err := ownsCity(db, tenantID, temperature.CityID)
+		if err != nil {
+			return err
+		}
+
+		err = useQuota(db.Debug(), tenantID, temperature.Timestamp)
+		if err != nil {
+			if err == core.ErrQuotaExceeded {
+				logging.FromContext(ctx).WithField("tenant_id", tenantID).Warning("weather service: daily quota exceeded")
+			}
+			return err
+		}
+
+		err = db.Debug().Create(temperature).Error
+		if err != nil {
+			return err
+		}
+		return addToRollup(db.Debug(), temperature)
+	})
 }
 
 func (ws *WeatherService) FindWebhookByID(ctx context.Context, id int64) (*core.Webhook, error) {
+	tenantID, err := tenantFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db, cancel := ws.client.WithContext(ctx)
+	defer cancel()
+
 	webhook := &core.Webhook{}
-	err := ws.client.db.First(webhook, "id = ?", id).Error
-	return webhook, err
+	err = db.First(webhook, "id = ? AND tenant_id = ?", id, tenantID).Error
+	return webhook, notFound(err)
 }
 
 func (ws *WeatherService) CreateWebhook(ctx context.Context, webhook *core.Webhook) error {
-	return ws.client.db.Debug().Create(webhook).Error
+	tenantID, err := tenantFrom(ctx)
+	if err != nil {
+		return err
+	}
+
+	db, cancel := ws.client.WithContext(ctx)
+	defer cancel()
+
+	err = ownsCity(db, tenantID, webhook.CityID)
+	if err != nil {
+		return err
+	}
+
+	webhook.TenantID = tenantID
+	return db.Debug().Create(webhook).Error
 }
 
 func (ws *WeatherService) DeleteWebhook(ctx context.Context, webhook *core.Webhook) error {
-	return ws.client.db.Debug().Delete(webhook).Error
+	tenantID, err := tenantFrom(ctx)
+	if err != nil {
+		return err
+	}
+
+	db, cancel := ws.client.WithContext(ctx)
+	defer cancel()
+
+	res := db.Debug().Where("id = ? AND tenant_id = ?", webhook.ID, tenantID).Delete(&core.Webhook{})
+	return affectedOne(res)
+}
+
+// tenantFrom returns the tenant every query of the request must be scoped to
+func tenantFrom(ctx context.Context) (int64, error) {
+	tenantID, ok := core.TenantFromContext(ctx)
+	if !ok {
+		return 0, core.ErrNoTenant
+	}
+	return tenantID, nil
+}
+
+// ownsCity ensures entities are only attached to cities of the same tenant that are not deleted
+func ownsCity(db *gorm.DB, tenantID int64, cityID int64) error {
+	var count int
+	err := db.Model(&core.City{}).Where("id = ? AND tenant_id = ? AND is_deleted = ?", cityID, tenantID, false).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return core.ErrNotFound
+	}
+	return nil
+}
+
+// affectedOne reports statements scoped out by the tenant condition as not found
+func affectedOne(res *gorm.DB) error {
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return core.ErrNotFound
+	}
+	return nil
+}
+
+// notFound reports the records missing from First queries as core.ErrNotFound
+func notFound(err error) error {
+	if gorm.IsRecordNotFoundError(err) {
+		return core.ErrNotFound
+	}
+	return err
 }
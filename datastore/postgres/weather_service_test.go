@@ -8,6 +8,7 @@ import (
 	core "github.com/walez/weather-monster"
 	"github.com/walez/weather-monster/datastore/postgres"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,11 +20,13 @@ var noRecordErr = "record not found"
 var duplicateKeyError = "duplicate key value violates unique constraint"
 
 func TestFindCityByID(t *testing.T) {
-	ctx := context.Background()
+	requireDB(t)
+	ctx := core.WithTenant(context.Background(), defaultTenant)
 
 	city := &core.City{
-		ID:   1,
-		Name: "City One",
+		ID:       1,
+		TenantID: defaultTenant,
+		Name:     "City One",
 	}
 	err := client.DB().Create(city).Error
 	assert.NoError(t, err)
@@ -69,11 +72,13 @@ func TestFindCityByID(t *testing.T) {
 }
 
 func TestFindCityByName(t *testing.T) {
-	ctx := context.Background()
+	requireDB(t)
+	ctx := core.WithTenant(context.Background(), defaultTenant)
 
 	city := &core.City{
-		ID:   2,
-		Name: "City Two",
+		ID:       2,
+		TenantID: defaultTenant,
+		Name:     "City Two",
 	}
 	err := client.DB().Create(city).Error
 	assert.NoError(t, err)
@@ -119,18 +124,21 @@ func TestFindCityByName(t *testing.T) {
 }
 
 func TestCreateCity(t *testing.T) {
-	ctx := context.Background()
+	requireDB(t)
+	ctx := core.WithTenant(context.Background(), defaultTenant)
 
 	cityThree := &core.City{
-		ID:   3,
-		Name: "City Three",
+		ID:       3,
+		TenantID: defaultTenant,
+		Name:     "City Three",
 	}
 	err := client.DB().Create(cityThree).Error
 	assert.NoError(t, err)
 
 	cityFour := &core.City{
-		ID:   4,
-		Name: "City Four",
+		ID:       4,
+		TenantID: defaultTenant,
+		Name:     "City Four",
 	}
 
 	type test struct {
@@ -148,8 +156,9 @@ func TestCreateCity(t *testing.T) {
 		{
 			summary: "should return err for duplicate name city",
 			input: &core.City{
-				ID:   100,
-				Name: "City Three",
+				ID:       100,
+				TenantID: defaultTenant,
+				Name:     "City Three",
 			},
 			err:       duplicateKeyError,
 			shouldErr: true,
@@ -172,11 +181,13 @@ func TestCreateCity(t *testing.T) {
 }
 
 func TestUpdateCity(t *testing.T) {
-	ctx := context.Background()
+	requireDB(t)
+	ctx := core.WithTenant(context.Background(), defaultTenant)
 
 	cityEight := &core.City{
-		ID:   8,
-		Name: "City Eight",
+		ID:       8,
+		TenantID: defaultTenant,
+		Name:     "City Eight",
 	}
 	err := client.DB().Create(cityEight).Error
 	assert.NoError(t, err)
@@ -218,11 +229,13 @@ func TestUpdateCity(t *testing.T) {
 }
 
 func TestDeleteCity(t *testing.T) {
-	ctx := context.Background()
+	requireDB(t)
+	ctx := core.WithTenant(context.Background(), defaultTenant)
 
 	cityTen := &core.City{
-		ID:   10,
-		Name: "City Ten",
+		ID:       10,
+		TenantID: defaultTenant,
+		Name:     "City Ten",
 	}
 	err := client.DB().Create(cityTen).Error
 	assert.NoError(t, err)
@@ -255,23 +268,32 @@ func TestDeleteCity(t *testing.T) {
 				c, err := service.FindCityByID(ctx, tc.input.ID)
 				assert.EqualError(t, err, noRecordErr)
 				assert.Equal(t, c.ID, int64(0))
+
+				err = service.CreateTemperature(ctx, &core.Temperature{CityID: tc.input.ID, Max: 10, Min: 5})
+				assert.EqualError(t, err, noRecordErr, "temperatures are not attached to deleted cities")
+
+				err = service.CreateWebhook(ctx, &core.Webhook{CityID: tc.input.ID, CallbackURL: "callbackdeleted"})
+				assert.EqualError(t, err, noRecordErr, "webhooks are not attached to deleted cities")
 			}
 		})
 	}
 }
 
 func TestGetCityForecast(t *testing.T) {
-	ctx := context.Background()
+	requireDB(t)
+	ctx := core.WithTenant(context.Background(), defaultTenant)
 
 	city := &core.City{
-		ID:   30,
-		Name: "City Thirty",
+		ID:       30,
+		TenantID: defaultTenant,
+		Name:     "City Thirty",
 	}
 	err := client.DB().Create(city).Error
 	assert.NoError(t, err)
 
 	temperatureOne := &core.Temperature{
 		ID:        1,
+		TenantID:  defaultTenant,
 		CityID:    30,
 		Max:       10,
 		Min:       5,
@@ -282,6 +304,7 @@ func TestGetCityForecast(t *testing.T) {
 
 	temperatureTwo := &core.Temperature{
 		ID:        2,
+		TenantID:  defaultTenant,
 		CityID:    30,
 		Max:       11,
 		Min:       8,
@@ -292,6 +315,7 @@ func TestGetCityForecast(t *testing.T) {
 
 	temperatureThree := &core.Temperature{
 		ID:        3,
+		TenantID:  defaultTenant,
 		CityID:    30,
 		Max:       110,
 		Min:       60,
@@ -300,6 +324,10 @@ func TestGetCityForecast(t *testing.T) {
 	err = client.DB().Create(temperatureThree).Error
 	assert.NoError(t, err)
 
+	service := testWeatherService(ctx, client)
+	_, err = service.BackfillRollups(ctx)
+	assert.NoError(t, err)
+
 	type test struct {
 		summary   string
 		input     int64
@@ -320,7 +348,6 @@ func TestGetCityForecast(t *testing.T) {
 		},
 	}
 
-	service := testWeatherService(ctx, client)
 	for _, tc := range tests {
 		t.Run(tc.summary, func(t *testing.T) {
 			found, err := service.GetCityForecast(ctx, tc.input)
@@ -339,25 +366,73 @@ func TestGetCityForecast(t *testing.T) {
 	}
 }
 
+func TestGetCityForecastContext(t *testing.T) {
+	requireDB(t)
+	city := &core.City{
+		ID:       31,
+		TenantID: defaultTenant,
+		Name:     "City ThirtyOne",
+	}
+	err := client.DB().Create(city).Error
+	assert.NoError(t, err)
+
+	t.Run("should abort forecast query for cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(core.WithTenant(context.Background(), defaultTenant))
+		cancel()
+
+		service := testWeatherService(ctx, client)
+		_, err := service.GetCityForecast(ctx, city.ID)
+		assert.Equal(t, context.Canceled, errors.Cause(err))
+	})
+
+	t.Run("should abort forecast query once query timeout elapses", func(t *testing.T) {
+		ctx := core.WithTenant(context.Background(), defaultTenant)
+
+		timeoutClient := postgres.New(ctx, testURI, postgres.WithQueryTimeout(time.Nanosecond))
+		defer timeoutClient.Close()
+
+		service := testWeatherService(ctx, timeoutClient)
+		_, err := service.GetCityForecast(ctx, city.ID)
+		assert.Equal(t, context.DeadlineExceeded, errors.Cause(err))
+	})
+
+	t.Run("should abort in-flight query when context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(core.WithTenant(context.Background(), defaultTenant), 100*time.Millisecond)
+		defer cancel()
+
+		db, done := client.WithContext(ctx)
+		defer done()
+
+		started := time.Now()
+		err := db.Exec("SELECT pg_sleep(5)").Error
+		assert.Error(t, err)
+		assert.True(t, time.Since(started) < 2*time.Second)
+	})
+}
+
 func TestGetCityWebhooks(t *testing.T) {
-	ctx := context.Background()
+	requireDB(t)
+	ctx := core.WithTenant(context.Background(), defaultTenant)
 
 	cityTwenty := &core.City{
-		ID:   20,
-		Name: "City Twenty",
+		ID:       20,
+		TenantID: defaultTenant,
+		Name:     "City Twenty",
 	}
 	err := client.DB().Create(cityTwenty).Error
 	assert.NoError(t, err)
 
 	cityTwentyOne := &core.City{
-		ID:   21,
-		Name: "City TwentyOne",
+		ID:       21,
+		TenantID: defaultTenant,
+		Name:     "City TwentyOne",
 	}
 	err = client.DB().Create(cityTwentyOne).Error
 	assert.NoError(t, err)
 
 	webhookOne := &core.Webhook{
 		ID:          20,
+		TenantID:    defaultTenant,
 		CityID:      20,
 		CallbackURL: "callbackone",
 	}
@@ -366,6 +441,7 @@ func TestGetCityWebhooks(t *testing.T) {
 
 	webhookTwo := &core.Webhook{
 		ID:          21,
+		TenantID:    defaultTenant,
 		CityID:      21,
 		CallbackURL: "callbacktwo",
 	}
@@ -408,3 +484,109 @@ func TestGetCityWebhooks(t *testing.T) {
 		})
 	}
 }
+
+func TestListCities(t *testing.T) {
+	requireDB(t)
+	ctx := core.WithTenant(context.Background(), defaultTenant)
+
+	for _, city := range []*core.City{
+		{ID: 80, TenantID: defaultTenant, Name: "City Eighty"},
+		{ID: 81, TenantID: defaultTenant, Name: "City EightyOne", IsDeleted: true},
+		{ID: 82, TenantID: defaultTenant, Name: "City EightyTwo"},
+	} {
+		err := client.DB().Create(city).Error
+		assert.NoError(t, err)
+	}
+
+	service := testWeatherService(ctx, client)
+
+	t.Run("should list cities after the cursor skipping deleted ones", func(t *testing.T) {
+		found, err := service.ListCities(ctx, 79, 2)
+		assert.NoError(t, err)
+		if assert.Len(t, found, 2) {
+			assert.Equal(t, int64(80), found[0].ID)
+			assert.Equal(t, int64(82), found[1].ID)
+		}
+	})
+
+	t.Run("should return empty result past the last city", func(t *testing.T) {
+		found, err := service.ListCities(ctx, 1<<40, 2)
+		assert.NoError(t, err)
+		assert.Len(t, found, 0)
+	})
+}
+
+func TestCityBatches(t *testing.T) {
+	requireDB(t)
+	ctx := core.WithTenant(context.Background(), defaultTenant)
+	now := time.Now()
+
+	for _, city := range []*core.City{
+		{ID: 85, TenantID: defaultTenant, Name: "City EightyFive"},
+		{ID: 86, TenantID: defaultTenant, Name: "City EightySix"},
+	} {
+		err := client.DB().Create(city).Error
+		assert.NoError(t, err)
+	}
+
+	for _, temperature := range []*core.Temperature{
+		{ID: 850, TenantID: defaultTenant, CityID: 85, Max: 10, Min: 4, Timestamp: now.Add(-3 * time.Hour).Unix()},
+		{ID: 851, TenantID: defaultTenant, CityID: 85, Max: 12, Min: 6, Timestamp: now.Add(-2 * time.Hour).Unix()},
+		{ID: 852, TenantID: defaultTenant, CityID: 85, Max: 14, Min: 8, Timestamp: now.Add(-1 * time.Hour).Unix()},
+		{ID: 860, TenantID: defaultTenant, CityID: 86, Max: 20, Min: 10, Timestamp: now.Add(-1 * time.Hour).Unix()},
+	} {
+		err := client.DB().Create(temperature).Error
+		assert.NoError(t, err)
+	}
+
+	webhook := &core.Webhook{ID: 850, TenantID: defaultTenant, CityID: 85, CallbackURL: "callbackeightyfive"}
+	err := client.DB().Create(webhook).Error
+	assert.NoError(t, err)
+
+	service := testWeatherService(ctx, client)
+	_, err = service.BackfillRollups(ctx)
+	assert.NoError(t, err)
+
+	cityIDs := []int64{85, 86, 87}
+
+	t.Run("should find existing cities", func(t *testing.T) {
+		found, err := service.FindCitiesByIDs(ctx, cityIDs)
+		assert.NoError(t, err)
+		assert.Len(t, found, 2)
+	})
+
+	t.Run("should return the forecast of cities with readings", func(t *testing.T) {
+		found, err := service.GetCitiesForecasts(ctx, cityIDs)
+		assert.NoError(t, err)
+		assert.Len(t, found, 2)
+		for _, forecast := range found {
+			switch forecast.CityID {
+			case 85:
+				assert.Equal(t, 12.0, forecast.Max)
+				assert.Equal(t, int64(3), forecast.Sample)
+			case 86:
+				assert.Equal(t, 20.0, forecast.Max)
+				assert.Equal(t, int64(1), forecast.Sample)
+			}
+		}
+	})
+
+	t.Run("should return the webhooks of cities", func(t *testing.T) {
+		found, err := service.GetCitiesWebhooks(ctx, cityIDs)
+		assert.NoError(t, err)
+		if assert.Len(t, found, 1) {
+			assert.Equal(t, int64(850), found[0].ID)
+		}
+	})
+
+	t.Run("should return the most recent temperatures of each city", func(t *testing.T) {
+		found, err := service.GetCitiesTemperatures(ctx, cityIDs, 2)
+		assert.NoError(t, err)
+
+		var ids []int64
+		for _, temperature := range found {
+			ids = append(ids, temperature.ID)
+		}
+		assert.Equal(t, []int64{852, 851, 860}, ids)
+	})
+}
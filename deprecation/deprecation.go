@@ -0,0 +1,38 @@
+// Package deprecation announces that routes are deprecated and when they stop being served
+package deprecation
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Headers set on responses of deprecated routes
+const (
+	// DeprecationHeader holds when the route was deprecated, as defined by RFC 9745
+	DeprecationHeader = "Deprecation"
+	// SunsetHeader holds when the route stops being served, as defined by RFC 8594
+	SunsetHeader = "Sunset"
+	LinkHeader   = "Link"
+)
+
+// Middleware marks the routes of its group as deprecated since the given time and links every response to
+// the same path under successorPrefix. The Sunset header is only set when sunset is not zero.
+func Middleware(since time.Time, sunset time.Time, successorPrefix string) gin.HandlerFunc {
+	deprecation := fmt.Sprintf("@%d", since.Unix())
+	var sunsetDate string
+	if !sunset.IsZero() {
+		sunsetDate = sunset.UTC().Format(http.TimeFormat)
+	}
+
+	return func(c *gin.Context) {
+		c.Header(DeprecationHeader, deprecation)
+		if sunsetDate != "" {
+			c.Header(SunsetHeader, sunsetDate)
+		}
+		c.Header(LinkHeader, fmt.Sprintf("<%s%s>; rel=\"successor-version\"", successorPrefix, c.Request.URL.Path))
+		c.Next()
+	}
+}
@@ -0,0 +1,43 @@
+package deprecation_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/walez/weather-monster/deprecation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	since := time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+
+	request := func(sunset time.Time) *httptest.ResponseRecorder {
+		r := gin.New()
+		r.Use(deprecation.Middleware(since, sunset, "/v1"))
+		r.GET("/cities/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cities/1", nil))
+		return w
+	}
+
+	t.Run("should announce deprecation and sunset", func(t *testing.T) {
+		w := request(sunset)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "@1792368000", w.Header().Get(deprecation.DeprecationHeader))
+		assert.Equal(t, "Fri, 30 Apr 2027 00:00:00 GMT", w.Header().Get(deprecation.SunsetHeader))
+		assert.Equal(t, `</v1/cities/1>; rel="successor-version"`, w.Header().Get(deprecation.LinkHeader))
+	})
+
+	t.Run("should omit unknown sunset", func(t *testing.T) {
+		w := request(time.Time{})
+		assert.NotEmpty(t, w.Header().Get(deprecation.DeprecationHeader))
+		assert.NotContains(t, w.Header(), deprecation.SunsetHeader)
+	})
+}
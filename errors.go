@@ -0,0 +1,23 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned when an entity does not exist or belongs to another tenant
+var ErrNotFound = errors.New("not found")
+
+// ValidationError is returned when the input of an operation is invalid, its message is meant for the client
+type ValidationError struct {
+	Message string
+}
+
+// Invalid returns a ValidationError whose message is formatted according to format
+func Invalid(format string, args ...interface{}) error {
+	return &ValidationError{Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
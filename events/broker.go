@@ -0,0 +1,323 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/logging"
+	"github.com/walez/weather-monster/metrics"
+)
+
+// Defaults of the broker and its subscriptions
+const (
+	DefaultSubscriptionBuffer = 16
+	DefaultHistory            = 100
+	DefaultHistoryRetention   = time.Hour
+)
+
+// ErrSlowSubscriber is the error of subscriptions closed by the Disconnect policy
+var ErrSlowSubscriber = errors.New("subscriber too slow")
+
+// OverflowPolicy decides what happens to a temperature published to a subscription whose buffer is full
+type OverflowPolicy int
+
+const (
+	// DropNewest skips the temperature, the subscriber misses it
+	DropNewest OverflowPolicy = iota
+	// Disconnect closes the subscription with ErrSlowSubscriber, the subscriber may resume from the history
+	Disconnect
+)
+
+// ParseOverflowPolicy parses "drop" or "disconnect"
+func ParseOverflowPolicy(policy string) (OverflowPolicy, error) {
+	switch policy {
+	case "drop":
+		return DropNewest, nil
+	case "disconnect":
+		return Disconnect, nil
+	default:
+		return 0, fmt.Errorf("unknown overflow policy %q, expected drop or disconnect", policy)
+	}
+}
+
+// Broker fans the temperatures created for a city out to its live subscribers and keeps the most recent
+// ones so that subscribers can resume, register Publish as a TemperatureCreated listener to feed it
+type Broker struct {
+	mu               sync.Mutex
+	subscribers      map[topic]map[*Subscription]struct{}
+	history          map[topic]*history
+	historySize      int
+	historyRetention time.Duration
+	lastSweep        time.Time
+	closed           bool
+}
+
+// history holds the most recent temperatures of a topic ordered by id
+type history struct {
+	temperatures []core.Temperature
+	publishedAt  time.Time
+}
+
+// topic identifies the temperatures of a city, cities are scoped to their tenant
+type topic struct {
+	tenantID int64
+	cityID   int64
+}
+
+// BrokerOption configures optional behaviour of the broker
+type BrokerOption func(*Broker)
+
+// WithHistory sets how many of the most recent temperatures of each city are kept to resume from, 0 keeps none
+func WithHistory(n int) BrokerOption {
+	return func(b *Broker) {
+		b.historySize = n
+	}
+}
+
+// WithHistoryRetention sets how long the history of a city without subscribers is kept after its most recent
+// temperature was published, 0 keeps it as long as the broker
+func WithHistoryRetention(d time.Duration) BrokerOption {
+	return func(b *Broker) {
+		b.historyRetention = d
+	}
+}
+
+func NewBroker(opts ...BrokerOption) *Broker {
+	b := &Broker{
+		subscribers:      make(map[topic]map[*Subscription]struct{}),
+		history:          make(map[topic]*history),
+		historySize:      DefaultHistory,
+		historyRetention: DefaultHistoryRetention,
+		lastSweep:        time.Now(),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Subscription receives the temperatures of its cities until it is closed
+type Subscription struct {
+	// Replay holds the temperatures created since the one resumed after, oldest first,
+	// they were published before the ones received on C
+	Replay []core.Temperature
+	// C receives the temperatures in the order they were published, it is closed by Close
+	C <-chan core.Temperature
+
+	c        chan core.Temperature
+	broker   *Broker
+	topics   []topic
+	overflow OverflowPolicy
+	after    int64
+	err      error
+	closed   bool
+}
+
+// SubscribeOption configures a subscription
+type SubscribeOption func(*Subscription)
+
+// WithBuffer sets how many temperatures wait for the subscriber before the overflow policy applies
+func WithBuffer(n int) SubscribeOption {
+	return func(s *Subscription) {
+		s.c = make(chan core.Temperature, n)
+	}
+}
+
+// WithOverflow sets the policy applied once the buffer is full, DropNewest by default
+func WithOverflow(policy OverflowPolicy) SubscribeOption {
+	return func(s *Subscription) {
+		s.overflow = policy
+	}
+}
+
+// ResumeAfter replays the temperatures of the history created after the one of id,
+// temperature ids grow as they are created
+func ResumeAfter(id int64) SubscribeOption {
+	return func(s *Subscription) {
+		s.after = id
+	}
+}
+
+// Subscribe returns a subscription to the temperatures created for the cities of the tenant from now on.
+// Close the subscription once done, subscriptions to a closed broker are closed already.
+func (b *Broker) Subscribe(tenantID int64, cityIDs []int64, opts ...SubscribeOption) *Subscription {
+	s := &Subscription{broker: b, c: make(chan core.Temperature, DefaultSubscriptionBuffer)}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.C = s.c
+	for _, cityID := range cityIDs {
+		s.topics = append(s.topics, topic{tenantID: tenantID, cityID: cityID})
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		s.closed = true
+		close(s.c)
+		return s
+	}
+
+	for _, t := range s.topics {
+		if b.subscribers[t] == nil {
+			b.subscribers[t] = make(map[*Subscription]struct{})
+		}
+		b.subscribers[t][s] = struct{}{}
+	}
+	if s.after > 0 {
+		s.Replay = b.replay(s.topics, s.after)
+	}
+	metrics.StreamSubscribers.Inc()
+	return s
+}
+
+// replay returns the temperatures of the topics created after the one of id, in order.
+// It must be called with the lock held.
+func (b *Broker) replay(topics []topic, id int64) []core.Temperature {
+	var temperatures []core.Temperature
+	for _, t := range topics {
+		h, ok := b.history[t]
+		if !ok {
+			continue
+		}
+		for _, temperature := range h.temperatures {
+			if temperature.ID > id {
+				temperatures = append(temperatures, temperature)
+			}
+		}
+	}
+
+	// histories of several cities are merged by id, they are short and sorted already
+	for i := 1; i < len(temperatures); i++ {
+		for j := i; j > 0 && temperatures[j].ID < temperatures[j-1].ID; j-- {
+			temperatures[j], temperatures[j-1] = temperatures[j-1], temperatures[j]
+		}
+	}
+	return temperatures
+}
+
+// Publish records t in the history of its city and sends it to the subscribers of the city without blocking,
+// the overflow policy of subscribers whose buffer is full applies
+func (b *Broker) Publish(ctx context.Context, t *core.Temperature) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.sweep(now)
+
+	key := topic{tenantID: t.TenantID, cityID: t.CityID}
+	if b.historySize > 0 {
+		h, ok := b.history[key]
+		if !ok {
+			h = &history{}
+			b.history[key] = h
+		}
+		h.add(*t, b.historySize)
+		h.publishedAt = now
+	}
+
+	for s := range b.subscribers[key] {
+		select {
+		case s.c <- *t:
+			continue
+		default:
+		}
+
+		metrics.StreamDropped.Inc()
+		logger := logging.FromContext(ctx).WithField("city_id", t.CityID)
+		if s.overflow == Disconnect {
+			logger.Warning("broker: subscriber too slow, disconnecting it")
+			s.err = ErrSlowSubscriber
+			s.unsubscribe()
+			continue
+		}
+		logger.Warning("broker: subscriber too slow, dropping temperature")
+	}
+	return nil
+}
+
+// add inserts t by id, listeners may publish temperatures out of order, and keeps the size most recent ones
+func (h *history) add(t core.Temperature, size int) {
+	i := sort.Search(len(h.temperatures), func(i int) bool { return h.temperatures[i].ID > t.ID })
+	h.temperatures = append(h.temperatures, core.Temperature{})
+	copy(h.temperatures[i+1:], h.temperatures[i:])
+	h.temperatures[i] = t
+
+	if len(h.temperatures) > size {
+		h.temperatures = h.temperatures[len(h.temperatures)-size:]
+	}
+}
+
+// sweep drops the history of the cities without subscribers that were not published to within the retention,
+// they are checked at most once per retention or minute. It must be called with the lock held.
+func (b *Broker) sweep(now time.Time) {
+	if b.historyRetention <= 0 {
+		return
+	}
+	interval := b.historyRetention
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	if now.Sub(b.lastSweep) < interval {
+		return
+	}
+	b.lastSweep = now
+
+	for t, h := range b.history {
+		if len(b.subscribers[t]) == 0 && now.Sub(h.publishedAt) > b.historyRetention {
+			delete(b.history, t)
+		}
+	}
+}
+
+// Close closes every subscription so that their subscribers stop, e.g before shutting the servers down
+// as they wait for streams to end
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for _, subscriptions := range b.subscribers {
+		for s := range subscriptions {
+			s.unsubscribe()
+		}
+	}
+}
+
+// Close unsubscribes and closes C, it is safe to call more than once
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.unsubscribe()
+}
+
+// Err returns ErrSlowSubscriber once the subscription was closed by the Disconnect policy, nil otherwise
+func (s *Subscription) Err() error {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	return s.err
+}
+
+// unsubscribe removes the subscription from the broker and closes C unless it was closed already.
+// Every path closing a subscription goes through it with the broker lock held, the only lock involved.
+func (s *Subscription) unsubscribe() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+
+	b := s.broker
+	for _, t := range s.topics {
+		delete(b.subscribers[t], s)
+		if len(b.subscribers[t]) == 0 {
+			delete(b.subscribers, t)
+		}
+	}
+	close(s.c)
+	metrics.StreamSubscribers.Dec()
+}
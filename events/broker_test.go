@@ -0,0 +1,242 @@
+package events_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/events"
+	"github.com/walez/weather-monster/logging"
+	"github.com/walez/weather-monster/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ids(temperatures []core.Temperature) []int64 {
+	var ids []int64
+	for _, t := range temperatures {
+		ids = append(ids, t.ID)
+	}
+	return ids
+}
+
+func TestBroker(t *testing.T) {
+	ctx := context.Background()
+	b := events.NewBroker(events.WithHistory(2))
+
+	city := b.Subscribe(1, []int64{10}, events.WithBuffer(1))
+	defer city.Close()
+	otherTenant := b.Subscribe(2, []int64{10}, events.WithBuffer(1))
+	defer otherTenant.Close()
+
+	t.Run("should publish to the subscribers of the city", func(t *testing.T) {
+		require.NoError(t, b.Publish(ctx, &core.Temperature{ID: 1, TenantID: 1, CityID: 10}))
+		require.NoError(t, b.Publish(ctx, &core.Temperature{ID: 2, TenantID: 1, CityID: 11}))
+
+		received := <-city.C
+		assert.Equal(t, int64(1), received.ID)
+		assert.Empty(t, city.C)
+		assert.Empty(t, otherTenant.C, "cities are scoped to their tenant")
+	})
+
+	t.Run("should drop temperatures of slow subscribers", func(t *testing.T) {
+		dropped := testutil.ToFloat64(metrics.StreamDropped)
+
+		require.NoError(t, b.Publish(ctx, &core.Temperature{ID: 3, TenantID: 2, CityID: 10}))
+		require.NoError(t, b.Publish(ctx, &core.Temperature{ID: 4, TenantID: 2, CityID: 10}))
+
+		received := <-otherTenant.C
+		assert.Equal(t, int64(3), received.ID)
+		assert.Equal(t, dropped+1, testutil.ToFloat64(metrics.StreamDropped))
+		assert.NoError(t, otherTenant.Err())
+	})
+
+	t.Run("should disconnect slow subscribers", func(t *testing.T) {
+		s := b.Subscribe(1, []int64{20}, events.WithBuffer(1), events.WithOverflow(events.Disconnect))
+		require.NoError(t, b.Publish(ctx, &core.Temperature{ID: 5, TenantID: 1, CityID: 20}))
+		require.NoError(t, b.Publish(ctx, &core.Temperature{ID: 6, TenantID: 1, CityID: 20}))
+
+		received, ok := <-s.C
+		assert.True(t, ok, "buffered temperatures are received")
+		assert.Equal(t, int64(5), received.ID)
+		_, ok = <-s.C
+		assert.False(t, ok)
+		assert.Equal(t, events.ErrSlowSubscriber, s.Err())
+		s.Close()
+	})
+
+	t.Run("should subscribe to several cities and resume from the history", func(t *testing.T) {
+		require.NoError(t, b.Publish(ctx, &core.Temperature{ID: 7, TenantID: 1, CityID: 20}))
+		require.NoError(t, b.Publish(ctx, &core.Temperature{ID: 8, TenantID: 1, CityID: 10}))
+
+		s := b.Subscribe(1, []int64{10, 20}, events.ResumeAfter(5))
+		defer s.Close()
+		assert.Equal(t, []int64{6, 7, 8}, ids(s.Replay), "history keeps the most recent temperatures of each city")
+
+		require.NoError(t, b.Publish(ctx, &core.Temperature{ID: 9, TenantID: 1, CityID: 20}))
+		received := <-s.C
+		assert.Equal(t, int64(9), received.ID)
+
+		assert.Empty(t, b.Subscribe(1, []int64{10}).Replay, "nothing is replayed unless resuming")
+	})
+
+	t.Run("should stop publishing once closed", func(t *testing.T) {
+		s := b.Subscribe(1, []int64{12}, events.WithBuffer(1))
+		s.Close()
+		s.Close()
+
+		require.NoError(t, b.Publish(ctx, &core.Temperature{ID: 10, TenantID: 1, CityID: 12}))
+		_, ok := <-s.C
+		assert.False(t, ok)
+	})
+
+	t.Run("should close subscriptions once closed", func(t *testing.T) {
+		s := b.Subscribe(1, []int64{13})
+		b.Close()
+
+		_, ok := <-s.C
+		assert.False(t, ok)
+		assert.NoError(t, s.Err())
+		_, ok = <-b.Subscribe(1, []int64{13}).C
+		assert.False(t, ok, "subscribing to a closed broker")
+		s.Close()
+	})
+}
+
+func TestBroker_History(t *testing.T) {
+	ctx := context.Background()
+	b := events.NewBroker(events.WithHistory(3), events.WithHistoryRetention(20*time.Millisecond))
+
+	t.Run("should keep the history ordered by id", func(t *testing.T) {
+		for _, id := range []int64{2, 4, 1, 3, 5} {
+			require.NoError(t, b.Publish(ctx, &core.Temperature{ID: id, TenantID: 1, CityID: 10}))
+		}
+
+		s := b.Subscribe(1, []int64{10}, events.ResumeAfter(1))
+		defer s.Close()
+		assert.Equal(t, []int64{3, 4, 5}, ids(s.Replay), "temperatures published late are not kept over more recent ones")
+	})
+
+	t.Run("should drop the history of cities without subscribers after the retention", func(t *testing.T) {
+		s := b.Subscribe(1, []int64{20})
+		require.NoError(t, b.Publish(ctx, &core.Temperature{ID: 6, TenantID: 1, CityID: 20}))
+		require.NoError(t, b.Publish(ctx, &core.Temperature{ID: 7, TenantID: 1, CityID: 30}))
+
+		time.Sleep(30 * time.Millisecond)
+		require.NoError(t, b.Publish(ctx, &core.Temperature{ID: 8, TenantID: 1, CityID: 40}))
+
+		resumed := b.Subscribe(1, []int64{20, 30}, events.ResumeAfter(1))
+		defer resumed.Close()
+		assert.Equal(t, []int64{6}, ids(resumed.Replay), "cities with subscribers keep their history")
+		s.Close()
+	})
+}
+
+// finishes fails the test unless f returns within a second, e.g when it deadlocks
+func finishes(t *testing.T, f func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("deadlocked")
+	}
+}
+
+// blockingWriter blocks the logs written to it until released, so that a log line holds the caller where it is
+type blockingWriter struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func newBlockingWriter() *blockingWriter {
+	return &blockingWriter{entered: make(chan struct{}, 1), release: make(chan struct{})}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	select {
+	case w.entered <- struct{}{}:
+	default:
+	}
+	<-w.release
+	return len(p), nil
+}
+
+// publishBlocked publishes t to a subscriber whose buffer is full and returns once Publish is logging it,
+// with the broker lock held, until w is released
+func publishBlocked(t *testing.T, b *events.Broker, temperature *core.Temperature) (*blockingWriter, chan struct{}) {
+	w := newBlockingWriter()
+	logger := logrus.New()
+	logger.SetOutput(w)
+	ctx := logging.WithLogger(context.Background(), logrus.NewEntry(logger))
+
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		assert.NoError(t, b.Publish(ctx, temperature))
+	}()
+	<-w.entered
+	return w, published
+}
+
+func TestBroker_CloseWhileDisconnecting(t *testing.T) {
+	b := events.NewBroker()
+	s := b.Subscribe(1, []int64{10}, events.WithBuffer(1), events.WithOverflow(events.Disconnect))
+	require.NoError(t, b.Publish(context.Background(), &core.Temperature{ID: 1, TenantID: 1, CityID: 10}))
+
+	w, published := publishBlocked(t, b, &core.Temperature{ID: 2, TenantID: 1, CityID: 10})
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		s.Close()
+	}()
+	// the subscriber goes away while Publish is disconnecting it
+	time.Sleep(20 * time.Millisecond)
+	close(w.release)
+
+	finishes(t, func() {
+		<-published
+		<-closed
+	})
+	assert.Equal(t, events.ErrSlowSubscriber, s.Err())
+}
+
+func TestBroker_CloseWhileClosingSubscriptions(t *testing.T) {
+	b := events.NewBroker()
+	s := b.Subscribe(1, []int64{10}, events.WithBuffer(1))
+	require.NoError(t, b.Publish(context.Background(), &core.Temperature{ID: 1, TenantID: 1, CityID: 10}))
+
+	// the broker and a subscription are closed together, e.g at shutdown as a stream ends
+	w, published := publishBlocked(t, b, &core.Temperature{ID: 2, TenantID: 1, CityID: 10})
+	brokerClosed, closed := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(brokerClosed)
+		b.Close()
+	}()
+	time.Sleep(20 * time.Millisecond)
+	go func() {
+		defer close(closed)
+		s.Close()
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(w.release)
+
+	finishes(t, func() {
+		<-published
+		<-brokerClosed
+		<-closed
+	})
+	_, ok := <-s.C
+	assert.True(t, ok, "buffered temperatures are received")
+	_, ok = <-s.C
+	assert.False(t, ok)
+}
@@ -2,10 +2,14 @@ package events
 
 import (
 	"context"
+	"sync"
 
 	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/logging"
+	"github.com/walez/weather-monster/metrics"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 )
 
 type Name string
@@ -14,14 +18,72 @@ const (
 	TemperatureCreated = Name("temperature_created")
 )
 
+// Defaults of the listener worker pool
+const (
+	DefaultWorkers   = 10
+	DefaultQueueSize = 1000
+)
+
 type Manager struct {
+	ctx               context.Context
 	temperatureEvents map[Name][]TemperatureListener
+
+	workers int
+	queue   chan job
+	running sync.WaitGroup
+
+	// mu guards the queue from being closed while events are queued
+	mu     sync.RWMutex
+	closed bool
+}
+
+// job is a listener invocation waiting for a worker
+type job struct {
+	event       Name
+	listener    TemperatureListener
+	temperature core.Temperature
+	// trace is the span context of the notifier, nil when it was not traced
+	trace opentracing.SpanContext
+	// requestID correlates the listener logs with the request that notified it
+	requestID string
+}
+
+// Option configures optional behaviour of the events manager
+type Option func(*Manager)
+
+// WithWorkers sets how many listeners run concurrently
+func WithWorkers(n int) Option {
+	return func(m *Manager) {
+		m.workers = n
+	}
 }
 
-func NewManager() *Manager {
-	return &Manager{
+// WithQueueSize sets how many listener invocations may wait for a worker
+// before notifying blocks the caller
+func WithQueueSize(n int) Option {
+	return func(m *Manager) {
+		m.queue = make(chan job, n)
+	}
+}
+
+// NewManager creates an events manager running listeners on a pool of workers,
+// listener contexts are derived from ctx so cancelling it aborts running listeners and stops the workers
+func NewManager(ctx context.Context, opts ...Option) *Manager {
+	m := &Manager{
+		ctx:               ctx,
 		temperatureEvents: make(map[Name][]TemperatureListener),
+		workers:           DefaultWorkers,
+		queue:             make(chan job, DefaultQueueSize),
+	}
+	for _, opt := range opts {
+		opt(m)
 	}
+
+	m.running.Add(m.workers)
+	for i := 0; i < m.workers; i++ {
+		go m.work()
+	}
+	return m
 }
 
 func (m *Manager) RegisterTemperatureListener(eventName Name, f TemperatureListener) {
@@ -34,18 +96,105 @@ func (m *Manager) RegisterTemperatureListener(eventName Name, f TemperatureListe
 	m.temperatureEvents[eventName] = l
 }
 
-func (m *Manager) NotifyTemperatureListeners(eventName Name, t *core.Temperature) {
-	for i := range m.temperatureEvents[eventName] {
-		go func(l TemperatureListener, t core.Temperature) {
-			ctx, cancel := context.WithCancel(context.TODO())
-			defer cancel()
+// NotifyTemperatureListeners queues a listener invocation per registered listener,
+// it blocks while the queue is full and drops the event once the manager context is done.
+// Listeners do not run under ctx, it only links their spans and logs to the trace and request of the notifier.
+func (m *Manager) NotifyTemperatureListeners(ctx context.Context, eventName Name, t *core.Temperature) {
+	var trace opentracing.SpanContext
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		trace = span.Context()
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		logging.FromContext(ctx).Warningf("events manager shut down, dropping %s event", eventName)
+		return
+	}
+
+	for _, l := range m.temperatureEvents[eventName] {
+		select {
+		case m.queue <- job{event: eventName, listener: l, temperature: *t, trace: trace, requestID: core.RequestIDFromContext(ctx)}:
+			metrics.EventsQueueDepth.Inc()
+		case <-m.ctx.Done():
+			logging.FromContext(ctx).Warningf("events manager stopped, dropping %s event", eventName)
+			return
+		}
+	}
+}
+
+// Saturation returns the fraction of the queue in use, always 0 for an unbuffered queue
+func (m *Manager) Saturation() float64 {
+	if cap(m.queue) == 0 {
+		return 0
+	}
+	return float64(len(m.queue)) / float64(cap(m.queue))
+}
+
+// Shutdown stops accepting events and waits until the queued ones have been handled by their listeners.
+// When ctx is done first its error is returned, cancelling the manager context then aborts the remaining listeners.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	if !m.closed {
+		m.closed = true
+		close(m.queue)
+	}
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *Manager) work() {
+	defer m.running.Done()
 
-			err := l(ctx, &t)
-			if err != nil {
-				log.Warningf("error running listener for Temperature: %v", err)
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case j, ok := <-m.queue:
+			if !ok {
 				return
 			}
-			log.Info("temperature listeners triggered")
-		}(m.temperatureEvents[eventName][i], *t)
+			metrics.EventsQueueDepth.Dec()
+			m.run(j)
+		}
+	}
+}
+
+func (m *Manager) run(j job) {
+	var opts []opentracing.StartSpanOption
+	if j.trace != nil {
+		opts = append(opts, opentracing.FollowsFrom(j.trace))
+	}
+	span := opentracing.StartSpan("events."+string(j.event), opts...)
+	defer span.Finish()
+
+	ctx, cancel := context.WithCancel(core.WithTenant(m.ctx, j.temperature.TenantID))
+	defer cancel()
+	ctx = opentracing.ContextWithSpan(ctx, span)
+	if j.requestID != "" {
+		ctx = core.WithRequestID(ctx, j.requestID)
+	}
+	logger := logging.FromContext(ctx).WithField("event", j.event)
+
+	err := j.listener(ctx, &j.temperature)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogKV("error", err.Error())
+		metrics.ListenerErrors.WithLabelValues(string(j.event)).Inc()
+		logger.WithError(err).Warning("events: listener failed")
+		return
 	}
+	logger.Debug("events: listener ran")
 }
@@ -0,0 +1,153 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/events"
+	"github.com/walez/weather-monster/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := events.NewManager(ctx, events.WithWorkers(1), events.WithQueueSize(1))
+
+	release := make(chan struct{})
+	received := make(chan *core.Temperature, 3)
+	m.RegisterTemperatureListener(events.TemperatureCreated, func(ctx context.Context, temperature *core.Temperature) error {
+		tenantID, _ := core.TenantFromContext(ctx)
+		assert.Equal(t, temperature.TenantID, tenantID, "listeners run scoped to the event tenant")
+
+		<-release
+		received <- temperature
+		if temperature.ID == 3 {
+			return errors.New("listener failed")
+		}
+		return nil
+	})
+
+	errorsBefore := testutil.ToFloat64(metrics.ListenerErrors.WithLabelValues(string(events.TemperatureCreated)))
+
+	// the first event is picked by the worker and the second fills the queue
+	m.NotifyTemperatureListeners(ctx, events.TemperatureCreated, &core.Temperature{ID: 1, TenantID: 2})
+	m.NotifyTemperatureListeners(ctx, events.TemperatureCreated, &core.Temperature{ID: 2, TenantID: 2})
+
+	notified := make(chan struct{})
+	go func() {
+		m.NotifyTemperatureListeners(ctx, events.TemperatureCreated, &core.Temperature{ID: 3, TenantID: 2})
+		close(notified)
+	}()
+
+	select {
+	case <-notified:
+		t.Fatal("notifying should block while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, float64(1), m.Saturation())
+
+	close(release)
+	<-notified
+	for i := int64(1); i <= 3; i++ {
+		select {
+		case temperature := <-received:
+			assert.Equal(t, i, temperature.ID, "events are delivered in order by a single worker")
+		case <-time.After(time.Second):
+			t.Fatalf("event %d not delivered", i)
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(metrics.ListenerErrors.WithLabelValues(string(events.TemperatureCreated))) == errorsBefore+1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestManager_Stopped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := events.NewManager(ctx, events.WithWorkers(1), events.WithQueueSize(0))
+	m.RegisterTemperatureListener(events.TemperatureCreated, func(ctx context.Context, temperature *core.Temperature) error {
+		return nil
+	})
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.NotifyTemperatureListeners(ctx, events.TemperatureCreated, &core.Temperature{ID: 1})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("notifying a stopped manager should not block")
+	}
+}
+
+func TestManager_Shutdown(t *testing.T) {
+	t.Run("should deliver queued events", func(t *testing.T) {
+		m := events.NewManager(context.Background(), events.WithWorkers(2), events.WithQueueSize(100))
+
+		var mu sync.Mutex
+		delivered := map[int64]bool{}
+		m.RegisterTemperatureListener(events.TemperatureCreated, func(ctx context.Context, temperature *core.Temperature) error {
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			delivered[temperature.ID] = true
+			mu.Unlock()
+			return nil
+		})
+
+		for i := int64(1); i <= 50; i++ {
+			m.NotifyTemperatureListeners(context.Background(), events.TemperatureCreated, &core.Temperature{ID: i})
+		}
+
+		require.NoError(t, m.Shutdown(context.Background()))
+		assert.Len(t, delivered, 50, "no event is lost on shutdown")
+
+		// events notified once shut down are dropped
+		m.NotifyTemperatureListeners(context.Background(), events.TemperatureCreated, &core.Temperature{ID: 51})
+		assert.NoError(t, m.Shutdown(context.Background()), "shutting down twice is a no-op")
+		assert.Len(t, delivered, 50)
+	})
+
+	t.Run("should give up at the deadline", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		m := events.NewManager(ctx, events.WithWorkers(1), events.WithQueueSize(1))
+		release := make(chan struct{})
+		defer close(release)
+		m.RegisterTemperatureListener(events.TemperatureCreated, func(ctx context.Context, temperature *core.Temperature) error {
+			<-release
+			return nil
+		})
+		m.NotifyTemperatureListeners(ctx, events.TemperatureCreated, &core.Temperature{ID: 1})
+
+		deadline, cancelDeadline := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancelDeadline()
+		assert.Equal(t, context.DeadlineExceeded, m.Shutdown(deadline))
+	})
+}
+
+func TestManager_RequestID(t *testing.T) {
+	m := events.NewManager(context.Background())
+
+	requestIDs := make(chan string, 1)
+	m.RegisterTemperatureListener(events.TemperatureCreated, func(ctx context.Context, temperature *core.Temperature) error {
+		requestIDs <- core.RequestIDFromContext(ctx)
+		return nil
+	})
+
+	m.NotifyTemperatureListeners(core.WithRequestID(context.Background(), "request-1"), events.TemperatureCreated, &core.Temperature{ID: 1})
+	require.NoError(t, m.Shutdown(context.Background()))
+	assert.Equal(t, "request-1", <-requestIDs, "listeners log with the request id of the notifier")
+}
@@ -0,0 +1,103 @@
+// Package gql serves the weather entities over GraphQL, so that clients fetch cities with their forecasts,
+// temperatures and webhooks in a single request. Lookups are batched per request to avoid a query per city.
+package gql
+
+import (
+	"context"
+	_ "embed"
+	"net/http"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/logging"
+	"github.com/walez/weather-monster/weather"
+
+	"github.com/gin-gonic/gin"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// Path is where the GraphQL endpoint is served, it is not versioned as the schema evolves by adding fields
+const Path = "graphql"
+
+// maxDepth bounds the nesting of queries, e.g city { temperatures { city { ... } } }
+const maxDepth = 8
+
+//go:embed schema.graphql
+var schema string
+
+// Request is a GraphQL query with its variables
+type Request struct {
+	Query         string                 `json:"query" binding:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Handler executes GraphQL requests
+type Handler struct {
+	schema    *graphql.Schema
+	ws        core.WeatherService
+	rateLimit *RateLimit
+}
+
+// Option configures optional behaviour of the handler
+type Option func(*Handler)
+
+// WithRateLimit charges mutations to the rate limits of their REST routes, they are not limited by default
+func WithRateLimit(rl RateLimit) Option {
+	return func(h *Handler) {
+		h.rateLimit = &rl
+	}
+}
+
+// NewHandler resolves queries with ws and mutations with h
+func NewHandler(h *weather.Handler, ws core.WeatherService, opts ...Option) *Handler {
+	handler := &Handler{
+		ws:        ws,
+		rateLimit: &RateLimit{},
+	}
+	for _, opt := range opts {
+		opt(handler)
+	}
+
+	handler.schema = graphql.MustParseSchema(schema, &resolver{h: h, ws: ws, rateLimit: handler.rateLimit},
+		graphql.MaxDepth(maxDepth),
+		// the fields of a whole page are resolved at once so that they are loaded in a single batch
+		graphql.MaxParallelism(maxCities),
+		graphql.Logger(panicLogger{}),
+	)
+	return handler
+}
+
+// RegisterRoutes adds the GraphQL endpoint, rg must authenticate requests with auth.Middleware.
+// Fields check the scopes of the principal like the REST routes do.
+func (h *Handler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.POST(Path, h.handleRequest)
+}
+
+func (h *Handler) handleRequest(ctx *gin.Context) {
+	body := &Request{}
+
+	err := ctx.ShouldBindJSON(body)
+	if err != nil {
+		logging.FromContext(ctx.Request.Context()).WithError(err).Error("graphql: error processing request")
+		ctx.SecureJSON(http.StatusBadRequest, weather.Response{
+			Status:  false,
+			Message: "request failure",
+		})
+		return
+	}
+
+	logging.FromContext(ctx.Request.Context()).WithField("operation", body.OperationName).Debug("graphql: executing query")
+	c := withLoaders(ctx.Request.Context(), newLoaders(h.ws))
+	c = withClientIP(c, h.rateLimit.Proxies.ClientIP(ctx.Request))
+	response := h.schema.Exec(c, body.Query, body.OperationName, body.Variables)
+
+	// errors are part of the response, e.g a field the principal is not allowed to read
+	ctx.JSON(http.StatusOK, response)
+}
+
+// panicLogger logs the panics of resolvers, their field resolves to an error
+type panicLogger struct{}
+
+func (panicLogger) LogPanic(ctx context.Context, value interface{}) {
+	logging.FromContext(ctx).WithField("panic", value).Error("graphql: panic resolving field")
+}
@@ -0,0 +1,176 @@
+package gql_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/auth"
+	"github.com/walez/weather-monster/events"
+	"github.com/walez/weather-monster/gql"
+	mocks "github.com/walez/weather-monster/mocks"
+	"github.com/walez/weather-monster/ratelimit"
+	"github.com/walez/weather-monster/weather"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string `json:"message"`
+		Extensions struct {
+			Code string `json:"code"`
+		} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// query posts query to the router with the scopes granted to the tenant 1
+func query(t *testing.T, h *gql.Handler, query string, scopes ...string) response {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h.RegisterRoutes(r.Group("/", auth.Middleware(auth.DefaultTenant(&core.Tenant{ID: 1}, scopes...))))
+
+	body, err := json.Marshal(gql.Request{Query: query})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	res := response{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	return res
+}
+
+func TestHandler(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ws := mocks.NewMockWeatherService(mockCtrl)
+	// created temperatures are sent to the webhooks of their city
+	ws.EXPECT().GetCityWebhooks(gomock.Any(), gomock.Any()).AnyTimes()
+	audit := mocks.NewMockAuditService(mockCtrl)
+	h := gql.NewHandler(weather.NewHandler(ws, events.NewManager(context.Background()), audit), ws)
+
+	t.Run("should load the fields of every city in a single query", func(t *testing.T) {
+		ws.EXPECT().ListCities(gomock.Any(), int64(0), 3).Return([]*core.City{
+			{ID: 1, Name: "City One"}, {ID: 2, Name: "City Two"}, {ID: 3, Name: "City Three"},
+		}, nil)
+		ws.EXPECT().GetCitiesForecasts(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, ids []int64) ([]*core.Forecast, error) {
+			assert.ElementsMatch(t, []int64{1, 2, 3}, ids)
+			return []*core.Forecast{{CityID: 1, Max: 10, Sample: 2}}, nil
+		})
+		ws.EXPECT().GetCitiesTemperatures(gomock.Any(), gomock.Any(), 2).DoAndReturn(func(ctx context.Context, ids []int64, limit int) ([]*core.Temperature, error) {
+			assert.ElementsMatch(t, []int64{1, 2, 3}, ids)
+			return []*core.Temperature{{ID: 7, CityID: 2, Max: 20, Timestamp: time.Unix(0, 0).Unix()}}, nil
+		})
+
+		res := query(t, h, `{ cities(first: 3) { id forecast { max } temperatures(last: 2) { id timestamp city { name } } } }`,
+			core.ScopeForecastsRead)
+		assert.Empty(t, res.Errors)
+		assert.JSONEq(t, `{"cities": [
+			{"id": "1", "forecast": {"max": 10}, "temperatures": []},
+			{"id": "2", "forecast": null, "temperatures": [{"id": "7", "timestamp": "1970-01-01T00:00:00Z", "city": {"name": "City Two"}}]},
+			{"id": "3", "forecast": null, "temperatures": []}
+		]}`, string(res.Data))
+	})
+
+	t.Run("should resolve missing cities to null", func(t *testing.T) {
+		ws.EXPECT().FindCitiesByIDs(gomock.Any(), []int64{9}).Return(nil, nil)
+
+		res := query(t, h, `{ city(id: "9") { name } }`, core.ScopeForecastsRead)
+		assert.Empty(t, res.Errors)
+		assert.JSONEq(t, `{"city": null}`, string(res.Data))
+	})
+
+	t.Run("should require the scope of webhooks", func(t *testing.T) {
+		ws.EXPECT().FindCitiesByIDs(gomock.Any(), []int64{1}).Return([]*core.City{{ID: 1}}, nil)
+
+		res := query(t, h, `{ city(id: "1") { id webhooks { id } } }`, core.ScopeForecastsRead)
+		require.Len(t, res.Errors, 1)
+		assert.Equal(t, "forbidden", res.Errors[0].Message)
+	})
+
+	t.Run("should require the scope of forecasts to read cities from any field", func(t *testing.T) {
+		ws.EXPECT().CreateTemperature(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, temperature *core.Temperature) error {
+			temperature.ID = 11
+			return nil
+		})
+
+		res := query(t, h, `mutation { createTemperature(cityId: "1", max: 20, min: 10) { id city { name forecast { max } } } }`,
+			core.ScopeTemperaturesWrite)
+		require.Len(t, res.Errors, 1)
+		assert.Equal(t, "forbidden", res.Errors[0].Message)
+		assert.JSONEq(t, `{"createTemperature": {"id": "11", "city": null}}`, string(res.Data))
+
+		ws.EXPECT().FindWebhookByID(gomock.Any(), int64(4)).Return(&core.Webhook{ID: 4, CityID: 1}, nil)
+		ws.EXPECT().DeleteWebhook(gomock.Any(), gomock.Any()).Return(nil)
+		audit.EXPECT().CreateAuditEntry(gomock.Any(), gomock.Any()).Return(nil)
+
+		res = query(t, h, `mutation { deleteWebhook(id: "4") { id city { temperatures(last: 1) { id } } } }`, core.ScopeWebhooksManage)
+		require.Len(t, res.Errors, 1)
+		assert.Equal(t, "forbidden", res.Errors[0].Message)
+	})
+
+	t.Run("should create temperatures with the weather handler", func(t *testing.T) {
+		ws.EXPECT().CreateTemperature(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, temperature *core.Temperature) error {
+			temperature.ID = 10
+			return nil
+		})
+
+		res := query(t, h, `mutation { createTemperature(cityId: "1", max: 20, min: 10) { id max min } }`,
+			core.ScopeTemperaturesWrite)
+		assert.Empty(t, res.Errors)
+		assert.JSONEq(t, `{"createTemperature": {"id": "10", "max": 20, "min": 10}}`, string(res.Data))
+	})
+
+	t.Run("should detail quota errors only", func(t *testing.T) {
+		ws.EXPECT().CreateTemperature(gomock.Any(), gomock.Any()).Return(core.ErrQuotaExceeded)
+		ws.EXPECT().FindWebhookByID(gomock.Any(), int64(4)).Return(nil, assert.AnError)
+
+		res := query(t, h, `mutation { createTemperature(cityId: "1", max: 20, min: 10) { id } }`, core.ScopeTemperaturesWrite)
+		require.Len(t, res.Errors, 1)
+		assert.Equal(t, core.ErrQuotaExceeded.Error(), res.Errors[0].Message)
+		assert.Equal(t, "QUOTA_EXCEEDED", res.Errors[0].Extensions.Code)
+
+		res = query(t, h, `mutation { deleteWebhook(id: "4") { id } }`, core.ScopeWebhooksManage)
+		require.Len(t, res.Errors, 1)
+		assert.Equal(t, "request failure", res.Errors[0].Message)
+		assert.Equal(t, "INTERNAL", res.Errors[0].Extensions.Code)
+	})
+}
+
+func TestHandler_RateLimit(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ws := mocks.NewMockWeatherService(mockCtrl)
+	ws.EXPECT().GetCityWebhooks(gomock.Any(), gomock.Any()).AnyTimes()
+	ws.EXPECT().CreateTemperature(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, temperature *core.Temperature) error {
+		temperature.ID = 10
+		return nil
+	}).Times(2)
+	h := gql.NewHandler(weather.NewHandler(ws, events.NewManager(context.Background()), mocks.NewMockAuditService(mockCtrl)), ws,
+		gql.WithRateLimit(gql.RateLimit{
+			Limiter: ratelimit.New(),
+			Default: ratelimit.PerMinute(100),
+			Routes:  map[string]ratelimit.Limit{"POST /temperatures": ratelimit.PerMinute(2)},
+		}))
+
+	res := query(t, h, `mutation {
+		first: createTemperature(cityId: "1", max: 20, min: 10) { id }
+		second: createTemperature(cityId: "1", max: 21, min: 11) { id }
+		third: createTemperature(cityId: "1", max: 22, min: 12) { id }
+	}`, core.ScopeTemperaturesWrite)
+	require.Len(t, res.Errors, 1, "each mutation is charged to the limit of its REST route")
+	assert.Equal(t, "too many requests", res.Errors[0].Message)
+	assert.Equal(t, "RATE_LIMITED", res.Errors[0].Extensions.Code)
+}
@@ -0,0 +1,165 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	core "github.com/walez/weather-monster"
+
+	"github.com/graph-gophers/dataloader"
+)
+
+// loaders batch the lookups of a request so that resolving a field of every city of a page
+// runs a single query rather than a query per city, they also cache entities for the request
+type loaders struct {
+	cities       *dataloader.Loader
+	forecasts    *dataloader.Loader
+	webhooks     *dataloader.Loader
+	temperatures *dataloader.Loader
+}
+
+func newLoaders(ws core.WeatherService) *loaders {
+	return &loaders{
+		cities:       dataloader.NewBatchedLoader(batchCities(ws)),
+		forecasts:    dataloader.NewBatchedLoader(batchForecasts(ws)),
+		webhooks:     dataloader.NewBatchedLoader(batchWebhooks(ws)),
+		temperatures: dataloader.NewBatchedLoader(batchTemperatures(ws)),
+	}
+}
+
+type loadersKey struct{}
+
+// withLoaders returns a copy of ctx carrying the loaders of a request
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+// cityKey loads an entity by the id of its city
+type cityKey int64
+
+func (k cityKey) String() string   { return strconv.FormatInt(int64(k), 10) }
+func (k cityKey) Raw() interface{} { return int64(k) }
+
+// temperaturesKey loads the last temperatures of a city
+type temperaturesKey struct {
+	cityID int64
+	last   int
+}
+
+func (k temperaturesKey) String() string   { return fmt.Sprintf("%d:%d", k.cityID, k.last) }
+func (k temperaturesKey) Raw() interface{} { return k }
+
+func cityIDs(keys dataloader.Keys) []int64 {
+	ids := make([]int64, len(keys))
+	for i, key := range keys {
+		ids[i] = key.Raw().(int64)
+	}
+	return ids
+}
+
+// failed fails every key of a batch with err
+func failed(keys dataloader.Keys, err error) []*dataloader.Result {
+	results := make([]*dataloader.Result, len(keys))
+	for i := range keys {
+		results[i] = &dataloader.Result{Error: err}
+	}
+	return results
+}
+
+// batchCities loads cities by id, missing cities load as nil
+func batchCities(ws core.WeatherService) dataloader.BatchFunc {
+	return func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+		cities, err := ws.FindCitiesByIDs(ctx, cityIDs(keys))
+		if err != nil {
+			return failed(keys, err)
+		}
+
+		byID := make(map[int64]*core.City, len(cities))
+		for _, city := range cities {
+			byID[city.ID] = city
+		}
+
+		results := make([]*dataloader.Result, len(keys))
+		for i, key := range keys {
+			results[i] = &dataloader.Result{Data: byID[key.Raw().(int64)]}
+		}
+		return results
+	}
+}
+
+// batchForecasts loads the forecasts of cities, cities without readings load as nil
+func batchForecasts(ws core.WeatherService) dataloader.BatchFunc {
+	return func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+		forecasts, err := ws.GetCitiesForecasts(ctx, cityIDs(keys))
+		if err != nil {
+			return failed(keys, err)
+		}
+
+		byCity := make(map[int64]*core.Forecast, len(forecasts))
+		for _, forecast := range forecasts {
+			byCity[forecast.CityID] = forecast
+		}
+
+		results := make([]*dataloader.Result, len(keys))
+		for i, key := range keys {
+			results[i] = &dataloader.Result{Data: byCity[key.Raw().(int64)]}
+		}
+		return results
+	}
+}
+
+// batchWebhooks loads the webhooks of cities
+func batchWebhooks(ws core.WeatherService) dataloader.BatchFunc {
+	return func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+		webhooks, err := ws.GetCitiesWebhooks(ctx, cityIDs(keys))
+		if err != nil {
+			return failed(keys, err)
+		}
+
+		byCity := make(map[int64][]*core.Webhook)
+		for _, webhook := range webhooks {
+			byCity[webhook.CityID] = append(byCity[webhook.CityID], webhook)
+		}
+
+		results := make([]*dataloader.Result, len(keys))
+		for i, key := range keys {
+			results[i] = &dataloader.Result{Data: byCity[key.Raw().(int64)]}
+		}
+		return results
+	}
+}
+
+// batchTemperatures loads the last temperatures of cities, running a query for each distinct count asked
+func batchTemperatures(ws core.WeatherService) dataloader.BatchFunc {
+	return func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+		byLast := make(map[int][]int64)
+		for _, key := range keys {
+			k := key.Raw().(temperaturesKey)
+			byLast[k.last] = append(byLast[k.last], k.cityID)
+		}
+
+		loaded := make(map[temperaturesKey][]*core.Temperature)
+		for last, ids := range byLast {
+			temperatures, err := ws.GetCitiesTemperatures(ctx, ids, last)
+			if err != nil {
+				return failed(keys, err)
+			}
+
+			for _, temperature := range temperatures {
+				k := temperaturesKey{cityID: temperature.CityID, last: last}
+				loaded[k] = append(loaded[k], temperature)
+			}
+		}
+
+		results := make([]*dataloader.Result, len(keys))
+		for i, key := range keys {
+			results[i] = &dataloader.Result{Data: loaded[key.Raw().(temperaturesKey)]}
+		}
+		return results
+	}
+}
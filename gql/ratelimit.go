@@ -0,0 +1,42 @@
+package gql
+
+import (
+	"context"
+
+	"github.com/walez/weather-monster/ratelimit"
+)
+
+// errRateLimited is answered for the mutations over the rate limit of their REST route
+var errRateLimited = &clientError{message: "too many requests", code: "RATE_LIMITED"}
+
+// RateLimit charges each mutation to the rate limit of the REST route it mirrors, e.g createTemperature
+// to "POST /temperatures", so that the mutations aliased in a single request are limited like as many
+// requests rather than one
+type RateLimit struct {
+	Limiter *ratelimit.Limiter
+	Default ratelimit.Limit
+	Routes  map[string]ratelimit.Limit
+	// Proxies are trusted to forward the address of anonymous clients, see ratelimit.Proxies
+	Proxies ratelimit.Proxies
+}
+
+type clientIPKey struct{}
+
+// withClientIP returns a copy of ctx carrying the IP of the client of a request
+func withClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// charge counts a mutation against the limit of route, failing once the client is over it
+func (rl *RateLimit) charge(ctx context.Context, route string) error {
+	limit := ratelimit.RouteLimit(rl.Default, rl.Routes, route)
+	if rl.Limiter == nil || limit.Rate <= 0 {
+		return nil
+	}
+
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	if !rl.Limiter.Allow(ratelimit.Key(ratelimit.Client(ctx, ip), route), limit).Allowed {
+		return errRateLimited
+	}
+	return nil
+}
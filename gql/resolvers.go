@@ -0,0 +1,444 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/auth"
+	"github.com/walez/weather-monster/logging"
+	"github.com/walez/weather-monster/weather"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// Bounds of the page sizes clients can ask for
+const (
+	maxCities       = 100
+	maxTemperatures = 100
+)
+
+var (
+	errUnauthorized = errors.New("unauthorized")
+	errForbidden    = errors.New("forbidden")
+)
+
+// resolver resolves the queries with the weather service and the loaders of the request, and the mutations
+// with the weather handler so that they behave like the REST api, e.g temperatures created notify webhooks
+type resolver struct {
+	h         *weather.Handler
+	ws        core.WeatherService
+	rateLimit *RateLimit
+}
+
+// requireScope fails unless the principal of the request was granted scope, like auth.RequireScope
+func requireScope(ctx context.Context, scope string) error {
+	p, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return errUnauthorized
+	}
+	if !p.HasScope(scope) {
+		return errForbidden
+	}
+	return nil
+}
+
+// fail logs err and converts it to the error answered to the client, see weather.ClassifyError
+func fail(ctx context.Context, err error) error {
+	logging.FromContext(ctx).WithError(err).Error("graphql: error resolving field")
+
+	kind, message := weather.ClassifyError(err)
+	return &clientError{message: message, code: errorCodes[kind]}
+}
+
+// errorCodes tell each kind of error apart in the extensions of GraphQL errors
+var errorCodes = map[weather.ErrorKind]string{
+	weather.KindInternal:      "INTERNAL",
+	weather.KindInvalid:       "INVALID_ARGUMENT",
+	weather.KindNotFound:      "NOT_FOUND",
+	weather.KindQuotaExceeded: "QUOTA_EXCEEDED",
+}
+
+// clientError is answered for the errors of the weather handler and service
+type clientError struct {
+	message string
+	code    string
+}
+
+func (e *clientError) Error() string {
+	return e.message
+}
+
+// Extensions sets the code of the error in the extensions answered with it
+func (e *clientError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+func parseID(id graphql.ID) (int64, error) {
+	parsed, err := strconv.ParseInt(string(id), 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid id sent")
+	}
+	return parsed, nil
+}
+
+func toID(id int64) graphql.ID {
+	return graphql.ID(strconv.FormatInt(id, 10))
+}
+
+// loadCity loads the city of id through the loaders of the request, nil when it does not exist.
+// Cities are read with the scope of the forecasts, whichever field they are resolved from.
+func loadCity(ctx context.Context, id int64) (*cityResolver, error) {
+	err := requireScope(ctx, core.ScopeForecastsRead)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := loadersFrom(ctx).cities.Load(ctx, cityKey(id))()
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+
+	city := data.(*core.City)
+	if city == nil {
+		return nil, nil
+	}
+	return &cityResolver{city: city}, nil
+}
+
+func (r *resolver) City(ctx context.Context, args struct{ ID graphql.ID }) (*cityResolver, error) {
+	err := requireScope(ctx, core.ScopeForecastsRead)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return loadCity(ctx, id)
+}
+
+func (r *resolver) Cities(ctx context.Context, args struct {
+	First int32
+	After *graphql.ID
+}) ([]*cityResolver, error) {
+	err := requireScope(ctx, core.ScopeForecastsRead)
+	if err != nil {
+		return nil, err
+	}
+
+	if args.First < 1 || args.First > maxCities {
+		return nil, errors.New("first must be between 1 and 100")
+	}
+
+	var cursor int64
+	if args.After != nil {
+		cursor, err = parseID(*args.After)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cities, err := r.ws.ListCities(ctx, cursor, int(args.First))
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+
+	// the cities are cached for the fields loading them again, e.g temperatures { city }
+	l := loadersFrom(ctx)
+	resolvers := make([]*cityResolver, len(cities))
+	for i, city := range cities {
+		l.cities.Prime(ctx, cityKey(city.ID), city)
+		resolvers[i] = &cityResolver{city: city}
+	}
+	return resolvers, nil
+}
+
+func (r *resolver) CreateCity(ctx context.Context, args struct {
+	Name      string
+	Latitude  float64
+	Longitude float64
+}) (*cityResolver, error) {
+	err := requireScope(ctx, core.ScopeCitiesWrite)
+	if err != nil {
+		return nil, err
+	}
+	err = r.rateLimit.charge(ctx, "POST /cities")
+	if err != nil {
+		return nil, err
+	}
+
+	city, err := r.h.CreateCity(ctx, &weather.CreateCityRequest{
+		Name:      &args.Name,
+		Latitude:  &args.Latitude,
+		Longitude: &args.Longitude,
+	})
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return &cityResolver{city: city}, nil
+}
+
+func (r *resolver) UpdateCity(ctx context.Context, args struct {
+	ID        graphql.ID
+	Name      *string
+	Latitude  *float64
+	Longitude *float64
+}) (*cityResolver, error) {
+	err := requireScope(ctx, core.ScopeCitiesWrite)
+	if err != nil {
+		return nil, err
+	}
+	err = r.rateLimit.charge(ctx, "PATCH /cities/:id")
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	city, err := r.h.UpdateCity(ctx, id, &weather.CreateCityRequest{
+		Name:      args.Name,
+		Latitude:  args.Latitude,
+		Longitude: args.Longitude,
+	})
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+
+	loadersFrom(ctx).cities.Clear(ctx, cityKey(id))
+	return &cityResolver{city: city}, nil
+}
+
+func (r *resolver) DeleteCity(ctx context.Context, args struct{ ID graphql.ID }) (*cityResolver, error) {
+	err := requireScope(ctx, core.ScopeCitiesWrite)
+	if err != nil {
+		return nil, err
+	}
+	err = r.rateLimit.charge(ctx, "DELETE /cities/:id")
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	city, err := r.h.DeleteCity(ctx, id)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+
+	loadersFrom(ctx).cities.Clear(ctx, cityKey(id))
+	return &cityResolver{city: city}, nil
+}
+
+func (r *resolver) CreateTemperature(ctx context.Context, args struct {
+	CityID graphql.ID
+	Max    int32
+	Min    int32
+}) (*temperatureResolver, error) {
+	err := requireScope(ctx, core.ScopeTemperaturesWrite)
+	if err != nil {
+		return nil, err
+	}
+	err = r.rateLimit.charge(ctx, "POST /temperatures")
+	if err != nil {
+		return nil, err
+	}
+
+	cityID, err := parseID(args.CityID)
+	if err != nil {
+		return nil, err
+	}
+
+	temperature, err := r.h.AddTemperature(ctx, &core.Temperature{
+		CityID: cityID,
+		Max:    int(args.Max),
+		Min:    int(args.Min),
+	})
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return &temperatureResolver{temperature: temperature}, nil
+}
+
+func (r *resolver) CreateWebhook(ctx context.Context, args struct {
+	CityID         graphql.ID
+	CallbackURL    string
+	PayloadVersion *int32
+}) (*webhookResolver, error) {
+	err := requireScope(ctx, core.ScopeWebhooksManage)
+	if err != nil {
+		return nil, err
+	}
+	err = r.rateLimit.charge(ctx, "POST /webhooks")
+	if err != nil {
+		return nil, err
+	}
+
+	cityID, err := parseID(args.CityID)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := &core.Webhook{
+		CityID:      cityID,
+		CallbackURL: args.CallbackURL,
+	}
+	if args.PayloadVersion != nil {
+		webhook.PayloadVersion = int(*args.PayloadVersion)
+	}
+
+	webhook, err = r.h.AddWebhook(ctx, webhook)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return &webhookResolver{webhook: webhook, created: true}, nil
+}
+
+func (r *resolver) DeleteWebhook(ctx context.Context, args struct{ ID graphql.ID }) (*webhookResolver, error) {
+	err := requireScope(ctx, core.ScopeWebhooksManage)
+	if err != nil {
+		return nil, err
+	}
+	err = r.rateLimit.charge(ctx, "DELETE /webhooks/:id")
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook, err := r.h.DeleteWebhook(ctx, id)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return &webhookResolver{webhook: webhook}, nil
+}
+
+type cityResolver struct {
+	city *core.City
+}
+
+func (r *cityResolver) ID() graphql.ID     { return toID(r.city.ID) }
+func (r *cityResolver) Name() string       { return r.city.Name }
+func (r *cityResolver) Latitude() float64  { return r.city.Latitude }
+func (r *cityResolver) Longitude() float64 { return r.city.Longitude }
+
+func (r *cityResolver) Forecast(ctx context.Context) (*forecastResolver, error) {
+	err := requireScope(ctx, core.ScopeForecastsRead)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := loadersFrom(ctx).forecasts.Load(ctx, cityKey(r.city.ID))()
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+
+	forecast := data.(*core.Forecast)
+	if forecast == nil {
+		return nil, nil
+	}
+	return &forecastResolver{forecast: forecast}, nil
+}
+
+func (r *cityResolver) Temperatures(ctx context.Context, args struct{ Last int32 }) ([]*temperatureResolver, error) {
+	err := requireScope(ctx, core.ScopeForecastsRead)
+	if err != nil {
+		return nil, err
+	}
+
+	if args.Last < 1 || args.Last > maxTemperatures {
+		return nil, errors.New("last must be between 1 and 100")
+	}
+
+	data, err := loadersFrom(ctx).temperatures.Load(ctx, temperaturesKey{cityID: r.city.ID, last: int(args.Last)})()
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+
+	temperatures := data.([]*core.Temperature)
+	resolvers := make([]*temperatureResolver, len(temperatures))
+	for i, temperature := range temperatures {
+		resolvers[i] = &temperatureResolver{temperature: temperature}
+	}
+	return resolvers, nil
+}
+
+func (r *cityResolver) Webhooks(ctx context.Context) ([]*webhookResolver, error) {
+	err := requireScope(ctx, core.ScopeWebhooksManage)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := loadersFrom(ctx).webhooks.Load(ctx, cityKey(r.city.ID))()
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+
+	webhooks := data.([]*core.Webhook)
+	resolvers := make([]*webhookResolver, len(webhooks))
+	for i, webhook := range webhooks {
+		resolvers[i] = &webhookResolver{webhook: webhook}
+	}
+	return resolvers, nil
+}
+
+type temperatureResolver struct {
+	temperature *core.Temperature
+}
+
+func (r *temperatureResolver) ID() graphql.ID { return toID(r.temperature.ID) }
+func (r *temperatureResolver) Max() int32     { return int32(r.temperature.Max) }
+func (r *temperatureResolver) Min() int32     { return int32(r.temperature.Min) }
+
+func (r *temperatureResolver) Timestamp() graphql.Time {
+	return graphql.Time{Time: time.Unix(r.temperature.Timestamp, 0).UTC()}
+}
+
+func (r *temperatureResolver) City(ctx context.Context) (*cityResolver, error) {
+	return loadCity(ctx, r.temperature.CityID)
+}
+
+type forecastResolver struct {
+	forecast *core.Forecast
+}
+
+func (r *forecastResolver) Max() float64  { return r.forecast.Max }
+func (r *forecastResolver) Min() float64  { return r.forecast.Min }
+func (r *forecastResolver) Sample() int32 { return int32(r.forecast.Sample) }
+
+func (r *forecastResolver) City(ctx context.Context) (*cityResolver, error) {
+	return loadCity(ctx, r.forecast.CityID)
+}
+
+type webhookResolver struct {
+	webhook *core.Webhook
+	// created answers the secret, webhooks loaded from the store hold theirs as well
+	created bool
+}
+
+func (r *webhookResolver) ID() graphql.ID        { return toID(r.webhook.ID) }
+func (r *webhookResolver) CallbackURL() string   { return r.webhook.CallbackURL }
+func (r *webhookResolver) PayloadVersion() int32 { return int32(r.webhook.PayloadVersion) }
+
+func (r *webhookResolver) Secret() *string {
+	if !r.created {
+		return nil
+	}
+	return &r.webhook.Secret
+}
+
+func (r *webhookResolver) City(ctx context.Context) (*cityResolver, error) {
+	return loadCity(ctx, r.webhook.CityID)
+}
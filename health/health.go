@@ -0,0 +1,115 @@
+// Package health reports to the orchestrator whether the api is alive and ready to serve traffic
+package health
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Routes served by RegisterRoutes
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+	VersionPath   = "/version"
+)
+
+// checkTimeout bounds how long a readiness check may run
+const checkTimeout = 2 * time.Second
+
+// Check reports why a dependency cannot serve traffic, nil when it can
+type Check func(ctx context.Context) error
+
+// BuildInfo identifies the running build
+type BuildInfo struct {
+	Commit    string `json:"commit"`
+	Branch    string `json:"branch"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// Checker serves liveness, readiness and build information
+type Checker struct {
+	build    BuildInfo
+	draining int32
+
+	mu     sync.RWMutex
+	checks map[string]Check
+}
+
+// NewChecker returns a checker reporting build, the go version is filled in
+func NewChecker(build BuildInfo) *Checker {
+	build.GoVersion = runtime.Version()
+	return &Checker{build: build, checks: map[string]Check{}}
+}
+
+// AddCheck makes readiness depend on check
+func (h *Checker) AddCheck(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[name] = check
+}
+
+// Drain fails readiness from now on so the orchestrator stops routing traffic before shutdown
+func (h *Checker) Drain() {
+	atomic.StoreInt32(&h.draining, 1)
+}
+
+func (h *Checker) RegisterRoutes(r gin.IRoutes) {
+	r.GET(LivenessPath, h.handleLiveness)
+	r.GET(ReadinessPath, h.handleReadiness)
+	r.GET(VersionPath, h.handleVersion)
+}
+
+func (h *Checker) handleLiveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (h *Checker) handleReadiness(c *gin.Context) {
+	if atomic.LoadInt32(&h.draining) == 1 {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), checkTimeout)
+	defer cancel()
+
+	failures := h.run(ctx)
+	if len(failures) > 0 {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": failures})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (h *Checker) handleVersion(c *gin.Context) {
+	c.JSON(http.StatusOK, h.build)
+}
+
+// run executes the checks concurrently and returns the errors of the failed ones by name
+func (h *Checker) run(ctx context.Context) map[string]string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	failures := map[string]string{}
+	for name, check := range h.checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			if err := check(ctx); err != nil {
+				mu.Lock()
+				failures[name] = err.Error()
+				mu.Unlock()
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return failures
+}
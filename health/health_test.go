@@ -0,0 +1,75 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/walez/weather-monster/health"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecker(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var dbErr error
+	checker := health.NewChecker(health.BuildInfo{Commit: "abc123", Branch: "main", BuildTime: "2020-01-01T00:00:00Z"})
+	checker.AddCheck("postgres", func(ctx context.Context) error { return dbErr })
+	checker.AddCheck("events", func(ctx context.Context) error { return nil })
+
+	r := gin.New()
+	checker.RegisterRoutes(r)
+
+	get := func(path string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		body := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	t.Run("should be ready when every check passes", func(t *testing.T) {
+		status, _ := get(health.ReadinessPath)
+		assert.Equal(t, http.StatusOK, status)
+	})
+
+	t.Run("should report failed checks", func(t *testing.T) {
+		dbErr = errors.New("connection refused")
+		defer func() { dbErr = nil }()
+
+		status, body := get(health.ReadinessPath)
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		assert.Equal(t, map[string]interface{}{"postgres": "connection refused"}, body["checks"])
+
+		status, _ = get(health.LivenessPath)
+		assert.Equal(t, http.StatusOK, status, "dependencies do not affect liveness")
+	})
+
+	t.Run("should report build information", func(t *testing.T) {
+		status, body := get(health.VersionPath)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "abc123", body["commit"])
+		assert.Equal(t, "main", body["branch"])
+		assert.Equal(t, "2020-01-01T00:00:00Z", body["build_time"])
+		assert.Equal(t, runtime.Version(), body["go_version"])
+	})
+
+	t.Run("should not be ready once draining", func(t *testing.T) {
+		checker.Drain()
+
+		status, body := get(health.ReadinessPath)
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		assert.Equal(t, "draining", body["status"])
+
+		status, _ = get(health.LivenessPath)
+		assert.Equal(t, http.StatusOK, status)
+	})
+}
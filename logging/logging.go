@@ -0,0 +1,63 @@
+// Package logging provides loggers scoped to a request so that every line it logs can be correlated
+package logging
+
+import (
+	"context"
+	"time"
+
+	core "github.com/walez/weather-monster"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying entry
+func WithLogger(ctx context.Context, entry *log.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey{}, entry)
+}
+
+// FromContext returns the logger set in ctx with WithLogger, otherwise the standard logger
+// with the request id of ctx when there is one
+func FromContext(ctx context.Context) *log.Entry {
+	if entry, ok := ctx.Value(loggerKey{}).(*log.Entry); ok {
+		return entry
+	}
+
+	entry := log.NewEntry(log.StandardLogger())
+	if id := core.RequestIDFromContext(ctx); id != "" {
+		entry = entry.WithField("request_id", id)
+	}
+	return entry
+}
+
+// Middleware sets a logger with the request id, method and path in the request context then logs the request
+// once handled, it must run after requestid.Middleware. Query strings are not logged as they may hold credentials.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		entry := FromContext(c.Request.Context()).WithFields(log.Fields{
+			"method": c.Request.Method,
+			"path":   c.Request.URL.Path,
+		})
+		c.Request = c.Request.WithContext(WithLogger(c.Request.Context(), entry))
+
+		c.Next()
+
+		entry = entry.WithFields(log.Fields{
+			"route":       c.FullPath(),
+			"status":      c.Writer.Status(),
+			"duration_ms": time.Since(start).Milliseconds(),
+			"client_ip":   c.ClientIP(),
+		})
+		switch status := c.Writer.Status(); {
+		case status >= 500:
+			entry.Error("request handled")
+		case status >= 400:
+			entry.Warn("request handled")
+		default:
+			entry.Info("request handled")
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Redacted replaces secrets in log entries
+const Redacted = "REDACTED"
+
+// secretKeys are parts of field names whose values are never logged
+var secretKeys = []string{"secret", "password", "token", "api_key", "apikey", "authorization"}
+
+var secretPatterns = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	// api keys keep their public prefix so that the key can be identified
+	{regexp.MustCompile(`\b(wm_[0-9a-f]{8}_)[0-9a-f]{48}\b`), "${1}" + Redacted},
+	// webhook secrets
+	{regexp.MustCompile(`\bwhsec_[0-9a-f]{48}\b`), "whsec_" + Redacted},
+	{regexp.MustCompile(`(?i)\b(bearer\s+)[A-Za-z0-9\-._~+/]+=*`), "${1}" + Redacted},
+	// passwords of connection URLs
+	{regexp.MustCompile(`(://[^:/@\s]+:)[^@\s]+@`), "${1}" + Redacted + "@"},
+}
+
+type redactingFormatter struct {
+	formatter log.Formatter
+}
+
+// Redact wraps formatter to mask secrets before entries are written: values of fields named like secrets,
+// and api keys, webhook secrets, bearer tokens or URL passwords found in messages and text fields
+func Redact(formatter log.Formatter) log.Formatter {
+	return &redactingFormatter{formatter: formatter}
+}
+
+func (f *redactingFormatter) Format(entry *log.Entry) ([]byte, error) {
+	redacted := *entry
+	redacted.Message = RedactString(entry.Message)
+	redacted.Data = make(log.Fields, len(entry.Data))
+
+	for key, value := range entry.Data {
+		redacted.Data[key] = value
+		switch v := value.(type) {
+		case string:
+			redacted.Data[key] = RedactString(v)
+		case error:
+			redacted.Data[key] = RedactString(v.Error())
+		case fmt.Stringer:
+			redacted.Data[key] = RedactString(v.String())
+		}
+		if isSecretKey(key) {
+			redacted.Data[key] = Redacted
+		}
+	}
+	return f.formatter.Format(&redacted)
+}
+
+// RedactString masks the api keys, bearer tokens and URL passwords found in s
+func RedactString(s string) string {
+	for _, p := range secretPatterns {
+		s = p.pattern.ReplaceAllString(s, p.replacement)
+	}
+	return s
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+// Package metrics defines the prometheus metrics exposed on /metrics
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "weather_monster"
+
+var (
+	// HTTPRequests counts handled requests by route and status code
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests handled by route and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPDuration observes request latencies by route
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latencies by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// TemperaturesIngested counts temperatures created by city
+	TemperaturesIngested = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "temperatures_ingested_total",
+		Help:      "Temperatures created by tenant and city.",
+	}, []string{"tenant", "city"})
+
+	// ForecastDuration observes forecast query latencies against the datastore
+	ForecastDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "forecast_query_duration_seconds",
+		Help:      "Forecast query latencies against the datastore.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// EventsQueueDepth is the number of events waiting for a listener worker
+	EventsQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "events",
+		Name:      "queue_depth",
+		Help:      "Events waiting for a listener worker.",
+	})
+
+	// ListenerErrors counts listeners that failed by event
+	ListenerErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "events",
+		Name:      "listener_errors_total",
+		Help:      "Event listeners that returned an error by event.",
+	}, []string{"event"})
+
+	// StreamSubscribers is the number of clients subscribed to live temperatures
+	StreamSubscribers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "events",
+		Name:      "stream_subscribers",
+		Help:      "Clients subscribed to live temperatures.",
+	})
+
+	// StreamDropped counts temperatures not sent to subscribers too slow to receive them
+	StreamDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "events",
+		Name:      "stream_dropped_total",
+		Help:      "Temperatures dropped as their subscriber buffer was full.",
+	})
+
+	// WebhookDeliveries counts webhook calls by response status code, "error" when no response was received
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhooks",
+		Name:      "deliveries_total",
+		Help:      "Webhook deliveries by response status code, error when the call failed.",
+	}, []string{"status"})
+
+	// RetentionRows counts rows deleted or compacted by the retention policy by operation
+	RetentionRows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "retention",
+		Name:      "rows_total",
+		Help:      "Rows deleted or compacted by the retention policy.",
+	}, []string{"operation"})
+
+	// RetentionRuns counts retention runs by result
+	RetentionRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "retention",
+		Name:      "runs_total",
+		Help:      "Retention policy runs by result.",
+	}, []string{"result"})
+)
+
+// Handler serves the metrics of the default registry
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware records HTTP metrics labelled with the route template rather than the path
+// so that ids do not explode the number of series
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		HTTPDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Since observes the seconds elapsed since start, e.g `defer metrics.Since(h, time.Now())`
+func Since(o prometheus.Observer, start time.Time) {
+	o.Observe(time.Since(start).Seconds())
+}
+
+// RegisterDBStats exposes the connection pool stats of db labelled with name
+func RegisterDBStats(db *sql.DB, name string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
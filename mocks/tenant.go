@@ -0,0 +1,124 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: tenant.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	gomock "github.com/golang/mock/gomock"
+	weather_monster "github.com/walez/weather-monster"
+	reflect "reflect"
+	time "time"
+)
+
+// MockTenantService is a mock of TenantService interface
+type MockTenantService struct {
+	ctrl     *gomock.Controller
+	recorder *MockTenantServiceMockRecorder
+}
+
+// MockTenantServiceMockRecorder is the mock recorder for MockTenantService
+type MockTenantServiceMockRecorder struct {
+	mock *MockTenantService
+}
+
+// NewMockTenantService creates a new mock instance
+func NewMockTenantService(ctrl *gomock.Controller) *MockTenantService {
+	mock := &MockTenantService{ctrl: ctrl}
+	mock.recorder = &MockTenantServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockTenantService) EXPECT() *MockTenantServiceMockRecorder {
+	return m.recorder
+}
+
+// FindTenantByID mocks base method
+func (m *MockTenantService) FindTenantByID(ctx context.Context, id int64) (*weather_monster.Tenant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindTenantByID", ctx, id)
+	ret0, _ := ret[0].(*weather_monster.Tenant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindTenantByID indicates an expected call of FindTenantByID
+func (mr *MockTenantServiceMockRecorder) FindTenantByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindTenantByID", reflect.TypeOf((*MockTenantService)(nil).FindTenantByID), ctx, id)
+}
+
+// FindTenantByName mocks base method
+func (m *MockTenantService) FindTenantByName(ctx context.Context, name string) (*weather_monster.Tenant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindTenantByName", ctx, name)
+	ret0, _ := ret[0].(*weather_monster.Tenant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindTenantByName indicates an expected call of FindTenantByName
+func (mr *MockTenantServiceMockRecorder) FindTenantByName(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindTenantByName", reflect.TypeOf((*MockTenantService)(nil).FindTenantByName), ctx, name)
+}
+
+// ListTenants mocks base method
+func (m *MockTenantService) ListTenants(ctx context.Context) ([]*weather_monster.Tenant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTenants", ctx)
+	ret0, _ := ret[0].([]*weather_monster.Tenant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTenants indicates an expected call of ListTenants
+func (mr *MockTenantServiceMockRecorder) ListTenants(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTenants", reflect.TypeOf((*MockTenantService)(nil).ListTenants), ctx)
+}
+
+// CreateTenant mocks base method
+func (m *MockTenantService) CreateTenant(ctx context.Context, tenant *weather_monster.Tenant) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTenant", ctx, tenant)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateTenant indicates an expected call of CreateTenant
+func (mr *MockTenantServiceMockRecorder) CreateTenant(ctx, tenant interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTenant", reflect.TypeOf((*MockTenantService)(nil).CreateTenant), ctx, tenant)
+}
+
+// SetDailyTemperatureQuota mocks base method
+func (m *MockTenantService) SetDailyTemperatureQuota(ctx context.Context, tenantID, quota int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetDailyTemperatureQuota", ctx, tenantID, quota)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetDailyTemperatureQuota indicates an expected call of SetDailyTemperatureQuota
+func (mr *MockTenantServiceMockRecorder) SetDailyTemperatureQuota(ctx, tenantID, quota interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDailyTemperatureQuota", reflect.TypeOf((*MockTenantService)(nil).SetDailyTemperatureQuota), ctx, tenantID, quota)
+}
+
+// GetTenantUsage mocks base method
+func (m *MockTenantService) GetTenantUsage(ctx context.Context, tenantID int64, day time.Time) (*weather_monster.TenantUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTenantUsage", ctx, tenantID, day)
+	ret0, _ := ret[0].(*weather_monster.TenantUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTenantUsage indicates an expected call of GetTenantUsage
+func (mr *MockTenantServiceMockRecorder) GetTenantUsage(ctx, tenantID, day interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTenantUsage", reflect.TypeOf((*MockTenantService)(nil).GetTenantUsage), ctx, tenantID, day)
+}
@@ -136,6 +136,81 @@ func (mr *MockWeatherServiceMockRecorder) GetCityWebhooks(ctx, cityID interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCityWebhooks", reflect.TypeOf((*MockWeatherService)(nil).GetCityWebhooks), ctx, cityID)
 }
 
+// ListCities mocks base method
+func (m *MockWeatherService) ListCities(ctx context.Context, cursor int64, limit int) ([]*weather_monster.City, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCities", ctx, cursor, limit)
+	ret0, _ := ret[0].([]*weather_monster.City)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCities indicates an expected call of ListCities
+func (mr *MockWeatherServiceMockRecorder) ListCities(ctx, cursor, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCities", reflect.TypeOf((*MockWeatherService)(nil).ListCities), ctx, cursor, limit)
+}
+
+// FindCitiesByIDs mocks base method
+func (m *MockWeatherService) FindCitiesByIDs(ctx context.Context, ids []int64) ([]*weather_monster.City, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindCitiesByIDs", ctx, ids)
+	ret0, _ := ret[0].([]*weather_monster.City)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindCitiesByIDs indicates an expected call of FindCitiesByIDs
+func (mr *MockWeatherServiceMockRecorder) FindCitiesByIDs(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindCitiesByIDs", reflect.TypeOf((*MockWeatherService)(nil).FindCitiesByIDs), ctx, ids)
+}
+
+// GetCitiesForecasts mocks base method
+func (m *MockWeatherService) GetCitiesForecasts(ctx context.Context, cityIDs []int64) ([]*weather_monster.Forecast, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCitiesForecasts", ctx, cityIDs)
+	ret0, _ := ret[0].([]*weather_monster.Forecast)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCitiesForecasts indicates an expected call of GetCitiesForecasts
+func (mr *MockWeatherServiceMockRecorder) GetCitiesForecasts(ctx, cityIDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCitiesForecasts", reflect.TypeOf((*MockWeatherService)(nil).GetCitiesForecasts), ctx, cityIDs)
+}
+
+// GetCitiesWebhooks mocks base method
+func (m *MockWeatherService) GetCitiesWebhooks(ctx context.Context, cityIDs []int64) ([]*weather_monster.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCitiesWebhooks", ctx, cityIDs)
+	ret0, _ := ret[0].([]*weather_monster.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCitiesWebhooks indicates an expected call of GetCitiesWebhooks
+func (mr *MockWeatherServiceMockRecorder) GetCitiesWebhooks(ctx, cityIDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCitiesWebhooks", reflect.TypeOf((*MockWeatherService)(nil).GetCitiesWebhooks), ctx, cityIDs)
+}
+
+// GetCitiesTemperatures mocks base method
+func (m *MockWeatherService) GetCitiesTemperatures(ctx context.Context, cityIDs []int64, limit int) ([]*weather_monster.Temperature, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCitiesTemperatures", ctx, cityIDs, limit)
+	ret0, _ := ret[0].([]*weather_monster.Temperature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCitiesTemperatures indicates an expected call of GetCitiesTemperatures
+func (mr *MockWeatherServiceMockRecorder) GetCitiesTemperatures(ctx, cityIDs, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCitiesTemperatures", reflect.TypeOf((*MockWeatherService)(nil).GetCitiesTemperatures), ctx, cityIDs, limit)
+}
+
 // CreateTemperature mocks base method
 func (m *MockWeatherService) CreateTemperature(ctx context.Context, temperature *weather_monster.Temperature) error {
 	m.ctrl.T.Helper()
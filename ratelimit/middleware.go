@@ -0,0 +1,126 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/walez/weather-monster/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Rate limit response headers
+const (
+	LimitHeader     = "X-RateLimit-Limit"
+	RemainingHeader = "X-RateLimit-Remaining"
+	ResetHeader     = "X-RateLimit-Reset"
+)
+
+// MiddlewareOption configures optional behaviour of the middleware
+type MiddlewareOption func(*middleware)
+
+type middleware struct {
+	prefix  string
+	byIP    bool
+	proxies Proxies
+}
+
+// TrimPrefix keys routes without prefix, e.g the api version, so that every version of a route shares
+// its limit and buckets when the middlewares share their limiter
+func TrimPrefix(prefix string) MiddlewareOption {
+	return func(m *middleware) {
+		m.prefix = prefix
+	}
+}
+
+// ByIP identifies clients by their IP whatever their credentials, so that the middleware can run
+// before auth.Middleware and throttle requests with invalid credentials
+func ByIP() MiddlewareOption {
+	return func(m *middleware) {
+		m.byIP = true
+	}
+}
+
+// WithProxies identifies clients by the address forwarded by the trusted proxies rather than the proxies,
+// without it the forwarded headers are ignored
+func WithProxies(proxies Proxies) MiddlewareOption {
+	return func(m *middleware) {
+		m.proxies = proxies
+	}
+}
+
+// Middleware limits every client to the limit of the matched route, keyed by
+// method and route e.g "POST /temperatures", falling back to def for other routes.
+// Clients are identified by their credentials, or their IP when anonymous, so it must run after auth.Middleware
+// unless they are identified ByIP
+func Middleware(l *Limiter, def Limit, routes map[string]Limit, opts ...MiddlewareOption) gin.HandlerFunc {
+	m := &middleware{}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return func(c *gin.Context) {
+		route := c.Request.Method + " " + strings.TrimPrefix(c.FullPath(), m.prefix)
+		limit := RouteLimit(def, routes, route)
+		if limit.Rate <= 0 {
+			c.Next()
+			return
+		}
+
+		result := l.Allow(Key(m.client(c), route), limit)
+
+		c.Header(LimitHeader, strconv.Itoa(result.Limit))
+		c.Header(RemainingHeader, strconv.Itoa(result.Remaining))
+		c.Header(ResetHeader, ceilSeconds(result.Reset))
+
+		if !result.Allowed {
+			c.Header("Retry-After", ceilSeconds(result.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"message": "too many requests"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (m *middleware) client(c *gin.Context) string {
+	if m.byIP {
+		return IPClient(m.proxies.ClientIP(c.Request))
+	}
+	return Client(c.Request.Context(), m.proxies.ClientIP(c.Request))
+}
+
+// Client identifies the client of a request in the buckets of a limiter, the subject of the principal of ctx
+// or ip when anonymous. It is shared with the gRPC api so that a client has the same budget on both.
+func Client(ctx context.Context, ip string) string {
+	p, ok := auth.PrincipalFromContext(ctx)
+	if !ok || p.Subject == auth.Anonymous {
+		return IPClient(ip)
+	}
+	return p.Subject
+}
+
+// IPClient identifies the clients of ip whatever their credentials
+func IPClient(ip string) string {
+	return "ip:" + ip
+}
+
+// Key identifies the bucket of client on route, routes are keyed by method and path e.g "POST /temperatures"
+func Key(client string, route string) string {
+	return client + " " + route
+}
+
+// RouteLimit returns the limit of route in routes, def for the routes missing from it
+func RouteLimit(def Limit, routes map[string]Limit, route string) Limit {
+	if limit, ok := routes[route]; ok {
+		return limit
+	}
+	return def
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,200 @@
+package ratelimit_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/auth"
+	"github.com/walez/weather-monster/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	now := time.Unix(1600000000, 0)
+	l := ratelimit.New(ratelimit.WithClock(func() time.Time { return now }))
+
+	apiKey := auth.AuthenticatorFunc(func(r *http.Request) (*auth.Principal, error) {
+		if r.Header.Get("Authorization") == "" {
+			return nil, auth.ErrNoCredentials
+		}
+		return &auth.Principal{Subject: r.Header.Get("Authorization")}, nil
+	})
+
+	r := gin.New()
+	rg := r.Group("",
+		auth.Middleware(apiKey, auth.DefaultTenant(&core.Tenant{ID: 1})),
+		ratelimit.Middleware(l, ratelimit.PerMinute(3), map[string]ratelimit.Limit{
+			"POST /temperatures": ratelimit.PerMinute(1),
+			"GET /unlimited":     ratelimit.PerMinute(0),
+		}),
+	)
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	rg.POST("/temperatures", ok)
+	rg.GET("/forecasts/:city_id", ok)
+	rg.GET("/unlimited", ok)
+
+	v1 := r.Group("/v1",
+		auth.Middleware(apiKey),
+		ratelimit.Middleware(l, ratelimit.PerMinute(3), map[string]ratelimit.Limit{
+			"POST /temperatures": ratelimit.PerMinute(1),
+		}, ratelimit.TrimPrefix("/v1")),
+	)
+	v1.POST("/temperatures", ok)
+
+	request := func(method string, path string, key string, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		if key != "" {
+			req.Header.Set("Authorization", key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("should limit route with its own limit", func(t *testing.T) {
+		w := request(http.MethodPost, "/temperatures", "key-1", "10.0.0.1")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "1", w.Header().Get(ratelimit.LimitHeader))
+		assert.Equal(t, "0", w.Header().Get(ratelimit.RemainingHeader))
+		assert.Equal(t, "60", w.Header().Get(ratelimit.ResetHeader))
+
+		w = request(http.MethodPost, "/temperatures", "key-1", "10.0.0.2")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code, "keys are limited regardless of ip")
+		assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	})
+
+	t.Run("should limit keys independently", func(t *testing.T) {
+		w := request(http.MethodPost, "/temperatures", "key-2", "10.0.0.1")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("should limit other routes with default limit", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			w := request(http.MethodGet, "/forecasts/1", "key-1", "10.0.0.1")
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+		w := request(http.MethodGet, "/forecasts/2", "key-1", "10.0.0.1")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code, "routes are limited regardless of params")
+	})
+
+	t.Run("should limit anonymous clients by ip", func(t *testing.T) {
+		w := request(http.MethodPost, "/temperatures", "", "10.0.0.3")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = request(http.MethodPost, "/temperatures", "", "10.0.0.3")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+		w = request(http.MethodPost, "/temperatures", "", "10.0.0.4")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("should not limit route with zero limit", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			w := request(http.MethodGet, "/unlimited", "key-1", "10.0.0.1")
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Empty(t, w.Header().Get(ratelimit.LimitHeader))
+		}
+	})
+
+	t.Run("should share limits between versions of a route", func(t *testing.T) {
+		w := request(http.MethodPost, "/v1/temperatures", "key-3", "10.0.0.1")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "1", w.Header().Get(ratelimit.LimitHeader), "routes are configured without the version")
+
+		w = request(http.MethodPost, "/temperatures", "key-3", "10.0.0.1")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	})
+
+	t.Run("should allow requests again once refilled", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		w := request(http.MethodPost, "/temperatures", "key-1", "10.0.0.1")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestMiddleware_ByIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	l := ratelimit.New()
+	apiKey := auth.AuthenticatorFunc(func(r *http.Request) (*auth.Principal, error) {
+		if r.Header.Get("Authorization") != "valid" {
+			return nil, errors.New("invalid api key")
+		}
+		return &auth.Principal{Subject: "valid"}, nil
+	})
+
+	r := gin.New()
+	r.Use(ratelimit.Middleware(l, ratelimit.PerMinute(2), nil, ratelimit.ByIP()), auth.Middleware(apiKey))
+	r.GET("/forecasts/:city_id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func(key string, ip string, forwarded ...string) int {
+		req := httptest.NewRequest(http.MethodGet, "/forecasts/1", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("Authorization", key)
+		for _, address := range forwarded {
+			req.Header.Add("X-Forwarded-For", address)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("should throttle invalid credentials", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, request("guess-1", "10.0.0.1"))
+		assert.Equal(t, http.StatusUnauthorized, request("guess-2", "10.0.0.1"))
+		assert.Equal(t, http.StatusTooManyRequests, request("guess-3", "10.0.0.1"))
+	})
+
+	t.Run("should limit every client of an ip together", func(t *testing.T) {
+		assert.Equal(t, http.StatusTooManyRequests, request("valid", "10.0.0.1"))
+		assert.Equal(t, http.StatusOK, request("valid", "10.0.0.2"))
+	})
+
+	t.Run("should ignore the forwarded headers of untrusted peers", func(t *testing.T) {
+		assert.Equal(t, http.StatusTooManyRequests, request("guess-4", "10.0.0.1", "192.0.2.1"))
+		assert.Equal(t, http.StatusTooManyRequests, request("guess-5", "10.0.0.1", "192.0.2.2"))
+	})
+}
+
+func TestMiddleware_WithProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	proxies, err := ratelimit.ParseProxies([]string{"10.0.0.0/8", "172.16.0.1"})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.Use(ratelimit.Middleware(ratelimit.New(), ratelimit.PerMinute(1), nil, ratelimit.ByIP(), ratelimit.WithProxies(proxies)))
+	r.GET("/forecasts/:city_id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func(peer string, forwarded string) int {
+		req := httptest.NewRequest(http.MethodGet, "/forecasts/1", nil)
+		req.RemoteAddr = peer + ":1234"
+		req.Header.Set("X-Forwarded-For", forwarded)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request("10.0.0.1", "192.0.2.1"))
+	assert.Equal(t, http.StatusOK, request("10.0.0.1", "192.0.2.2"), "clients of a trusted proxy are limited by their address")
+	assert.Equal(t, http.StatusTooManyRequests, request("10.0.0.1", "192.0.2.3, 172.16.0.1, 192.0.2.1"),
+		"hops set by the client before the last untrusted one are ignored")
+}
+
+func TestParseProxies(t *testing.T) {
+	_, err := ratelimit.ParseProxies([]string{"10.0.0.0/8", "::1", "192.0.2.1"})
+	assert.NoError(t, err)
+
+	_, err = ratelimit.ParseProxies([]string{"proxy.example.com"})
+	assert.Error(t, err)
+}
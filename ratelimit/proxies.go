@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Proxies are the reverse proxies trusted to forward the address of clients in the X-Forwarded-For
+// and X-Real-Ip headers, the headers of other peers are ignored as any client can set them
+type Proxies []*net.IPNet
+
+// ParseProxies parses IPs and CIDRs, e.g "10.0.0.1" or "10.0.0.0/8"
+func ParseProxies(addresses []string) (Proxies, error) {
+	var proxies Proxies
+	for _, address := range addresses {
+		address = strings.TrimSpace(address)
+		if !strings.Contains(address, "/") {
+			ip := net.ParseIP(address)
+			if ip == nil {
+				return nil, errors.Errorf("invalid proxy address %q", address)
+			}
+			bits := 8 * len(ip.To4())
+			if bits == 0 {
+				bits = 8 * net.IPv6len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(address)
+		if err != nil {
+			return nil, errors.Errorf("invalid proxy address %q", address)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// ClientIP returns the IP of the client of r, the address of the peer unless it is a trusted proxy.
+// The hops of X-Forwarded-For are then walked back to the first one that is not a trusted proxy,
+// as the ones before it could be set by the client.
+func (p Proxies) ClientIP(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if !p.trusts(ip) {
+		return ip
+	}
+
+	forwarded := false
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		ip, forwarded = hop, true
+		if !p.trusts(hop) {
+			return hop
+		}
+	}
+
+	if real := strings.TrimSpace(r.Header.Get("X-Real-Ip")); real != "" && !forwarded {
+		return real
+	}
+	return ip
+}
+
+func (p Proxies) trusts(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
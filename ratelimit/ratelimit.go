@@ -0,0 +1,150 @@
+// Package ratelimit throttles api clients with per route token buckets
+package ratelimit
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Limit allows Burst requests at once refilled at Rate requests per second,
+// a zero Rate disables limiting
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute returns a limit of n requests per minute allowing bursts of n requests
+func PerMinute(n int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: n}
+}
+
+// Result reports the state of a bucket after a request was counted
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long to wait before the next request is allowed
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+// Limiter holds a token bucket per client and route
+type Limiter struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// Option configures optional behaviour of the limiter
+type Option func(*Limiter)
+
+// WithClock replaces time.Now, e.g for tests
+func WithClock(now func() time.Time) Option {
+	return func(l *Limiter) {
+		l.now = now
+	}
+}
+
+func New(opts ...Option) *Limiter {
+	l := &Limiter{
+		now:     time.Now,
+		buckets: map[string]*bucket{},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.lastSweep = l.now()
+	return l
+}
+
+// Allow takes a token from the bucket identified by key
+func (l *Limiter) Allow(key string, limit Limit) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.limit = limit
+
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	result := Result{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+	return result
+}
+
+// sweep forgets buckets refilled since their last request, they are recreated full on demand
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate >= float64(b.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// ParseRouteLimits parses comma separated `<method> <route>=<requests per minute>` pairs,
+// e.g "POST /temperatures=600,GET /forecasts/:city_id=120"
+func ParseRouteLimits(s string) (map[string]Limit, error) {
+	limits := map[string]Limit{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		i := strings.LastIndex(pair, "=")
+		if i < 0 {
+			return nil, errors.Errorf("ratelimit: missing limit in %q", pair)
+		}
+
+		n, err := strconv.Atoi(pair[i+1:])
+		if err != nil || n < 0 {
+			return nil, errors.Errorf("ratelimit: invalid limit in %q", pair)
+		}
+
+		route := strings.Join(strings.Fields(pair[:i]), " ")
+		if len(strings.Fields(route)) != 2 {
+			return nil, errors.Errorf("ratelimit: route must be `<method> <path>` in %q", pair)
+		}
+		limits[route] = PerMinute(n)
+	}
+	return limits, nil
+}
@@ -0,0 +1,69 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/walez/weather-monster/ratelimit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	l := ratelimit.New(ratelimit.WithClock(func() time.Time { return now }))
+	limit := ratelimit.Limit{Rate: 1, Burst: 2}
+
+	t.Run("should allow bursts", func(t *testing.T) {
+		first := l.Allow("a", limit)
+		assert.True(t, first.Allowed)
+		assert.Equal(t, 1, first.Remaining)
+
+		second := l.Allow("a", limit)
+		assert.True(t, second.Allowed)
+		assert.Equal(t, 0, second.Remaining)
+		assert.Equal(t, 2*time.Second, second.Reset)
+	})
+
+	t.Run("should reject once the bucket is empty", func(t *testing.T) {
+		res := l.Allow("a", limit)
+		assert.False(t, res.Allowed)
+		assert.Equal(t, time.Second, res.RetryAfter)
+	})
+
+	t.Run("should keep buckets per key", func(t *testing.T) {
+		assert.True(t, l.Allow("b", limit).Allowed)
+	})
+
+	t.Run("should refill at rate", func(t *testing.T) {
+		now = now.Add(1500 * time.Millisecond)
+		assert.True(t, l.Allow("a", limit).Allowed)
+
+		res := l.Allow("a", limit)
+		assert.False(t, res.Allowed)
+		assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+	})
+
+	t.Run("should start full after idle buckets are swept", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		res := l.Allow("a", limit)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 1, res.Remaining)
+	})
+}
+
+func TestParseRouteLimits(t *testing.T) {
+	limits, err := ratelimit.ParseRouteLimits("POST /temperatures=600, GET  /forecasts/:city_id=120,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]ratelimit.Limit{
+		"POST /temperatures":      {Rate: 10, Burst: 600},
+		"GET /forecasts/:city_id": {Rate: 2, Burst: 120},
+	}, limits)
+
+	_, err = ratelimit.ParseRouteLimits("/temperatures=600")
+	assert.Error(t, err)
+
+	_, err = ratelimit.ParseRouteLimits("POST /temperatures=many")
+	assert.Error(t, err)
+}
@@ -0,0 +1,16 @@
+package core
+
+import "context"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the id correlating the work done for a request
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the id set by WithRequestID, empty outside of a request
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
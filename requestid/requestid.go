@@ -0,0 +1,41 @@
+// Package requestid correlates the work done for a request with an id
+package requestid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	core "github.com/walez/weather-monster"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header carries the request id, it is reused when sent by the client or a proxy
+const Header = "X-Request-ID"
+
+// maxLength bounds ids accepted from clients
+const maxLength = 128
+
+// Middleware sets the request id in the request context and the response headers,
+// generating one when the request has none
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(Header)
+		if id == "" || len(id) > maxLength {
+			id = New()
+		}
+
+		c.Header(Header, id)
+		c.Request = c.Request.WithContext(core.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// New returns a random request id
+func New() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
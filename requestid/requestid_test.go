@@ -0,0 +1,41 @@
+package requestid_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/requestid"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(requestid.Middleware())
+	r.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, core.RequestIDFromContext(c.Request.Context()))
+	})
+
+	t.Run("should propagate request id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(requestid.Header, "request-1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, "request-1", w.Body.String())
+		assert.Equal(t, "request-1", w.Header().Get(requestid.Header))
+	})
+
+	t.Run("should generate missing request id", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Len(t, w.Body.String(), 32)
+		assert.Equal(t, w.Body.String(), w.Header().Get(requestid.Header))
+	})
+}
@@ -0,0 +1,252 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/auth"
+	"github.com/walez/weather-monster/logging"
+	"github.com/walez/weather-monster/ratelimit"
+	"github.com/walez/weather-monster/requestid"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// requestIDKey is the metadata key of the request id, metadata keys are lower case
+const requestIDKey = "x-request-id"
+
+// maxRequestIDLength bounds ids accepted from clients
+const maxRequestIDLength = 128
+
+// retryAfterKey is the header metadata key of how many seconds a throttled client waits, like Retry-After
+const retryAfterKey = "retry-after"
+
+// interceptor correlates, throttles, authenticates and logs calls, the way the REST middlewares do for requests
+type interceptor struct {
+	authenticators []auth.Authenticator
+	methods        map[string]method
+	rateLimit      RateLimit
+}
+
+func (i *interceptor) unary(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	start := time.Now()
+	ctx = withRequest(ctx, info.FullMethod)
+
+	ctx, err := i.admit(ctx, info.FullMethod)
+	var resp interface{}
+	if err == nil {
+		resp, err = handler(ctx, req)
+	}
+
+	logCall(ctx, start, err)
+	return resp, err
+}
+
+func (i *interceptor) stream(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	start := time.Now()
+	ctx := withRequest(ss.Context(), info.FullMethod)
+
+	ctx, err := i.admit(ctx, info.FullMethod)
+	if err == nil {
+		err = handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+
+	logCall(ctx, start, err)
+	return err
+}
+
+// serverStream overrides the context of a stream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// withRequest sets the request id of the call, generated when missing, and a logger in ctx
+func withRequest(ctx context.Context, method string) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(requestIDKey); len(ids) > 0 && len(ids[0]) <= maxRequestIDLength {
+			id = ids[0]
+		}
+	}
+	if id == "" {
+		id = requestid.New()
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, id))
+	ctx = core.WithRequestID(ctx, id)
+	return logging.WithLogger(ctx, logging.FromContext(ctx).WithField("method", method))
+}
+
+// admit authorizes the call of fullMethod within the rate limits, peers are limited by IP before being
+// authenticated so that calls with invalid credentials are throttled too
+func (i *interceptor) admit(ctx context.Context, fullMethod string) (context.Context, error) {
+	m, ok := i.methods[fullMethod]
+	if !ok {
+		return ctx, status.Error(codes.Unimplemented, "unknown method")
+	}
+
+	ip := peerIP(ctx)
+	err := limit(ctx, i.rateLimit.IPLimiter, i.rateLimit.IPLimit, ratelimit.IPClient(ip), m.route)
+	if err != nil {
+		return ctx, err
+	}
+
+	ctx, err = i.authorize(ctx, m.scope)
+	if err != nil {
+		return ctx, err
+	}
+
+	routeLimit := ratelimit.RouteLimit(i.rateLimit.Default, i.rateLimit.Routes, m.route)
+	return ctx, limit(ctx, i.rateLimit.Limiter, routeLimit, ratelimit.Client(ctx, ip), m.route)
+}
+
+// limit counts the call of client on route with l, failing with codes.ResourceExhausted once over the limit
+func limit(ctx context.Context, l *ratelimit.Limiter, limit ratelimit.Limit, client string, route string) error {
+	if l == nil || limit.Rate <= 0 {
+		return nil
+	}
+
+	result := l.Allow(ratelimit.Key(client, route), limit)
+	if result.Allowed {
+		return nil
+	}
+	retryAfter := strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds())))
+	_ = grpc.SetHeader(ctx, metadata.Pairs(retryAfterKey, retryAfter))
+	return status.Error(codes.ResourceExhausted, "too many requests")
+}
+
+// authorize sets the principal authenticated from the call metadata in ctx,
+// failing when none is found or it lacks scope
+func (i *interceptor) authorize(ctx context.Context, scope string) (context.Context, error) {
+	r := httpRequest(ctx)
+	for _, a := range i.authenticators {
+		p, err := a.Authenticate(r)
+		if err == auth.ErrNoCredentials {
+			continue
+		}
+
+		if err != nil {
+			logging.FromContext(ctx).WithError(err).Warning("auth: rejected credentials")
+			break
+		}
+
+		if !p.HasScope(scope) {
+			return ctx, status.Error(codes.PermissionDenied, "forbidden")
+		}
+		return auth.WithPrincipal(ctx, p), nil
+	}
+	return ctx, status.Error(codes.Unauthenticated, "unauthorized")
+}
+
+// httpRequest exposes the call metadata as the headers of a request so that authenticators are shared
+// with the REST api, e.g the authorization metadata carries the api key or bearer token
+func httpRequest(ctx context.Context) *http.Request {
+	r := (&http.Request{Method: http.MethodPost, URL: &url.URL{}, Header: http.Header{}}).WithContext(ctx)
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		for _, value := range values {
+			r.Header.Add(key, value)
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		r.RemoteAddr = p.Addr.String()
+	}
+	return r
+}
+
+// peerIP returns the IP of the peer of the call, gRPC clients connect directly rather than through proxies
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	ip := p.Addr.String()
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return ip
+}
+
+// recoverUnary answers the panics of unary handlers with codes.Internal
+func recoverUnary(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(ctx, r)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// recoverStream answers the panics of stream handlers with codes.Internal
+func recoverStream(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(ss.Context(), r)
+		}
+	}()
+	return handler(srv, ss)
+}
+
+// recovered logs the panic r of a handler with its stack, like gin.Recovery does for requests
+func recovered(ctx context.Context, r interface{}) error {
+	logging.FromContext(ctx).WithFields(log.Fields{
+		"panic": fmt.Sprint(r),
+		"stack": string(debug.Stack()),
+	}).Error("rpc: handler panicked")
+	return status.Error(codes.Internal, "internal error")
+}
+
+// logCall logs the call once handled with its status code
+func logCall(ctx context.Context, start time.Time, err error) {
+	code := status.Code(err)
+	entry := logging.FromContext(ctx).WithFields(log.Fields{
+		"code":        code.String(),
+		"duration_ms": time.Since(start).Milliseconds(),
+	})
+	switch code {
+	case codes.OK:
+		entry.Info("call handled")
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		entry.Error("call handled")
+	default:
+		entry.Warn("call handled")
+	}
+}
@@ -0,0 +1,109 @@
+// Package rpc serves the weather API over gRPC, services share the weather.Handler logic with the REST API
+package rpc
+
+import (
+	"context"
+	"net"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/auth"
+	"github.com/walez/weather-monster/ratelimit"
+	"github.com/walez/weather-monster/rpc/weatherpb"
+	"github.com/walez/weather-monster/weather"
+
+	"google.golang.org/grpc"
+)
+
+// method is the scope required by a method and the REST route it mirrors, whose rate limit it shares
+type method struct {
+	scope string
+	route string
+}
+
+// methods are the methods served, methods missing from it are rejected
+var methods = map[string]method{
+	"/weather.v1.CityService/CreateCity":                {core.ScopeCitiesWrite, "POST /cities"},
+	"/weather.v1.CityService/UpdateCity":                {core.ScopeCitiesWrite, "PATCH /cities/:id"},
+	"/weather.v1.CityService/DeleteCity":                {core.ScopeCitiesWrite, "DELETE /cities/:id"},
+	"/weather.v1.TemperatureService/CreateTemperature":  {core.ScopeTemperaturesWrite, "POST /temperatures"},
+	"/weather.v1.TemperatureService/StreamTemperatures": {core.ScopeForecastsRead, "GET /cities/:id/stream"},
+	"/weather.v1.ForecastService/GetCityForecast":       {core.ScopeForecastsRead, "GET /forecasts/:city_id"},
+	"/weather.v1.WebhookService/CreateWebhook":          {core.ScopeWebhooksManage, "POST /webhooks"},
+	"/weather.v1.WebhookService/DeleteWebhook":          {core.ScopeWebhooksManage, "DELETE /webhooks/:id"},
+}
+
+// Server serves the weather services
+type Server struct {
+	grpc *grpc.Server
+}
+
+// Option configures optional behaviour of the server
+type Option func(*interceptor)
+
+// RateLimit throttles calls with the limiters of the REST api, so that clients have the same budget on
+// a method and the route it mirrors, see ratelimit.Middleware
+type RateLimit struct {
+	// IPLimiter limits peers to IPLimit on each method before they are authenticated
+	IPLimiter *ratelimit.Limiter
+	IPLimit   ratelimit.Limit
+	// Limiter limits authenticated clients to the limit of the route of the method in Routes, Default otherwise
+	Limiter *ratelimit.Limiter
+	Default ratelimit.Limit
+	Routes  map[string]ratelimit.Limit
+}
+
+// WithRateLimit throttles calls as rl configures, calls are not limited without it
+func WithRateLimit(rl RateLimit) Option {
+	return func(i *interceptor) {
+		i.rateLimit = rl
+	}
+}
+
+// NewServer registers the weather services backed by h.
+// Calls are authenticated with the first authenticator finding credentials in their metadata, as done by
+// auth.Middleware, and must be granted the scope of their method. Panics of the handlers are answered
+// with codes.Internal rather than crashing the process.
+func NewServer(
+	h *weather.Handler,
+	authenticators []auth.Authenticator,
+	opts ...Option,
+) *Server {
+	i := &interceptor{authenticators: authenticators, methods: methods}
+	for _, opt := range opts {
+		opt(i)
+	}
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(i.unary, recoverUnary),
+		grpc.ChainStreamInterceptor(i.stream, recoverStream),
+	)
+
+	svc := &service{h: h}
+	weatherpb.RegisterCityServiceServer(s, svc)
+	weatherpb.RegisterTemperatureServiceServer(s, svc)
+	weatherpb.RegisterForecastServiceServer(s, svc)
+	weatherpb.RegisterWebhookServiceServer(s, svc)
+	return &Server{grpc: s}
+}
+
+// Serve accepts connections on lis until the server is shut down
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// Shutdown stops accepting connections and waits for running calls, calls still running once ctx is done
+// are cancelled. Streams only end once their client cancels them or their subscription is closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.grpc.Stop()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,234 @@
+package rpc_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/auth"
+	"github.com/walez/weather-monster/events"
+	mocks "github.com/walez/weather-monster/mocks"
+	"github.com/walez/weather-monster/ratelimit"
+	"github.com/walez/weather-monster/rpc"
+	"github.com/walez/weather-monster/rpc/weatherpb"
+	"github.com/walez/weather-monster/weather"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// tokens are the bearer tokens accepted by the test server with the scopes they grant
+var tokens = map[string][]string{
+	"writer":  {core.ScopeTemperaturesWrite, core.ScopeForecastsRead},
+	"reader":  {core.ScopeForecastsRead},
+	"manager": {core.ScopeWebhooksManage},
+}
+
+func bearer(r *http.Request) (*auth.Principal, error) {
+	token := r.Header.Get("Authorization")
+	if token == "" {
+		return nil, auth.ErrNoCredentials
+	}
+	scopes, ok := tokens[token[len("Bearer "):]]
+	if !ok {
+		return nil, auth.ErrNoCredentials
+	}
+	return &auth.Principal{Subject: token, TenantID: 1, Scopes: scopes}, nil
+}
+
+func withToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+// serve starts the server on an in-memory listener and returns a connection to it
+func serve(t *testing.T, s *rpc.Server) *grpc.ClientConn {
+	lis := bufconn.Listen(1024 * 1024)
+	go s.Serve(lis)
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestServer(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ws := mocks.NewMockWeatherService(mockCtrl)
+	ws.EXPECT().GetCityWebhooks(gomock.Any(), gomock.Any()).AnyTimes()
+	ws.EXPECT().FindCitiesByIDs(gomock.Any(), []int64{1}).Return([]*core.City{{ID: 1, TenantID: 1}}, nil).AnyTimes()
+	ws.EXPECT().CreateTemperature(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, temperature *core.Temperature) error {
+		temperature.TenantID, _ = core.TenantFromContext(ctx)
+		temperature.ID = 10
+		temperature.Timestamp = time.Now().Unix()
+		return nil
+	}).AnyTimes()
+
+	audit := mocks.NewMockAuditService(mockCtrl)
+	broker := events.NewBroker()
+	h := weather.NewHandler(ws, events.NewManager(ctx), audit, weather.WithBroker(broker))
+
+	conn := serve(t, rpc.NewServer(h, []auth.Authenticator{auth.AuthenticatorFunc(bearer)}))
+	temperatures := weatherpb.NewTemperatureServiceClient(conn)
+	webhooks := weatherpb.NewWebhookServiceClient(conn)
+
+	t.Run("should reject calls without credentials", func(t *testing.T) {
+		_, err := temperatures.CreateTemperature(ctx, &weatherpb.CreateTemperatureRequest{CityId: 1})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("should reject calls without the method scope", func(t *testing.T) {
+		_, err := temperatures.CreateTemperature(withToken(ctx, "reader"), &weatherpb.CreateTemperatureRequest{CityId: 1})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("should stream created temperatures", func(t *testing.T) {
+		stream, err := temperatures.StreamTemperatures(withToken(ctx, "reader"), &weatherpb.StreamTemperaturesRequest{CityId: 1})
+		require.NoError(t, err)
+		header, err := stream.Header()
+		require.NoError(t, err)
+		assert.NotEmpty(t, header.Get("x-request-id"))
+
+		created, err := temperatures.CreateTemperature(withToken(ctx, "writer"), &weatherpb.CreateTemperatureRequest{CityId: 1, Max: 20, Min: 10})
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), created.Id)
+
+		received, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, created.Id, received.Id)
+		assert.Equal(t, int32(20), received.Max)
+		assert.Equal(t, int32(10), received.Min)
+	})
+
+	t.Run("should pin the payload version of created webhooks", func(t *testing.T) {
+		ws.EXPECT().CreateWebhook(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, webhook *core.Webhook) error {
+			webhook.ID = 4
+			return nil
+		}).Times(2)
+		audit.EXPECT().CreateAuditEntry(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+		var header metadata.MD
+		created, err := webhooks.CreateWebhook(withToken(ctx, "manager"),
+			&weatherpb.CreateWebhookRequest{CityId: 1, CallbackUrl: "https://example.com/callback", PayloadVersion: 1},
+			grpc.Header(&header))
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), created.PayloadVersion)
+		assert.NotEmpty(t, header.Get("x-webhook-secret"))
+
+		created, err = webhooks.CreateWebhook(withToken(ctx, "manager"),
+			&weatherpb.CreateWebhookRequest{CityId: 1, CallbackUrl: "https://example.com/callback"})
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), created.PayloadVersion, "the latest version is pinned when unset")
+
+		_, err = webhooks.CreateWebhook(withToken(ctx, "manager"),
+			&weatherpb.CreateWebhookRequest{CityId: 1, CallbackUrl: "https://example.com/callback", PayloadVersion: 99})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("should only answer not found and validation errors as such", func(t *testing.T) {
+		ws.EXPECT().FindWebhookByID(gomock.Any(), int64(5)).Return(nil, core.ErrNotFound)
+		_, err := webhooks.DeleteWebhook(withToken(ctx, "manager"), &weatherpb.DeleteWebhookRequest{Id: 5})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+
+		ws.EXPECT().FindWebhookByID(gomock.Any(), int64(6)).Return(nil, errors.New("connection refused"))
+		_, err = webhooks.DeleteWebhook(withToken(ctx, "manager"), &weatherpb.DeleteWebhookRequest{Id: 6})
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.Equal(t, "request failure", status.Convert(err).Message())
+	})
+
+	t.Run("should end streams once the broker is closed", func(t *testing.T) {
+		stream, err := temperatures.StreamTemperatures(withToken(ctx, "reader"), &weatherpb.StreamTemperaturesRequest{CityId: 1})
+		require.NoError(t, err)
+		_, err = stream.Header()
+		require.NoError(t, err)
+
+		broker.Close()
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ws := mocks.NewMockWeatherService(mockCtrl)
+	ws.EXPECT().GetCityForecast(gomock.Any(), int64(1)).Return(&core.Forecast{CityID: 1}, nil).AnyTimes()
+	h := weather.NewHandler(ws, events.NewManager(ctx), mocks.NewMockAuditService(mockCtrl))
+
+	conn := serve(t, rpc.NewServer(h, []auth.Authenticator{auth.AuthenticatorFunc(bearer)}, rpc.WithRateLimit(rpc.RateLimit{
+		IPLimiter: ratelimit.New(),
+		IPLimit:   ratelimit.PerMinute(3),
+		Limiter:   ratelimit.New(),
+		Default:   ratelimit.PerMinute(100),
+		Routes:    map[string]ratelimit.Limit{"GET /forecasts/:city_id": ratelimit.PerMinute(1)},
+	})))
+	forecasts := weatherpb.NewForecastServiceClient(conn)
+	temperatures := weatherpb.NewTemperatureServiceClient(conn)
+
+	t.Run("should limit the calls of clients per route", func(t *testing.T) {
+		_, err := forecasts.GetCityForecast(withToken(ctx, "reader"), &weatherpb.GetCityForecastRequest{CityId: 1})
+		require.NoError(t, err)
+
+		var header metadata.MD
+		_, err = forecasts.GetCityForecast(withToken(ctx, "reader"), &weatherpb.GetCityForecastRequest{CityId: 1}, grpc.Header(&header))
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		assert.NotEmpty(t, header.Get("retry-after"))
+
+		_, err = forecasts.GetCityForecast(withToken(ctx, "writer"), &weatherpb.GetCityForecastRequest{CityId: 1})
+		assert.NoError(t, err, "other clients have their own limit")
+	})
+
+	t.Run("should limit peers before authenticating them", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			_, err := temperatures.CreateTemperature(withToken(ctx, "invalid"), &weatherpb.CreateTemperatureRequest{CityId: 1})
+			require.Equal(t, codes.Unauthenticated, status.Code(err))
+		}
+		_, err := temperatures.CreateTemperature(withToken(ctx, "invalid"), &weatherpb.CreateTemperatureRequest{CityId: 1})
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+}
+
+func TestServer_Recovery(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ws := mocks.NewMockWeatherService(mockCtrl)
+	ws.EXPECT().GetCityForecast(gomock.Any(), int64(1)).DoAndReturn(func(context.Context, int64) (*core.Forecast, error) {
+		panic("forecast failure")
+	}).Times(2)
+	h := weather.NewHandler(ws, events.NewManager(ctx), mocks.NewMockAuditService(mockCtrl))
+
+	conn := serve(t, rpc.NewServer(h, []auth.Authenticator{auth.AuthenticatorFunc(bearer)}))
+	forecasts := weatherpb.NewForecastServiceClient(conn)
+
+	_, err := forecasts.GetCityForecast(withToken(ctx, "reader"), &weatherpb.GetCityForecastRequest{CityId: 1})
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	_, err = forecasts.GetCityForecast(withToken(ctx, "reader"), &weatherpb.GetCityForecastRequest{CityId: 1})
+	assert.Equal(t, codes.Internal, status.Code(err), "the server keeps serving after a panic")
+}
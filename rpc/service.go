@@ -0,0 +1,196 @@
+package rpc
+
+import (
+	"context"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/logging"
+	"github.com/walez/weather-monster/rpc/weatherpb"
+	"github.com/walez/weather-monster/weather"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// webhookSecretKey is the header metadata key of the secret of a created webhook, which only the
+// response of CreateWebhook holds, so that the Webhook message is unchanged
+const webhookSecretKey = "x-webhook-secret"
+
+// service implements the weather services on top of the handler shared with the REST api
+type service struct {
+	weatherpb.UnimplementedCityServiceServer
+	weatherpb.UnimplementedTemperatureServiceServer
+	weatherpb.UnimplementedForecastServiceServer
+	weatherpb.UnimplementedWebhookServiceServer
+
+	h *weather.Handler
+}
+
+func (s *service) CreateCity(ctx context.Context, req *weatherpb.CreateCityRequest) (*weatherpb.City, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name required")
+	}
+
+	city, err := s.h.CreateCity(ctx, &weather.CreateCityRequest{
+		Name:      &req.Name,
+		Latitude:  &req.Latitude,
+		Longitude: &req.Longitude,
+	})
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return cityMessage(city), nil
+}
+
+func (s *service) UpdateCity(ctx context.Context, req *weatherpb.UpdateCityRequest) (*weatherpb.City, error) {
+	city, err := s.h.UpdateCity(ctx, req.Id, &weather.CreateCityRequest{
+		Name:      req.Name,
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+	})
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return cityMessage(city), nil
+}
+
+func (s *service) DeleteCity(ctx context.Context, req *weatherpb.DeleteCityRequest) (*weatherpb.City, error) {
+	city, err := s.h.DeleteCity(ctx, req.Id)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return cityMessage(city), nil
+}
+
+func (s *service) CreateTemperature(ctx context.Context, req *weatherpb.CreateTemperatureRequest) (*weatherpb.Temperature, error) {
+	temperature, err := s.h.AddTemperature(ctx, &core.Temperature{
+		CityID: req.CityId,
+		Max:    int(req.Max),
+		Min:    int(req.Min),
+	})
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return temperatureMessage(temperature), nil
+}
+
+// StreamTemperatures sends the temperatures created for the city until the client cancels the call
+// or the subscription is closed
+func (s *service) StreamTemperatures(req *weatherpb.StreamTemperaturesRequest, stream weatherpb.TemperatureService_StreamTemperaturesServer) error {
+	ctx := stream.Context()
+	subscription, err := s.h.Subscribe(ctx, []int64{req.CityId}, 0)
+	if err != nil {
+		return toStatus(ctx, err)
+	}
+	defer subscription.Close()
+	logging.FromContext(ctx).WithField("city_id", req.CityId).Debug("rpc: streaming temperatures")
+
+	// headers tell the client that temperatures created from now on are streamed
+	err = stream.SendHeader(nil)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case temperature, ok := <-subscription.C:
+			if !ok {
+				if err := subscription.Err(); err != nil {
+					return status.Error(codes.ResourceExhausted, err.Error())
+				}
+				return status.Error(codes.Unavailable, "stream closed")
+			}
+
+			err := stream.Send(temperatureMessage(&temperature))
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *service) GetCityForecast(ctx context.Context, req *weatherpb.GetCityForecastRequest) (*weatherpb.Forecast, error) {
+	forecast, err := s.h.GetCityForecast(ctx, req.CityId)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &weatherpb.Forecast{
+		CityId: forecast.CityID,
+		Max:    forecast.Max,
+		Min:    forecast.Min,
+		Sample: forecast.Sample,
+	}, nil
+}
+
+func (s *service) CreateWebhook(ctx context.Context, req *weatherpb.CreateWebhookRequest) (*weatherpb.Webhook, error) {
+	webhook, err := s.h.AddWebhook(ctx, &core.Webhook{
+		CityID:         req.CityId,
+		CallbackURL:    req.CallbackUrl,
+		PayloadVersion: int(req.PayloadVersion),
+	})
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	err = grpc.SetHeader(ctx, metadata.Pairs(webhookSecretKey, webhook.Secret))
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).Error("rpc: unable to send webhook secret")
+	}
+	return webhookMessage(webhook), nil
+}
+
+func (s *service) DeleteWebhook(ctx context.Context, req *weatherpb.DeleteWebhookRequest) (*weatherpb.Webhook, error) {
+	webhook, err := s.h.DeleteWebhook(ctx, req.Id)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return webhookMessage(webhook), nil
+}
+
+// errorCodes answer each kind of error
+var errorCodes = map[weather.ErrorKind]codes.Code{
+	weather.KindInternal:      codes.Internal,
+	weather.KindInvalid:       codes.InvalidArgument,
+	weather.KindNotFound:      codes.NotFound,
+	weather.KindQuotaExceeded: codes.ResourceExhausted,
+}
+
+// toStatus logs err and converts it to the status answered to the client, see weather.ClassifyError
+func toStatus(ctx context.Context, err error) error {
+	logging.FromContext(ctx).WithError(err).Error("rpc: error processing call")
+
+	kind, message := weather.ClassifyError(err)
+	return status.Error(errorCodes[kind], message)
+}
+
+func cityMessage(city *core.City) *weatherpb.City {
+	return &weatherpb.City{
+		Id:        city.ID,
+		Name:      city.Name,
+		Latitude:  city.Latitude,
+		Longitude: city.Longitude,
+	}
+}
+
+func temperatureMessage(temperature *core.Temperature) *weatherpb.Temperature {
+	return &weatherpb.Temperature{
+		Id:        temperature.ID,
+		CityId:    temperature.CityID,
+		Max:       int32(temperature.Max),
+		Min:       int32(temperature.Min),
+		Timestamp: temperature.Timestamp,
+	}
+}
+
+func webhookMessage(webhook *core.Webhook) *weatherpb.Webhook {
+	return &weatherpb.Webhook{
+		Id:             webhook.ID,
+		CityId:         webhook.CityID,
+		CallbackUrl:    webhook.CallbackURL,
+		PayloadVersion: int32(webhook.PayloadVersion),
+	}
+}
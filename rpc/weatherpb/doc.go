@@ -0,0 +1,4 @@
+// Package weatherpb holds the protobuf messages and gRPC services of the weather API generated from weather.proto
+package weatherpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative weather.proto
@@ -0,0 +1,539 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package weatherpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// CityServiceClient is the client API for CityService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CityServiceClient interface {
+	// CreateCity returns the existing city of the same name when there is one
+	CreateCity(ctx context.Context, in *CreateCityRequest, opts ...grpc.CallOption) (*City, error)
+	UpdateCity(ctx context.Context, in *UpdateCityRequest, opts ...grpc.CallOption) (*City, error)
+	DeleteCity(ctx context.Context, in *DeleteCityRequest, opts ...grpc.CallOption) (*City, error)
+}
+
+type cityServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCityServiceClient(cc grpc.ClientConnInterface) CityServiceClient {
+	return &cityServiceClient{cc}
+}
+
+func (c *cityServiceClient) CreateCity(ctx context.Context, in *CreateCityRequest, opts ...grpc.CallOption) (*City, error) {
+	out := new(City)
+	err := c.cc.Invoke(ctx, "/weather.v1.CityService/CreateCity", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cityServiceClient) UpdateCity(ctx context.Context, in *UpdateCityRequest, opts ...grpc.CallOption) (*City, error) {
+	out := new(City)
+	err := c.cc.Invoke(ctx, "/weather.v1.CityService/UpdateCity", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cityServiceClient) DeleteCity(ctx context.Context, in *DeleteCityRequest, opts ...grpc.CallOption) (*City, error) {
+	out := new(City)
+	err := c.cc.Invoke(ctx, "/weather.v1.CityService/DeleteCity", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CityServiceServer is the server API for CityService service.
+// All implementations must embed UnimplementedCityServiceServer
+// for forward compatibility
+type CityServiceServer interface {
+	// CreateCity returns the existing city of the same name when there is one
+	CreateCity(context.Context, *CreateCityRequest) (*City, error)
+	UpdateCity(context.Context, *UpdateCityRequest) (*City, error)
+	DeleteCity(context.Context, *DeleteCityRequest) (*City, error)
+	mustEmbedUnimplementedCityServiceServer()
+}
+
+// UnimplementedCityServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedCityServiceServer struct {
+}
+
+func (UnimplementedCityServiceServer) CreateCity(context.Context, *CreateCityRequest) (*City, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateCity not implemented")
+}
+func (UnimplementedCityServiceServer) UpdateCity(context.Context, *UpdateCityRequest) (*City, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateCity not implemented")
+}
+func (UnimplementedCityServiceServer) DeleteCity(context.Context, *DeleteCityRequest) (*City, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteCity not implemented")
+}
+func (UnimplementedCityServiceServer) mustEmbedUnimplementedCityServiceServer() {}
+
+// UnsafeCityServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CityServiceServer will
+// result in compilation errors.
+type UnsafeCityServiceServer interface {
+	mustEmbedUnimplementedCityServiceServer()
+}
+
+func RegisterCityServiceServer(s grpc.ServiceRegistrar, srv CityServiceServer) {
+	s.RegisterService(&CityService_ServiceDesc, srv)
+}
+
+func _CityService_CreateCity_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateCityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CityServiceServer).CreateCity(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/weather.v1.CityService/CreateCity",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CityServiceServer).CreateCity(ctx, req.(*CreateCityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CityService_UpdateCity_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateCityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CityServiceServer).UpdateCity(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/weather.v1.CityService/UpdateCity",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CityServiceServer).UpdateCity(ctx, req.(*UpdateCityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CityService_DeleteCity_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CityServiceServer).DeleteCity(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/weather.v1.CityService/DeleteCity",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CityServiceServer).DeleteCity(ctx, req.(*DeleteCityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// CityService_ServiceDesc is the grpc.ServiceDesc for CityService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CityService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "weather.v1.CityService",
+	HandlerType: (*CityServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateCity",
+			Handler:    _CityService_CreateCity_Handler,
+		},
+		{
+			MethodName: "UpdateCity",
+			Handler:    _CityService_UpdateCity_Handler,
+		},
+		{
+			MethodName: "DeleteCity",
+			Handler:    _CityService_DeleteCity_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "weather.proto",
+}
+
+// TemperatureServiceClient is the client API for TemperatureService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TemperatureServiceClient interface {
+	// CreateTemperature records a temperature, it requires the temperatures:write scope
+	CreateTemperature(ctx context.Context, in *CreateTemperatureRequest, opts ...grpc.CallOption) (*Temperature, error)
+	// StreamTemperatures sends the temperatures of a city as they are created until the call is cancelled,
+	// it requires the forecasts:read scope
+	StreamTemperatures(ctx context.Context, in *StreamTemperaturesRequest, opts ...grpc.CallOption) (TemperatureService_StreamTemperaturesClient, error)
+}
+
+type temperatureServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTemperatureServiceClient(cc grpc.ClientConnInterface) TemperatureServiceClient {
+	return &temperatureServiceClient{cc}
+}
+
+func (c *temperatureServiceClient) CreateTemperature(ctx context.Context, in *CreateTemperatureRequest, opts ...grpc.CallOption) (*Temperature, error) {
+	out := new(Temperature)
+	err := c.cc.Invoke(ctx, "/weather.v1.TemperatureService/CreateTemperature", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *temperatureServiceClient) StreamTemperatures(ctx context.Context, in *StreamTemperaturesRequest, opts ...grpc.CallOption) (TemperatureService_StreamTemperaturesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TemperatureService_ServiceDesc.Streams[0], "/weather.v1.TemperatureService/StreamTemperatures", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &temperatureServiceStreamTemperaturesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TemperatureService_StreamTemperaturesClient interface {
+	Recv() (*Temperature, error)
+	grpc.ClientStream
+}
+
+type temperatureServiceStreamTemperaturesClient struct {
+	grpc.ClientStream
+}
+
+func (x *temperatureServiceStreamTemperaturesClient) Recv() (*Temperature, error) {
+	m := new(Temperature)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// TemperatureServiceServer is the server API for TemperatureService service.
+// All implementations must embed UnimplementedTemperatureServiceServer
+// for forward compatibility
+type TemperatureServiceServer interface {
+	// CreateTemperature records a temperature, it requires the temperatures:write scope
+	CreateTemperature(context.Context, *CreateTemperatureRequest) (*Temperature, error)
+	// StreamTemperatures sends the temperatures of a city as they are created until the call is cancelled,
+	// it requires the forecasts:read scope
+	StreamTemperatures(*StreamTemperaturesRequest, TemperatureService_StreamTemperaturesServer) error
+	mustEmbedUnimplementedTemperatureServiceServer()
+}
+
+// UnimplementedTemperatureServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedTemperatureServiceServer struct {
+}
+
+func (UnimplementedTemperatureServiceServer) CreateTemperature(context.Context, *CreateTemperatureRequest) (*Temperature, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateTemperature not implemented")
+}
+func (UnimplementedTemperatureServiceServer) StreamTemperatures(*StreamTemperaturesRequest, TemperatureService_StreamTemperaturesServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamTemperatures not implemented")
+}
+func (UnimplementedTemperatureServiceServer) mustEmbedUnimplementedTemperatureServiceServer() {}
+
+// UnsafeTemperatureServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TemperatureServiceServer will
+// result in compilation errors.
+type UnsafeTemperatureServiceServer interface {
+	mustEmbedUnimplementedTemperatureServiceServer()
+}
+
+func RegisterTemperatureServiceServer(s grpc.ServiceRegistrar, srv TemperatureServiceServer) {
+	s.RegisterService(&TemperatureService_ServiceDesc, srv)
+}
+
+func _TemperatureService_CreateTemperature_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateTemperatureRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TemperatureServiceServer).CreateTemperature(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/weather.v1.TemperatureService/CreateTemperature",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TemperatureServiceServer).CreateTemperature(ctx, req.(*CreateTemperatureRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TemperatureService_StreamTemperatures_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamTemperaturesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TemperatureServiceServer).StreamTemperatures(m, &temperatureServiceStreamTemperaturesServer{stream})
+}
+
+type TemperatureService_StreamTemperaturesServer interface {
+	Send(*Temperature) error
+	grpc.ServerStream
+}
+
+type temperatureServiceStreamTemperaturesServer struct {
+	grpc.ServerStream
+}
+
+func (x *temperatureServiceStreamTemperaturesServer) Send(m *Temperature) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// TemperatureService_ServiceDesc is the grpc.ServiceDesc for TemperatureService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TemperatureService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "weather.v1.TemperatureService",
+	HandlerType: (*TemperatureServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateTemperature",
+			Handler:    _TemperatureService_CreateTemperature_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamTemperatures",
+			Handler:       _TemperatureService_StreamTemperatures_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "weather.proto",
+}
+
+// ForecastServiceClient is the client API for ForecastService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ForecastServiceClient interface {
+	GetCityForecast(ctx context.Context, in *GetCityForecastRequest, opts ...grpc.CallOption) (*Forecast, error)
+}
+
+type forecastServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewForecastServiceClient(cc grpc.ClientConnInterface) ForecastServiceClient {
+	return &forecastServiceClient{cc}
+}
+
+func (c *forecastServiceClient) GetCityForecast(ctx context.Context, in *GetCityForecastRequest, opts ...grpc.CallOption) (*Forecast, error) {
+	out := new(Forecast)
+	err := c.cc.Invoke(ctx, "/weather.v1.ForecastService/GetCityForecast", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ForecastServiceServer is the server API for ForecastService service.
+// All implementations must embed UnimplementedForecastServiceServer
+// for forward compatibility
+type ForecastServiceServer interface {
+	GetCityForecast(context.Context, *GetCityForecastRequest) (*Forecast, error)
+	mustEmbedUnimplementedForecastServiceServer()
+}
+
+// UnimplementedForecastServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedForecastServiceServer struct {
+}
+
+func (UnimplementedForecastServiceServer) GetCityForecast(context.Context, *GetCityForecastRequest) (*Forecast, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCityForecast not implemented")
+}
+func (UnimplementedForecastServiceServer) mustEmbedUnimplementedForecastServiceServer() {}
+
+// UnsafeForecastServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ForecastServiceServer will
+// result in compilation errors.
+type UnsafeForecastServiceServer interface {
+	mustEmbedUnimplementedForecastServiceServer()
+}
+
+func RegisterForecastServiceServer(s grpc.ServiceRegistrar, srv ForecastServiceServer) {
+	s.RegisterService(&ForecastService_ServiceDesc, srv)
+}
+
+func _ForecastService_GetCityForecast_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCityForecastRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ForecastServiceServer).GetCityForecast(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/weather.v1.ForecastService/GetCityForecast",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ForecastServiceServer).GetCityForecast(ctx, req.(*GetCityForecastRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ForecastService_ServiceDesc is the grpc.ServiceDesc for ForecastService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ForecastService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "weather.v1.ForecastService",
+	HandlerType: (*ForecastServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetCityForecast",
+			Handler:    _ForecastService_GetCityForecast_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "weather.proto",
+}
+
+// WebhookServiceClient is the client API for WebhookService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type WebhookServiceClient interface {
+	CreateWebhook(ctx context.Context, in *CreateWebhookRequest, opts ...grpc.CallOption) (*Webhook, error)
+	DeleteWebhook(ctx context.Context, in *DeleteWebhookRequest, opts ...grpc.CallOption) (*Webhook, error)
+}
+
+type webhookServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWebhookServiceClient(cc grpc.ClientConnInterface) WebhookServiceClient {
+	return &webhookServiceClient{cc}
+}
+
+func (c *webhookServiceClient) CreateWebhook(ctx context.Context, in *CreateWebhookRequest, opts ...grpc.CallOption) (*Webhook, error) {
+	out := new(Webhook)
+	err := c.cc.Invoke(ctx, "/weather.v1.WebhookService/CreateWebhook", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *webhookServiceClient) DeleteWebhook(ctx context.Context, in *DeleteWebhookRequest, opts ...grpc.CallOption) (*Webhook, error) {
+	out := new(Webhook)
+	err := c.cc.Invoke(ctx, "/weather.v1.WebhookService/DeleteWebhook", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// WebhookServiceServer is the server API for WebhookService service.
+// All implementations must embed UnimplementedWebhookServiceServer
+// for forward compatibility
+type WebhookServiceServer interface {
+	CreateWebhook(context.Context, *CreateWebhookRequest) (*Webhook, error)
+	DeleteWebhook(context.Context, *DeleteWebhookRequest) (*Webhook, error)
+	mustEmbedUnimplementedWebhookServiceServer()
+}
+
+// UnimplementedWebhookServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedWebhookServiceServer struct {
+}
+
+func (UnimplementedWebhookServiceServer) CreateWebhook(context.Context, *CreateWebhookRequest) (*Webhook, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateWebhook not implemented")
+}
+func (UnimplementedWebhookServiceServer) DeleteWebhook(context.Context, *DeleteWebhookRequest) (*Webhook, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteWebhook not implemented")
+}
+func (UnimplementedWebhookServiceServer) mustEmbedUnimplementedWebhookServiceServer() {}
+
+// UnsafeWebhookServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WebhookServiceServer will
+// result in compilation errors.
+type UnsafeWebhookServiceServer interface {
+	mustEmbedUnimplementedWebhookServiceServer()
+}
+
+func RegisterWebhookServiceServer(s grpc.ServiceRegistrar, srv WebhookServiceServer) {
+	s.RegisterService(&WebhookService_ServiceDesc, srv)
+}
+
+func _WebhookService_CreateWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateWebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WebhookServiceServer).CreateWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/weather.v1.WebhookService/CreateWebhook",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WebhookServiceServer).CreateWebhook(ctx, req.(*CreateWebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WebhookService_DeleteWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteWebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WebhookServiceServer).DeleteWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/weather.v1.WebhookService/DeleteWebhook",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WebhookServiceServer).DeleteWebhook(ctx, req.(*DeleteWebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// WebhookService_ServiceDesc is the grpc.ServiceDesc for WebhookService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var WebhookService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "weather.v1.WebhookService",
+	HandlerType: (*WebhookServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateWebhook",
+			Handler:    _WebhookService_CreateWebhook_Handler,
+		},
+		{
+			MethodName: "DeleteWebhook",
+			Handler:    _WebhookService_DeleteWebhook_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "weather.proto",
+}
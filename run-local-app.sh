@@ -1,4 +1,6 @@
 #!/bin/bash
 set -e
 
-go run ./cmd/api/main.go
+LDFLAGS="-X main.commit=$(git rev-parse HEAD 2>/dev/null) -X main.branchName=$(git rev-parse --abbrev-ref HEAD 2>/dev/null) -X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+
+go run -ldflags "$LDFLAGS" ./cmd/api "$@"
@@ -0,0 +1,53 @@
+//go:generate mockgen --source tenant.go -destination mocks/tenant.go -package mocks
+
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNoTenant is returned by tenant scoped operations called without a tenant in their context
+	ErrNoTenant = errors.New("no tenant in context")
+	// ErrQuotaExceeded is returned when a tenant used up its daily ingestion quota
+	ErrQuotaExceeded = errors.New("daily ingestion quota exceeded")
+)
+
+// Tenant defines an organization owning cities, temperatures and webhooks isolated from other tenants
+type Tenant struct {
+	ID   int64  `json:"id,omitempty" gorm:"AUTO_INCREMENT;PRIMARY_KEY"`
+	Name string `json:"name,omitempty"`
+	// DailyTemperatureQuota caps the temperatures created per UTC day, zero means unlimited
+	DailyTemperatureQuota int64 `json:"daily_temperature_quota"`
+}
+
+// TenantUsage counts what a tenant ingested during a UTC day
+type TenantUsage struct {
+	TenantID     int64     `json:"tenant_id"`
+	Day          time.Time `json:"day"`
+	Temperatures int64     `json:"temperatures"`
+}
+
+type TenantService interface {
+	FindTenantByID(ctx context.Context, id int64) (*Tenant, error)
+	FindTenantByName(ctx context.Context, name string) (*Tenant, error)
+	ListTenants(ctx context.Context) ([]*Tenant, error)
+	CreateTenant(ctx context.Context, tenant *Tenant) error
+	SetDailyTemperatureQuota(ctx context.Context, tenantID int64, quota int64) error
+	GetTenantUsage(ctx context.Context, tenantID int64, day time.Time) (*TenantUsage, error)
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a context scoping weather operations to the tenant
+func WithTenant(ctx context.Context, tenantID int64) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant set by WithTenant
+func TenantFromContext(ctx context.Context) (int64, bool) {
+	tenantID, ok := ctx.Value(tenantKey{}).(int64)
+	return tenantID, ok
+}
@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"net/http"
+
+	core "github.com/walez/weather-monster"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// Middleware starts a span per request named after its route, continuing the trace propagated
+// in the request headers, and sets it in the request context
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tracer := opentracing.GlobalTracer()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		parent, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(c.Request.Header))
+		span := tracer.StartSpan("HTTP "+c.Request.Method+" "+route, ext.RPCServerOption(parent))
+		defer span.Finish()
+
+		ext.HTTPMethod.Set(span, c.Request.Method)
+		ext.HTTPUrl.Set(span, c.Request.URL.String())
+		ext.Component.Set(span, "gin")
+		if id := core.RequestIDFromContext(c.Request.Context()); id != "" {
+			span.SetTag("request_id", id)
+		}
+
+		c.Request = c.Request.WithContext(opentracing.ContextWithSpan(c.Request.Context(), span))
+		c.Next()
+
+		status := c.Writer.Status()
+		ext.HTTPStatusCode.Set(span, uint16(status))
+		if status >= http.StatusInternalServerError {
+			ext.Error.Set(span, true)
+		}
+	}
+}
+
+// InjectHeaders propagates the trace of span in the headers of an outbound request
+func InjectHeaders(span opentracing.Span, header http.Header) error {
+	return span.Tracer().Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header))
+}
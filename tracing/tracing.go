@@ -0,0 +1,112 @@
+// Package tracing records opentracing spans and hands them to a pluggable exporter
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/opentracing/basictracer-go"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Exporter receives every finished span
+type Exporter interface {
+	RecordSpan(span basictracer.RawSpan)
+}
+
+// New returns a tracer sampling sampleRate of the traces, between 0 and 1, and exporting them to exporter
+func New(exporter Exporter, sampleRate float64) opentracing.Tracer {
+	threshold := uint64(math.MaxUint64)
+	if sampleRate < 1 {
+		threshold = uint64(sampleRate * math.MaxUint64)
+	}
+
+	options := basictracer.DefaultOptions()
+	options.Recorder = exporter
+	options.TrimUnsampledSpans = true
+	options.ShouldSample = func(traceID uint64) bool {
+		return sampleRate > 0 && traceID <= threshold
+	}
+	return basictracer.NewWithOptions(options)
+}
+
+// NoopExporter drops every span
+type NoopExporter struct{}
+
+func (NoopExporter) RecordSpan(span basictracer.RawSpan) {}
+
+// JSONExporter writes sampled spans as JSON lines, e.g to stdout or a file for local testing
+type JSONExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{w: w}
+}
+
+// NewFileExporter appends spans to the file at path
+func NewFileExporter(path string) (*JSONExporter, io.Closer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "tracing: unable to open export file")
+	}
+	return NewJSONExporter(f), f, nil
+}
+
+// exportedSpan is the JSON representation of a span, ids are hex encoded as in propagated headers
+type exportedSpan struct {
+	TraceID   string                 `json:"trace_id"`
+	SpanID    string                 `json:"span_id"`
+	ParentID  string                 `json:"parent_id,omitempty"`
+	Operation string                 `json:"operation"`
+	Start     time.Time              `json:"start"`
+	Duration  time.Duration          `json:"duration_ns"`
+	Tags      map[string]interface{} `json:"tags,omitempty"`
+	Logs      []map[string]string    `json:"logs,omitempty"`
+}
+
+func (e *JSONExporter) RecordSpan(span basictracer.RawSpan) {
+	if !span.Context.Sampled {
+		return
+	}
+
+	exported := exportedSpan{
+		TraceID:   fmt.Sprintf("%x", span.Context.TraceID),
+		SpanID:    fmt.Sprintf("%x", span.Context.SpanID),
+		Operation: span.Operation,
+		Start:     span.Start,
+		Duration:  span.Duration,
+		Tags:      span.Tags,
+	}
+	if span.ParentSpanID != 0 {
+		exported.ParentID = fmt.Sprintf("%x", span.ParentSpanID)
+	}
+	for _, record := range span.Logs {
+		fields := map[string]string{}
+		for _, field := range record.Fields {
+			fields[field.Key()] = fmt.Sprint(field.Value())
+		}
+		exported.Logs = append(exported.Logs, fields)
+	}
+
+	line, err := json.Marshal(exported)
+	if err != nil {
+		log.WithError(err).Warning("tracing: unable to encode span")
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err = e.w.Write(append(line, '\n'))
+	if err != nil {
+		log.WithError(err).Warning("tracing: unable to export span")
+	}
+}
@@ -0,0 +1,95 @@
+package tracing_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/walez/weather-monster/tracing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/basictracer-go"
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	recorder := basictracer.NewInMemoryRecorder()
+	tracer := tracing.New(recorder, 1)
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	r := gin.New()
+	r.Use(tracing.Middleware())
+	r.GET("/cities/:id", func(c *gin.Context) {
+		span := opentracing.SpanFromContext(c.Request.Context())
+		assert.NotNil(t, span, "request context carries the span")
+		c.Status(http.StatusOK)
+	})
+
+	t.Run("should start a trace named after the route", func(t *testing.T) {
+		recorder.Reset()
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/cities/1", nil))
+
+		spans := recorder.GetSpans()
+		require.Len(t, spans, 1)
+		assert.Equal(t, "HTTP GET /cities/:id", spans[0].Operation)
+		assert.Equal(t, uint16(http.StatusOK), spans[0].Tags["http.status_code"])
+		assert.Zero(t, spans[0].ParentSpanID)
+	})
+
+	t.Run("should continue a propagated trace", func(t *testing.T) {
+		recorder.Reset()
+		parent := tracer.StartSpan("client")
+		req := httptest.NewRequest(http.MethodGet, "/cities/1", nil)
+		require.NoError(t, tracing.InjectHeaders(parent, req.Header))
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := recorder.GetSpans()
+		require.Len(t, spans, 1)
+		parentContext := parent.Context().(basictracer.SpanContext)
+		assert.Equal(t, parentContext.TraceID, spans[0].Context.TraceID)
+		assert.Equal(t, parentContext.SpanID, spans[0].ParentSpanID)
+	})
+}
+
+func TestJSONExporter(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	tracer := tracing.New(tracing.NewJSONExporter(buf), 1)
+	parent := tracer.StartSpan("parent")
+	child := tracer.StartSpan("child", opentracing.ChildOf(parent.Context()))
+	child.SetTag("db.statement", "SELECT 1")
+	child.LogKV("event", "done")
+	child.Finish()
+	parent.Finish()
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var exported struct {
+		TraceID   string                 `json:"trace_id"`
+		SpanID    string                 `json:"span_id"`
+		ParentID  string                 `json:"parent_id"`
+		Operation string                 `json:"operation"`
+		Tags      map[string]interface{} `json:"tags"`
+		Logs      []map[string]string    `json:"logs"`
+	}
+	require.NoError(t, json.Unmarshal(lines[0], &exported))
+	assert.Equal(t, "child", exported.Operation)
+	assert.NotEmpty(t, exported.TraceID)
+	assert.NotEmpty(t, exported.ParentID)
+	assert.Equal(t, "SELECT 1", exported.Tags["db.statement"])
+	assert.Equal(t, []map[string]string{{"event": "done"}}, exported.Logs)
+
+	t.Run("should not export unsampled traces", func(t *testing.T) {
+		buf.Reset()
+		tracing.New(tracing.NewJSONExporter(buf), 0).StartSpan("unsampled").Finish()
+		assert.Empty(t, buf.String())
+	})
+}
@@ -7,7 +7,8 @@ import "context"
 // City defines a location where temperatures and forecasts can be reported and gotten
 type City struct {
 	ID        int64   `json:"id,omitempty"  gorm:"AUTO_INCREMENT;PRIMARY_KEY"`
-	Name      string  `json:"name,omitempty" gorm:"unique;not null"`
+	TenantID  int64   `json:"-"`
+	Name      string  `json:"name,omitempty" gorm:"not null"`
 	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
 	IsDeleted bool    `json:"-" gorm:"column:is_deleted"`
@@ -16,6 +17,7 @@ type City struct {
 // Temperature defines a temperature measurement in Celsuis
 type Temperature struct {
 	ID        int64 `json:"id,omitempty"  gorm:"AUTO_INCREMENT"`
+	TenantID  int64 `json:"-"`
 	CityID    int64 `json:"city_id,omitempty"`
 	Max       int   `json:"max"`
 	Min       int   `json:"min"`
@@ -33,11 +35,18 @@ type Forecast struct {
 // Webhook defines an entity for subscribing to temperature changes
 type Webhook struct {
 	ID          int64  `json:"id,omitempty"  gorm:"AUTO_INCREMENT"`
+	TenantID    int64  `json:"-"`
 	CityID      int64  `json:"city_id,omitempty"`
 	CallbackURL string `json:"callback_url,omitempty"`
-	IsDeleted   bool   `json:"-" gorm:"column:is_deleted"`
+	// Secret signs the deliveries, it is only answered when the webhook is created.
+	// Webhooks created before secrets were introduced have none and their deliveries are not signed.
+	Secret string `json:"-"`
+	// PayloadVersion is the version of the payloads posted to the callback, see the webhook package
+	PayloadVersion int  `json:"payload_version"`
+	IsDeleted      bool `json:"-" gorm:"column:is_deleted"`
 }
 
+// WeatherService manages the weather entities of the tenant set in the context with WithTenant
 type WeatherService interface {
 	FindCityByID(ctx context.Context, id int64) (*City, error)
 	FindCityByName(ctx context.Context, name string) (*City, error)
@@ -47,6 +56,17 @@ type WeatherService interface {
 	GetCityForecast(ctx context.Context, cityID int64) (*Forecast, error)
 	GetCityWebhooks(ctx context.Context, cityID int64) ([]*Webhook, error)
 
+	// ListCities returns up to limit cities ordered by id, starting after the city of id cursor
+	ListCities(ctx context.Context, cursor int64, limit int) ([]*City, error)
+
+	// Batch lookups load the entities of several cities in a single query,
+	// cities without any are missing from the result
+	FindCitiesByIDs(ctx context.Context, ids []int64) ([]*City, error)
+	GetCitiesForecasts(ctx context.Context, cityIDs []int64) ([]*Forecast, error)
+	GetCitiesWebhooks(ctx context.Context, cityIDs []int64) ([]*Webhook, error)
+	// GetCitiesTemperatures returns up to limit of the most recent temperatures of each city, most recent first
+	GetCitiesTemperatures(ctx context.Context, cityIDs []int64, limit int) ([]*Temperature, error)
+
 	CreateTemperature(ctx context.Context, temperature *Temperature) error
 
 	FindWebhookByID(ctx context.Context, id int64) (*Webhook, error)
@@ -0,0 +1,63 @@
+package weather
+
+import (
+	"context"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/auth"
+	"github.com/walez/weather-monster/logging"
+)
+
+// systemActor is the actor of mutations made outside of an authenticated request
+const systemActor = "system"
+
+func (h *Handler) ListAuditEntries(
+	ctx context.Context,
+	filter core.AuditFilter,
+) ([]*core.AuditEntry, error) {
+
+	return h.as.ListAuditEntries(ctx, filter)
+}
+
+// audit records a mutation of an entity with its state before and after, nil when it did not exist.
+// Failures are only logged as the mutation already happened.
+func (h *Handler) audit(
+	ctx context.Context,
+	action string,
+	entity string,
+	entityID int64,
+	before interface{},
+	after interface{},
+) {
+
+	actor := systemActor
+	if p, ok := auth.PrincipalFromContext(ctx); ok {
+		actor = p.Subject
+	}
+
+	entry := &core.AuditEntry{
+		Actor:     actor,
+		Action:    action,
+		Entity:    entity,
+		EntityID:  entityID,
+		RequestID: core.RequestIDFromContext(ctx),
+	}
+
+	var err error
+	entry.Before, err = core.MarshalJSONValue(before)
+	if err == nil {
+		entry.After, err = core.MarshalJSONValue(after)
+	}
+	if err == nil {
+		err = h.as.CreateAuditEntry(ctx, entry)
+	}
+
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).WithFields(map[string]interface{}{
+			"actor":     actor,
+			"action":    action,
+			"entity":    entity,
+			"entity_id": entityID,
+		}).Error("weather handler: unable to record audit entry")
+	}
+}
@@ -0,0 +1,167 @@
+package weather_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/auth"
+	"github.com/walez/weather-monster/events"
+	mocks "github.com/walez/weather-monster/mocks"
+	"github.com/walez/weather-monster/weather"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_Audit(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var entries []*core.AuditEntry
+	as := mocks.NewMockAuditService(mockCtrl)
+	as.EXPECT().CreateAuditEntry(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, entry *core.AuditEntry) error {
+		entries = append(entries, entry)
+		return nil
+	}).AnyTimes()
+
+	ws := mocks.NewMockWeatherService(mockCtrl)
+	ws.EXPECT().FindCityByID(gomock.Any(), int64(1)).DoAndReturn(func(ctx context.Context, id int64) (*core.City, error) {
+		return &core.City{ID: 1, Name: "Berlin", Latitude: 52.5, Longitude: 13.4}, nil
+	}).AnyTimes()
+	ws.EXPECT().FindCityByID(gomock.Any(), gomock.Any()).Return(nil, errors.New("record not found")).AnyTimes()
+	ws.EXPECT().UpdateCity(gomock.Any(), gomock.Any()).Return(nil)
+	ws.EXPECT().DeleteCity(gomock.Any(), gomock.Any()).Return(nil)
+	ws.EXPECT().FindWebhookByID(gomock.Any(), int64(2)).Return(&core.Webhook{ID: 2, CityID: 1, CallbackURL: "callback"}, nil)
+	ws.EXPECT().DeleteWebhook(gomock.Any(), gomock.Any()).Return(nil)
+	ws.EXPECT().CreateWebhook(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, webhook *core.Webhook) error {
+		webhook.ID = 3
+		return nil
+	})
+
+	h := weather.NewHandler(ws, events.NewManager(context.Background()), as)
+
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "api_key:3", TenantID: 1})
+	ctx = core.WithRequestID(ctx, "request-1")
+
+	t.Run("should record city update with before and after", func(t *testing.T) {
+		entries = nil
+		name := "Berlin Mitte"
+		_, err := h.UpdateCity(ctx, 1, &weather.CreateCityRequest{Name: &name})
+		require.NoError(t, err)
+
+		require.Len(t, entries, 1)
+		entry := entries[0]
+		assert.Equal(t, "api_key:3", entry.Actor)
+		assert.Equal(t, core.AuditUpdate, entry.Action)
+		assert.Equal(t, core.AuditEntityCity, entry.Entity)
+		assert.Equal(t, int64(1), entry.EntityID)
+		assert.Equal(t, "request-1", entry.RequestID)
+		assert.JSONEq(t, `{"id": 1, "name": "Berlin", "latitude": 52.5, "longitude": 13.4}`, string(entry.Before))
+		assert.JSONEq(t, `{"id": 1, "name": "Berlin Mitte", "latitude": 52.5, "longitude": 13.4}`, string(entry.After))
+	})
+
+	t.Run("should record city deletion without after", func(t *testing.T) {
+		entries = nil
+		_, err := h.DeleteCity(ctx, 1)
+		require.NoError(t, err)
+
+		require.Len(t, entries, 1)
+		assert.Equal(t, core.AuditDelete, entries[0].Action)
+		assert.NotEmpty(t, entries[0].Before)
+		assert.Empty(t, entries[0].After)
+	})
+
+	t.Run("should record webhook deletion", func(t *testing.T) {
+		entries = nil
+		_, err := h.DeleteWebhook(ctx, 2)
+		require.NoError(t, err)
+
+		require.Len(t, entries, 1)
+		assert.Equal(t, core.AuditEntityWebhook, entries[0].Entity)
+		assert.Equal(t, int64(2), entries[0].EntityID)
+	})
+
+	t.Run("should record webhook creation without its secret", func(t *testing.T) {
+		entries = nil
+		webhook, err := h.AddWebhook(ctx, &core.Webhook{CityID: 1, CallbackURL: "callback"})
+		require.NoError(t, err)
+		assert.Regexp(t, "^whsec_[0-9a-f]{48}$", webhook.Secret)
+
+		require.Len(t, entries, 1)
+		assert.JSONEq(t, `{"id": 3, "city_id": 1, "callback_url": "callback", "payload_version": 2}`, string(entries[0].After))
+	})
+
+	t.Run("should reject unknown payload versions", func(t *testing.T) {
+		entries = nil
+		_, err := h.AddWebhook(ctx, &core.Webhook{CityID: 1, CallbackURL: "callback", PayloadVersion: 99})
+		require.Error(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("should not record failed mutations", func(t *testing.T) {
+		entries = nil
+		_, err := h.DeleteCity(ctx, 10)
+		require.Error(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("should record system actor outside of requests", func(t *testing.T) {
+		entries = nil
+		ws.EXPECT().DeleteCity(gomock.Any(), gomock.Any()).Return(nil)
+		_, err := h.DeleteCity(core.WithTenant(context.Background(), 1), 1)
+		require.NoError(t, err)
+
+		require.Len(t, entries, 1)
+		assert.Equal(t, "system", entries[0].Actor)
+	})
+}
+
+func TestRoutes_Audit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	as := mocks.NewMockAuditService(mockCtrl)
+	as.EXPECT().ListAuditEntries(gomock.Any(), core.AuditFilter{Entity: "city", EntityID: 1, Limit: 2}).Return([]*core.AuditEntry{{ID: 9}, {ID: 7}}, nil)
+	as.EXPECT().ListAuditEntries(gomock.Any(), core.AuditFilter{Entity: "city", EntityID: 1, Cursor: 7, Limit: 2}).Return([]*core.AuditEntry{{ID: 4}}, nil)
+	as.EXPECT().ListAuditEntries(gomock.Any(), core.AuditFilter{Entity: "city", EntityID: 2, Limit: 2}).Return(nil, nil)
+
+	h := weather.NewHandler(mocks.NewMockWeatherService(mockCtrl), events.NewManager(context.Background()), as)
+
+	r := gin.New()
+	h.RegisterRoutes(r.Group(weather.V1Path, auth.Middleware(auth.DefaultTenant(&core.Tenant{ID: 1}, core.ScopeAuditRead))))
+
+	get := func(url string) (*httptest.ResponseRecorder, *weather.AuditResponse) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+
+		response := &weather.AuditResponse{}
+		json.Unmarshal(w.Body.Bytes(), response)
+		return w, response
+	}
+
+	w, page := get("/v1/audit?entity=city&id=1&limit=2")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, page.Entries, 2)
+	assert.Equal(t, int64(7), page.NextCursor)
+
+	w, page = get("/v1/audit?entity=city&id=1&limit=2&cursor=7")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, page.Entries, 1)
+	assert.Zero(t, page.NextCursor, "last page has no cursor")
+
+	w, _ = get("/v1/audit?entity=city&id=2&limit=2")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"entries": []}`, w.Body.String(), "no entries are an empty list")
+
+	w, _ = get("/v1/audit?limit=1000")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
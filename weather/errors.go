@@ -0,0 +1,43 @@
+package weather
+
+import (
+	"errors"
+
+	core "github.com/walez/weather-monster"
+)
+
+// ErrorKind classifies the errors of the handler by what the client can do about them,
+// the REST, gRPC and GraphQL apis each translate it to their own status
+type ErrorKind int
+
+const (
+	// KindInternal errors are not caused by the request, e.g the database is unreachable
+	KindInternal ErrorKind = iota
+	// KindInvalid errors reject the input of the request, see core.ValidationError
+	KindInvalid
+	// KindNotFound errors are answered for entities that do not exist or belong to another tenant
+	KindNotFound
+	// KindQuotaExceeded errors are answered once the tenant used up its daily ingestion quota
+	KindQuotaExceeded
+)
+
+// ClassifyError returns the kind of err and the message answered to the client.
+// Only the quota error is detailed, the others could leak the internals of the service.
+func ClassifyError(err error) (ErrorKind, string) {
+	var invalid *core.ValidationError
+	switch {
+	case errors.Is(err, core.ErrQuotaExceeded):
+		return KindQuotaExceeded, err.Error()
+	case errors.As(err, &invalid):
+		return KindInvalid, "request failure"
+	case errors.Is(err, core.ErrNotFound):
+		return KindNotFound, "request failure"
+	default:
+		return KindInternal, "request failure"
+	}
+}
+
+// invalidRequest reports the error binding a request as a validation error
+func invalidRequest(err error) error {
+	return &core.ValidationError{Message: err.Error()}
+}
@@ -3,39 +3,108 @@ package weather
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"net/http"
+	"strconv"
+	"time"
 
 	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/logging"
+	"github.com/walez/weather-monster/metrics"
+	"github.com/walez/weather-monster/tracing"
+	wh "github.com/walez/weather-monster/webhook"
 
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 	"github.com/pkg/errors"
-	log "github.com/sirupsen/logrus"
 )
 
 func (h *Handler) CallCityWebhooks(ctx context.Context, temperature *core.Temperature) error {
-	log.Info("making callback request for temperature")
+	logger := logging.FromContext(ctx).WithField("city_id", temperature.CityID)
+	logger.Debug("temperature listener: calling city webhooks")
 	webhooks, err := h.ws.GetCityWebhooks(ctx, temperature.CityID)
 	if err != nil {
 		return errors.Wrap(err, "temperature listener: unable to fetch webhooks")
 	}
 
-	payload := map[string]interface{}{
-		"city_id":   temperature.CityID,
-		"max":       temperature.Max,
-		"min":       temperature.Min,
-		"timestamp": temperature.Timestamp,
-	}
-	j, err := json.Marshal(payload)
-	if err != nil {
-		return errors.Wrap(err, "temperature listener: unable to marshal payload")
+	// versions after the first one detail the city, when it cannot be fetched only their webhooks are skipped
+	var city *core.City
+	for _, webhook := range webhooks {
+		if webhook.PayloadVersion != wh.Version1 {
+			city, err = h.ws.FindCityByID(ctx, temperature.CityID)
+			if err != nil {
+				logger.WithError(err).Error("temperature listener: unable to fetch city, skipping webhooks detailing it")
+			}
+			break
+		}
 	}
 
-	b := bytes.NewReader(j)
+	payloads := map[int][]byte{}
 	for _, webhook := range webhooks {
-		_, reqErr := http.Post(webhook.CallbackURL, "application/json", b)
-		if err != nil {
-			log.Errorf("issue making posting callback data: %v", reqErr)
+		payload, ok := payloads[webhook.PayloadVersion]
+		if !ok {
+			if city == nil && webhook.PayloadVersion != wh.Version1 {
+				logger.WithField("webhook_id", webhook.ID).Warning("temperature listener: skipping webhook without city")
+				continue
+			}
+
+			payload, err = wh.NewPayload(webhook.PayloadVersion, city, temperature)
+			if err != nil {
+				logger.WithError(err).WithField("webhook_id", webhook.ID).Error("temperature listener: unable to marshal payload")
+				continue
+			}
+			payloads[webhook.PayloadVersion] = payload
 		}
+
+		h.deliverWebhook(ctx, webhook, deliveryID(webhook, temperature), payload)
 	}
 	return nil
 }
+
+// deliveryID identifies the delivery of a temperature to a webhook, receivers deduplicate deliveries with it
+func deliveryID(webhook *core.Webhook, temperature *core.Temperature) string {
+	return strconv.FormatInt(webhook.ID, 10) + "-" + strconv.FormatInt(temperature.ID, 10)
+}
+
+// deliverWebhook posts payload to the webhook callback signed with its secret, propagating the trace of ctx
+func (h *Handler) deliverWebhook(ctx context.Context, webhook *core.Webhook, deliveryID string, payload []byte) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "webhook.deliver")
+	defer span.Finish()
+	ext.SpanKindRPCClient.Set(span)
+	ext.HTTPMethod.Set(span, http.MethodPost)
+	ext.HTTPUrl.Set(span, webhook.CallbackURL)
+	span.SetTag("webhook.id", webhook.ID)
+	span.SetTag("webhook.delivery", deliveryID)
+	logger := logging.FromContext(ctx).WithField("webhook_id", webhook.ID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.CallbackURL, bytes.NewReader(payload))
+	if err != nil {
+		ext.Error.Set(span, true)
+		logger.WithError(err).Error("webhook: unable to create callback request")
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if webhook.Secret != "" {
+		wh.SetHeaders(req.Header, webhook.Secret, deliveryID, time.Now(), payload)
+	} else {
+		req.Header.Set(wh.DeliveryHeader, deliveryID)
+	}
+
+	err = tracing.InjectHeaders(span, req.Header)
+	if err != nil {
+		logger.WithError(err).Warning("webhook: unable to propagate trace to callback request")
+	}
+
+	res, err := h.httpClient.Do(req)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogKV("error", err.Error())
+		metrics.WebhookDeliveries.WithLabelValues("error").Inc()
+		logger.WithError(err).Error("webhook: callback failed")
+		return
+	}
+	res.Body.Close()
+
+	ext.HTTPStatusCode.Set(span, uint16(res.StatusCode))
+	logger.WithField("status", res.StatusCode).Debug("webhook: delivered")
+	metrics.WebhookDeliveries.WithLabelValues(strconv.Itoa(res.StatusCode)).Inc()
+}
@@ -0,0 +1,118 @@
+package weather_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/events"
+	mocks "github.com/walez/weather-monster/mocks"
+	"github.com/walez/weather-monster/tracing"
+	"github.com/walez/weather-monster/webhook"
+
+	"github.com/golang/mock/gomock"
+	"github.com/opentracing/basictracer-go"
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_CallCityWebhooks(t *testing.T) {
+	recorder := basictracer.NewInMemoryRecorder()
+	tracer := tracing.New(recorder, 1)
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	type delivery struct {
+		header  http.Header
+		payload []byte
+	}
+	received := make(chan delivery, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := io.ReadAll(r.Body)
+		received <- delivery{header: r.Header, payload: payload}
+	}))
+	defer server.Close()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ws := mocks.NewMockWeatherService(mockCtrl)
+	ws.EXPECT().GetCityWebhooks(gomock.Any(), int64(1)).Return([]*core.Webhook{
+		{ID: 1, CityID: 1, CallbackURL: server.URL, Secret: "whsec_test", PayloadVersion: webhook.Version2},
+		{ID: 2, CityID: 1, CallbackURL: server.URL, PayloadVersion: webhook.Version1},
+	}, nil)
+	ws.EXPECT().FindCityByID(gomock.Any(), int64(1)).Return(&core.City{ID: 1, Name: "Lagos"}, nil)
+
+	h := testHandler(ws, events.NewManager(context.Background()))
+
+	listener := tracer.StartSpan("events.temperature_created")
+	ctx := opentracing.ContextWithSpan(context.Background(), listener)
+	err := h.CallCityWebhooks(ctx, &core.Temperature{ID: 7, CityID: 1, Max: 10, Min: 5})
+	require.NoError(t, err)
+	listener.Finish()
+
+	traceID := listener.Context().(basictracer.SpanContext).TraceID
+	var deliveries []delivery
+	for i := 0; i < 2; i++ {
+		d := <-received
+		extracted, err := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(d.header))
+		require.NoError(t, err, "callbacks carry the trace")
+		assert.Equal(t, traceID, extracted.(basictracer.SpanContext).TraceID)
+		deliveries = append(deliveries, d)
+	}
+
+	signed := deliveries[0]
+	assert.Equal(t, "1-7", signed.header.Get(webhook.DeliveryHeader))
+	assert.NoError(t, webhook.Verify("whsec_test", signed.header, signed.payload, time.Minute, time.Now()))
+	event, err := webhook.ParseEvent(signed.payload)
+	require.NoError(t, err)
+	assert.Equal(t, webhook.Version2, event.Version, "payloads have the version of their webhook")
+	assert.Equal(t, "Lagos", event.City.Name)
+
+	unsigned := deliveries[1]
+	assert.Equal(t, "2-7", unsigned.header.Get(webhook.DeliveryHeader))
+	assert.Empty(t, unsigned.header.Get(webhook.SignatureHeader), "webhooks without secret are not signed")
+	assert.JSONEq(t, `{"city_id": 1, "max": 10, "min": 5, "timestamp": 0}`, string(unsigned.payload))
+
+	var spans []basictracer.RawSpan
+	for _, span := range recorder.GetSpans() {
+		if span.Operation == "webhook.deliver" {
+			spans = append(spans, span)
+		}
+	}
+	require.Len(t, spans, 2)
+	assert.Equal(t, uint16(http.StatusOK), spans[0].Tags["http.status_code"])
+}
+
+func TestHandler_CallCityWebhooks_MissingCity(t *testing.T) {
+	received := make(chan []byte, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := io.ReadAll(r.Body)
+		received <- payload
+	}))
+	defer server.Close()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ws := mocks.NewMockWeatherService(mockCtrl)
+	ws.EXPECT().GetCityWebhooks(gomock.Any(), int64(1)).Return([]*core.Webhook{
+		{ID: 1, CityID: 1, CallbackURL: server.URL, PayloadVersion: webhook.Version2},
+		{ID: 2, CityID: 1, CallbackURL: server.URL, PayloadVersion: webhook.Version1},
+		{ID: 3, CityID: 1, CallbackURL: server.URL, PayloadVersion: webhook.Version2},
+	}, nil)
+	ws.EXPECT().FindCityByID(gomock.Any(), int64(1)).Return(nil, errors.New("connection refused")).Times(1)
+
+	h := testHandler(ws, events.NewManager(context.Background()))
+	err := h.CallCityWebhooks(context.Background(), &core.Temperature{ID: 7, CityID: 1, Max: 10, Min: 5})
+	require.NoError(t, err)
+
+	require.Len(t, received, 1, "only the webhooks detailing the city are skipped")
+	assert.JSONEq(t, `{"city_id": 1, "max": 10, "min": 5, "timestamp": 0}`, string(<-received))
+}
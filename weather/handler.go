@@ -2,32 +2,100 @@ package weather
 
 import (
 	"context"
+	"net/http"
 	"strconv"
+	"time"
 
 	core "github.com/walez/weather-monster"
 	"github.com/walez/weather-monster/events"
+	"github.com/walez/weather-monster/metrics"
+	wh "github.com/walez/weather-monster/webhook"
 
 	"github.com/pkg/errors"
 )
 
 type Handler struct {
-	ws core.WeatherService
-	em *events.Manager
+	ws         core.WeatherService
+	em         *events.Manager
+	as         core.AuditService
+	httpClient *http.Client
+
+	broker        *events.Broker
+	subscribeOpts []events.SubscribeOption
+	heartbeat     time.Duration
+}
+
+// HandlerOption configures optional behaviour of the handler
+type HandlerOption func(*Handler)
+
+// WithHTTPClient sets the client calling webhooks, http.DefaultClient by default
+func WithHTTPClient(client *http.Client) HandlerOption {
+	return func(h *Handler) {
+		h.httpClient = client
+	}
+}
+
+// WithBroker streams live temperatures from broker, the handler feeds it with the temperatures created.
+// By default the handler uses a broker of its own.
+func WithBroker(b *events.Broker) HandlerOption {
+	return func(h *Handler) {
+		h.broker = b
+	}
+}
+
+// WithStream sets the options of the subscriptions of live streams, e.g their buffer and overflow policy,
+// and how often idle streams send a heartbeat so that proxies keep them open
+func WithStream(heartbeat time.Duration, opts ...events.SubscribeOption) HandlerOption {
+	return func(h *Handler) {
+		h.heartbeat = heartbeat
+		h.subscribeOpts = opts
+	}
 }
 
 func NewHandler(
 	ws core.WeatherService,
 	em *events.Manager,
+	as core.AuditService,
+	opts ...HandlerOption,
 ) *Handler {
 	h := &Handler{
-		ws: ws,
-		em: em,
+		ws:         ws,
+		em:         em,
+		as:         as,
+		httpClient: http.DefaultClient,
+		heartbeat:  DefaultHeartbeat,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.broker == nil {
+		h.broker = events.NewBroker()
 	}
 
 	h.em.RegisterTemperatureListener(events.TemperatureCreated, h.CallCityWebhooks)
+	h.em.RegisterTemperatureListener(events.TemperatureCreated, h.broker.Publish)
 	return h
 }
 
+// FindCity returns the city of id
+func (h *Handler) FindCity(
+	ctx context.Context,
+	id int64,
+) (*core.City, error) {
+
+	return h.ws.FindCityByID(ctx, id)
+}
+
+// ListCities returns up to limit cities ordered by id, starting after the city of id cursor
+func (h *Handler) ListCities(
+	ctx context.Context,
+	cursor int64,
+	limit int,
+) ([]*core.City, error) {
+
+	return h.ws.ListCities(ctx, cursor, limit)
+}
+
 func (h *Handler) CreateCity(
 	ctx context.Context,
 	input *CreateCityRequest,
@@ -51,6 +119,7 @@ func (h *Handler) CreateCity(
 		return nil, err
 	}
 
+	h.audit(ctx, core.AuditCreate, core.AuditEntityCity, city.ID, nil, city)
 	return city, nil
 }
 
@@ -66,6 +135,8 @@ func (h *Handler) UpdateCity(
 		return nil, err
 	}
 
+	before := *city
+
 	// Update city
 	if input.Name != nil {
 		city.Name = *input.Name
@@ -84,6 +155,7 @@ func (h *Handler) UpdateCity(
 		return nil, err
 	}
 
+	h.audit(ctx, core.AuditUpdate, core.AuditEntityCity, city.ID, &before, city)
 	return city, nil
 }
 
@@ -103,6 +175,7 @@ func (h *Handler) DeleteCity(
 		return nil, err
 	}
 
+	h.audit(ctx, core.AuditDelete, core.AuditEntityCity, city.ID, city, nil)
 	return city, nil
 }
 
@@ -120,6 +193,20 @@ func (h *Handler) GetCityForecast(
 	return forecast, nil
 }
 
+// GetCityWebhooks returns the webhooks of the city, the city must exist
+func (h *Handler) GetCityWebhooks(
+	ctx context.Context,
+	cityID int64,
+) ([]*core.Webhook, error) {
+
+	_, err := h.ws.FindCityByID(ctx, cityID)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.ws.GetCityWebhooks(ctx, cityID)
+}
+
 func (h *Handler) CreateTemperature(
 	ctx context.Context,
 	input *CreateTemperatureRequest,
@@ -128,20 +215,34 @@ func (h *Handler) CreateTemperature(
 	// Create new temperature
 	cityID, err := strconv.ParseInt(input.CityID, 10, 64)
 	if err != nil {
-		return nil, errors.Wrapf(err, "create temperature: invalid city id ")
+		return nil, core.Invalid("invalid city_id sent")
 	}
-	temperature := &core.Temperature{
+
+	return h.AddTemperature(ctx, &core.Temperature{
 		CityID: cityID,
 		Max:    input.Max,
 		Min:    input.Min,
-	}
+	})
+}
+
+// AddTemperature records temperature and notifies its listeners, it is shared by the api versions
+// once they have decoded their request
+func (h *Handler) AddTemperature(
+	ctx context.Context,
+	temperature *core.Temperature,
+) (*core.Temperature, error) {
 
-	err = h.ws.CreateTemperature(ctx, temperature)
+	err := h.ws.CreateTemperature(ctx, temperature)
 	if err != nil {
 		return nil, err
 	}
 
-	h.em.NotifyTemperatureListeners(events.TemperatureCreated, temperature)
+	metrics.TemperaturesIngested.WithLabelValues(
+		strconv.FormatInt(temperature.TenantID, 10),
+		strconv.FormatInt(temperature.CityID, 10),
+	).Inc()
+
+	h.em.NotifyTemperatureListeners(ctx, events.TemperatureCreated, temperature)
 	return temperature, nil
 }
 
@@ -152,18 +253,43 @@ func (h *Handler) CreateWebhook(
 
 	cityID, err := strconv.ParseInt(input.CityID, 10, 64)
 	if err != nil {
-		return nil, errors.Wrapf(err, "create webhook: invalid city id ")
+		return nil, core.Invalid("invalid city_id sent")
+	}
+
+	return h.AddWebhook(ctx, &core.Webhook{
+		CityID:         cityID,
+		CallbackURL:    input.CallbackURL,
+		PayloadVersion: input.PayloadVersion,
+	})
+}
+
+// AddWebhook subscribes webhook to the temperatures of its city, it is shared by the api versions
+// once they have decoded their request. It generates the secret signing the deliveries, the only
+// time it can be answered to the caller, and pins the latest payload version when none is set.
+func (h *Handler) AddWebhook(
+	ctx context.Context,
+	webhook *core.Webhook,
+) (*core.Webhook, error) {
+
+	if webhook.PayloadVersion == 0 {
+		webhook.PayloadVersion = wh.LatestVersion
 	}
-	webhook := &core.Webhook{
-		CityID:      cityID,
-		CallbackURL: input.CallbackURL,
+	if !wh.ValidVersion(webhook.PayloadVersion) {
+		return nil, core.Invalid("unknown payload version %d, available versions: %v", webhook.PayloadVersion, wh.Versions)
+	}
+
+	secret, err := wh.NewSecret()
+	if err != nil {
+		return nil, errors.Wrap(err, "create webhook: unable to generate secret")
 	}
+	webhook.Secret = secret
 
 	err = h.ws.CreateWebhook(ctx, webhook)
 	if err != nil {
 		return nil, err
 	}
 
+	h.audit(ctx, core.AuditCreate, core.AuditEntityWebhook, webhook.ID, nil, webhook)
 	return webhook, nil
 }
 
@@ -183,5 +309,6 @@ func (h *Handler) DeleteWebhook(
 		return nil, err
 	}
 
+	h.audit(ctx, core.AuditDelete, core.AuditEntityWebhook, webhook.ID, webhook, nil)
 	return webhook, nil
 }
@@ -47,7 +47,7 @@ func TestHandler_CreateCity(t *testing.T) {
 	ws.EXPECT().FindCityByName(gomock.Any(), gomock.Any()).Return(nil, errors.New("record exists"))
 	ws.EXPECT().CreateCity(gomock.Any(), gomock.Any()).Return(nil)
 
-	em := events.NewManager()
+	em := events.NewManager(context.Background())
 
 	ctx := context.Background()
 	cityTwo := "City Two"
@@ -154,7 +154,7 @@ func TestHandler_UpdateCity(t *testing.T) {
 	ws.EXPECT().FindCityByID(gomock.Any(), gomock.Any()).Return(nil, errors.New("record non exists"))
 	ws.EXPECT().UpdateCity(gomock.Any(), gomock.Any()).Return(nil)
 
-	em := events.NewManager()
+	em := events.NewManager(context.Background())
 
 	ctx := context.Background()
 	cityTwo := "City Two"
@@ -260,7 +260,7 @@ func TestHandler_DeleteCity(t *testing.T) {
 	ws.EXPECT().FindCityByID(gomock.Any(), gomock.Any()).Return(nil, errors.New("record non exists"))
 	ws.EXPECT().DeleteCity(gomock.Any(), gomock.Any()).Return(nil)
 
-	em := events.NewManager()
+	em := events.NewManager(context.Background())
 
 	ctx := context.Background()
 	tests := []test{
@@ -326,5 +326,16 @@ func testHandler(
 	ws core.WeatherService,
 	em *events.Manager,
 ) *weather.Handler {
-	return weather.NewHandler(ws, em)
+	return weather.NewHandler(ws, em, discardAudit{})
+}
+
+// discardAudit drops audit entries for tests not asserting them
+type discardAudit struct{}
+
+func (discardAudit) CreateAuditEntry(ctx context.Context, entry *core.AuditEntry) error {
+	return nil
+}
+
+func (discardAudit) ListAuditEntries(ctx context.Context, filter core.AuditFilter) ([]*core.AuditEntry, error) {
+	return nil, nil
 }
@@ -1,58 +1,87 @@
 package weather
 
 import (
-	"errors"
 	"net/http"
 	"strconv"
 
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/auth"
+	"github.com/walez/weather-monster/logging"
+
 	"github.com/gin-gonic/gin"
-	log "github.com/sirupsen/logrus"
 )
 
-// All the path constants in the weather API.
+// Versions of the weather API, each is mounted under its own path so that a breaking change ships
+// as a new version next to the previous ones. Versions share the Handler methods and only differ by
+// their routes and request types, e.g CreateTemperatureRequest is the v1 contract taking ids as strings.
+const (
+	V1Path = "/v1"
+)
+
+// All the path constants in the weather API, relative to the version path.
 const (
-	BasePath       = ""
 	CityPath       = "cities"
 	SingleCityPath = "cities/:id"
+	CityStreamPath = "cities/:id/stream"
 
 	ForecastPath = "forecasts/:city_id"
 
-	TemperaturePath = "temperatures"
+	TemperaturePath       = "temperatures"
+	TemperatureStreamPath = "temperatures/stream"
 
 	WebhookPath       = "webhooks"
 	SingleWebhookPath = "webhooks/:id"
+
+	AuditPath = "audit"
 )
 
-// RegisterRoutes adds all the endpoints exposed by this feature
+// Page sizes of the listings when the request sets no limit
+const (
+	defaultCityLimit  = 50
+	defaultAuditLimit = 50
+)
+
+// RegisterRoutes adds all the v1 endpoints exposed by this feature,
+// rg must authenticate requests with auth.Middleware
 func (h *Handler) RegisterRoutes(rg *gin.RouterGroup) {
 
-	rg.POST(CityPath, h.handleCityCreateRequest)
-	rg.PATCH(SingleCityPath, h.handleCityUpdateRequest)
-	rg.DELETE(SingleCityPath, h.handleCityDeleteRequest)
+	citiesWrite := auth.RequireScope(core.ScopeCitiesWrite)
+	rg.POST(CityPath, citiesWrite, h.handleCityCreateRequest)
+	rg.PATCH(SingleCityPath, citiesWrite, h.handleCityUpdateRequest)
+	rg.DELETE(SingleCityPath, citiesWrite, h.handleCityDeleteRequest)
 
-	rg.GET(ForecastPath, h.handleForecastRequest)
+	forecastsRead := auth.RequireScope(core.ScopeForecastsRead)
+	rg.GET(CityPath, forecastsRead, h.handleCityListRequest)
+	rg.GET(SingleCityPath, forecastsRead, h.handleCityRequest)
+	rg.GET(ForecastPath, forecastsRead, h.handleForecastRequest)
+	rg.GET(CityStreamPath, forecastsRead, h.handleCityStreamRequest)
+	rg.GET(TemperatureStreamPath, forecastsRead, h.handleTemperatureStreamRequest)
 
-	rg.POST(TemperaturePath, h.handleTemperatureCreateRequest)
+	rg.POST(TemperaturePath, auth.RequireScope(core.ScopeTemperaturesWrite), h.handleTemperatureCreateRequest)
 
-	rg.POST(WebhookPath, h.handleWebhookCreateRequest)
-	rg.DELETE(SingleWebhookPath, h.handleWebhookDeleteRequest)
+	webhooksManage := auth.RequireScope(core.ScopeWebhooksManage)
+	rg.GET(WebhookPath, webhooksManage, h.handleWebhookListRequest)
+	rg.POST(WebhookPath, webhooksManage, h.handleWebhookCreateRequest)
+	rg.DELETE(SingleWebhookPath, webhooksManage, h.handleWebhookDeleteRequest)
+
+	rg.GET(AuditPath, auth.RequireScope(core.ScopeAuditRead), h.handleAuditRequest)
 }
 
 func (h *Handler) handleForecastRequest(ctx *gin.Context) {
 	id := ctx.Param("city_id")
 	if id == "" {
-		h.handleError(ctx, errors.New("city_id required"))
+		h.handleError(ctx, core.Invalid("city_id required"))
 		return
 	}
 
 	cityID, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
-		h.handleError(ctx, errors.New("invalid city_id sent"))
+		h.handleError(ctx, core.Invalid("invalid city_id sent"))
 		return
 	}
 
-	log.Debugf("city ID: %v", cityID)
-	forecast, err := h.GetCityForecast(ctx, cityID)
+	logging.FromContext(ctx.Request.Context()).WithField("city_id", cityID).Debug("weather handler: forecast requested")
+	forecast, err := h.GetCityForecast(ctx.Request.Context(), cityID)
 	if err != nil {
 		h.handleError(ctx, err)
 		return
@@ -61,17 +90,65 @@ func (h *Handler) handleForecastRequest(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, forecast)
 }
 
+func (h *Handler) handleCityListRequest(ctx *gin.Context) {
+	query := &CityQuery{}
+
+	err := ctx.ShouldBindQuery(query)
+	if err != nil {
+		h.handleError(ctx, invalidRequest(err))
+		return
+	}
+
+	if query.Limit == 0 {
+		query.Limit = defaultCityLimit
+	}
+
+	logging.FromContext(ctx.Request.Context()).WithField("cursor", query.Cursor).Debug("weather handler: listing cities")
+	cities, err := h.ListCities(ctx.Request.Context(), query.Cursor, query.Limit)
+	if err != nil {
+		h.handleError(ctx, err)
+		return
+	}
+
+	response := &CitiesResponse{Cities: cities}
+	if response.Cities == nil {
+		response.Cities = []*core.City{}
+	}
+	if len(cities) == query.Limit {
+		response.NextCursor = cities[len(cities)-1].ID
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) handleCityRequest(ctx *gin.Context) {
+	cityID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		h.handleError(ctx, core.Invalid("invalid city_id sent"))
+		return
+	}
+
+	logging.FromContext(ctx.Request.Context()).WithField("city_id", cityID).Debug("weather handler: city requested")
+	city, err := h.FindCity(ctx.Request.Context(), cityID)
+	if err != nil {
+		h.handleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, city)
+}
+
 func (h *Handler) handleCityCreateRequest(ctx *gin.Context) {
 	body := &CreateCityRequest{}
 
 	err := ctx.ShouldBind(body)
 	if err != nil {
-		h.handleError(ctx, err)
+		h.handleError(ctx, invalidRequest(err))
 		return
 	}
 
-	log.Debugf("request body: %#v", body)
-	city, err := h.CreateCity(ctx, body)
+	logging.FromContext(ctx.Request.Context()).Debug("weather handler: creating city")
+	city, err := h.CreateCity(ctx.Request.Context(), body)
 	if err != nil {
 		h.handleError(ctx, err)
 		return
@@ -83,25 +160,25 @@ func (h *Handler) handleCityCreateRequest(ctx *gin.Context) {
 func (h *Handler) handleCityUpdateRequest(ctx *gin.Context) {
 	id := ctx.Param("id")
 	if id == "" {
-		h.handleError(ctx, errors.New("city_id required"))
+		h.handleError(ctx, core.Invalid("city_id required"))
 		return
 	}
 
 	cityID, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
-		h.handleError(ctx, errors.New("invalid city_id sent"))
+		h.handleError(ctx, core.Invalid("invalid city_id sent"))
 		return
 	}
 
 	body := &CreateCityRequest{}
 	err = ctx.ShouldBind(body)
 	if err != nil {
-		h.handleError(ctx, err)
+		h.handleError(ctx, invalidRequest(err))
 		return
 	}
 
-	log.Debugf("request body: %#v, city ID: %v", body, cityID)
-	city, err := h.UpdateCity(ctx, cityID, body)
+	logging.FromContext(ctx.Request.Context()).WithField("city_id", cityID).Debug("weather handler: updating city")
+	city, err := h.UpdateCity(ctx.Request.Context(), cityID, body)
 	if err != nil {
 		h.handleError(ctx, err)
 		return
@@ -113,18 +190,18 @@ func (h *Handler) handleCityUpdateRequest(ctx *gin.Context) {
 func (h *Handler) handleCityDeleteRequest(ctx *gin.Context) {
 	id := ctx.Param("id")
 	if id == "" {
-		h.handleError(ctx, errors.New("city_id required"))
+		h.handleError(ctx, core.Invalid("city_id required"))
 		return
 	}
 
 	cityID, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
-		h.handleError(ctx, errors.New("invalid city_id sent"))
+		h.handleError(ctx, core.Invalid("invalid city_id sent"))
 		return
 	}
 
-	log.Debugf("city ID: %v", cityID)
-	city, err := h.DeleteCity(ctx, cityID)
+	logging.FromContext(ctx.Request.Context()).WithField("city_id", cityID).Debug("weather handler: deleting city")
+	city, err := h.DeleteCity(ctx.Request.Context(), cityID)
 	if err != nil {
 		h.handleError(ctx, err)
 		return
@@ -138,12 +215,12 @@ func (h *Handler) handleTemperatureCreateRequest(ctx *gin.Context) {
 
 	err := ctx.ShouldBind(body)
 	if err != nil {
-		h.handleError(ctx, err)
+		h.handleError(ctx, invalidRequest(err))
 		return
 	}
 
-	log.Debugf("request body: %#v", body)
-	city, err := h.CreateTemperature(ctx, body)
+	logging.FromContext(ctx.Request.Context()).WithField("city_id", body.CityID).Debug("weather handler: creating temperature")
+	city, err := h.CreateTemperature(ctx.Request.Context(), body)
 	if err != nil {
 		h.handleError(ctx, err)
 		return
@@ -152,40 +229,62 @@ func (h *Handler) handleTemperatureCreateRequest(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, city)
 }
 
+func (h *Handler) handleWebhookListRequest(ctx *gin.Context) {
+	query := &WebhookQuery{}
+
+	err := ctx.ShouldBindQuery(query)
+	if err != nil {
+		h.handleError(ctx, invalidRequest(err))
+		return
+	}
+
+	logging.FromContext(ctx.Request.Context()).WithField("city_id", query.CityID).Debug("weather handler: listing webhooks")
+	webhooks, err := h.GetCityWebhooks(ctx.Request.Context(), query.CityID)
+	if err != nil {
+		h.handleError(ctx, err)
+		return
+	}
+
+	if webhooks == nil {
+		webhooks = []*core.Webhook{}
+	}
+	ctx.JSON(http.StatusOK, webhooks)
+}
+
 func (h *Handler) handleWebhookCreateRequest(ctx *gin.Context) {
 	body := &CreateWebhookRequest{}
 
 	err := ctx.ShouldBind(body)
 	if err != nil {
-		h.handleError(ctx, err)
+		h.handleError(ctx, invalidRequest(err))
 		return
 	}
 
-	log.Debugf("request body: %#v", body)
-	city, err := h.CreateWebhook(ctx, body)
+	logging.FromContext(ctx.Request.Context()).WithField("city_id", body.CityID).Debug("weather handler: creating webhook")
+	webhook, err := h.CreateWebhook(ctx.Request.Context(), body)
 	if err != nil {
 		h.handleError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, city)
+	ctx.JSON(http.StatusOK, newCreateWebhookResponse(webhook))
 }
 
 func (h *Handler) handleWebhookDeleteRequest(ctx *gin.Context) {
 	id := ctx.Param("id")
 	if id == "" {
-		h.handleError(ctx, errors.New("webhook_id required"))
+		h.handleError(ctx, core.Invalid("webhook_id required"))
 		return
 	}
 
 	webhookID, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
-		h.handleError(ctx, errors.New("invalid webhook_id sent"))
+		h.handleError(ctx, core.Invalid("invalid webhook_id sent"))
 		return
 	}
 
-	log.Debugf("webhook ID: %v", webhookID)
-	webhook, err := h.DeleteWebhook(ctx, webhookID)
+	logging.FromContext(ctx.Request.Context()).WithField("webhook_id", webhookID).Debug("weather handler: deleting webhook")
+	webhook, err := h.DeleteWebhook(ctx.Request.Context(), webhookID)
 	if err != nil {
 		h.handleError(ctx, err)
 		return
@@ -193,3 +292,45 @@ func (h *Handler) handleWebhookDeleteRequest(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, webhook)
 }
+
+func (h *Handler) handleAuditRequest(ctx *gin.Context) {
+	query := &AuditQuery{}
+
+	err := ctx.ShouldBindQuery(query)
+	if err != nil {
+		h.handleError(ctx, invalidRequest(err))
+		return
+	}
+
+	if query.Limit == 0 {
+		query.Limit = defaultAuditLimit
+	}
+
+	logging.FromContext(ctx.Request.Context()).WithFields(map[string]interface{}{
+		"entity":    query.Entity,
+		"entity_id": query.ID,
+		"cursor":    query.Cursor,
+	}).Debug("weather handler: listing audit entries")
+	entries, err := h.ListAuditEntries(ctx.Request.Context(), core.AuditFilter{
+		Entity:   query.Entity,
+		EntityID: query.ID,
+		Actor:    query.Actor,
+		Action:   query.Action,
+		Cursor:   query.Cursor,
+		Limit:    query.Limit,
+	})
+	if err != nil {
+		h.handleError(ctx, err)
+		return
+	}
+
+	response := &AuditResponse{Entries: entries}
+	if response.Entries == nil {
+		response.Entries = []*core.AuditEntry{}
+	}
+	if len(entries) == query.Limit {
+		response.NextCursor = entries[len(entries)-1].ID
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,99 @@
+package weather_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/auth"
+	"github.com/walez/weather-monster/events"
+	mocks "github.com/walez/weather-monster/mocks"
+	"github.com/walez/weather-monster/weather"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutes_TemperatureQuota(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ws := mocks.NewMockWeatherService(mockCtrl)
+	ws.EXPECT().CreateTemperature(gomock.Any(), gomock.Any()).Return(core.ErrQuotaExceeded)
+
+	h := testHandler(ws, events.NewManager(context.Background()))
+
+	r := gin.New()
+	h.RegisterRoutes(r.Group(weather.V1Path, auth.Middleware(auth.DefaultTenant(&core.Tenant{ID: 1}, core.ScopeTemperaturesWrite))))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/temperatures", strings.NewReader(`{"city_id": "1", "max": 10, "min": 5}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), core.ErrQuotaExceeded.Error())
+}
+
+func TestRoutes_Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ws := mocks.NewMockWeatherService(mockCtrl)
+	ws.EXPECT().FindCityByID(gomock.Any(), int64(1)).Return(nil, core.ErrNotFound)
+	ws.EXPECT().FindCityByID(gomock.Any(), int64(2)).Return(nil, errors.New("connection refused"))
+
+	h := testHandler(ws, events.NewManager(context.Background()))
+
+	r := gin.New()
+	h.RegisterRoutes(r.Group(weather.V1Path, auth.Middleware(auth.DefaultTenant(&core.Tenant{ID: 1}, core.ScopeForecastsRead))))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest, get("/v1/cities/abc").Code)
+	assert.Equal(t, http.StatusNotFound, get("/v1/cities/1").Code)
+
+	w := get("/v1/cities/2")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"message": "request failure"}`, w.Body.String(), "internal errors are not detailed")
+}
+
+func TestRoutes_CityList(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ws := mocks.NewMockWeatherService(mockCtrl)
+	ws.EXPECT().ListCities(gomock.Any(), int64(0), 2).Return([]*core.City{{ID: 1}, {ID: 2}}, nil)
+	ws.EXPECT().ListCities(gomock.Any(), int64(2), 2).Return([]*core.City{{ID: 3}}, nil)
+
+	h := testHandler(ws, events.NewManager(context.Background()))
+
+	r := gin.New()
+	h.RegisterRoutes(r.Group(weather.V1Path, auth.Middleware(auth.DefaultTenant(&core.Tenant{ID: 1}, core.ScopeForecastsRead))))
+
+	list := func(query string) string {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/cities?"+query, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	assert.JSONEq(t, `{"cities": [{"id": 1, "latitude": 0, "longitude": 0}, {"id": 2, "latitude": 0, "longitude": 0}], "next_cursor": 2}`,
+		list("limit=2"), "full pages link to the next one")
+	assert.JSONEq(t, `{"cities": [{"id": 3, "latitude": 0, "longitude": 0}]}`, list("limit=2&cursor=2"))
+}
@@ -0,0 +1,264 @@
+package weather
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/events"
+	"github.com/walez/weather-monster/logging"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// DefaultHeartbeat is how often idle streams send a heartbeat
+const DefaultHeartbeat = 15 * time.Second
+
+// LastEventIDHeader is sent by SSE clients reconnecting with the id of the last temperature they received
+const LastEventIDHeader = "Last-Event-ID"
+
+// temperatureEvent names the SSE events carrying a temperature
+const temperatureEvent = "temperature"
+
+// maxStreamCities bounds the cities of a WebSocket stream, which are looked up when it is opened
+const maxStreamCities = 50
+
+const (
+	// wsWriteWait bounds writing a message, clients not reading are disconnected
+	wsWriteWait = 10 * time.Second
+	// wsMaxMessageSize bounds messages read from clients, which only send control messages
+	wsMaxMessageSize = 512
+)
+
+// upgrader accepts any origin: credentials are sent explicitly rather than by the browser so other
+// origins can not open a stream on behalf of a user
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// Subscribe subscribes to the temperatures created for the cities of the tenant in ctx, resuming after
+// the temperature of id lastEventID when not 0. The cities must exist, close the subscription once done.
+func (h *Handler) Subscribe(ctx context.Context, cityIDs []int64, lastEventID int64) (*events.Subscription, error) {
+	cities, err := h.ws.FindCitiesByIDs(ctx, cityIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[int64]bool, len(cities))
+	for _, city := range cities {
+		found[city.ID] = true
+	}
+	for _, id := range cityIDs {
+		if !found[id] {
+			return nil, core.ErrNotFound
+		}
+	}
+
+	tenantID, _ := core.TenantFromContext(ctx)
+	opts := append([]events.SubscribeOption{events.ResumeAfter(lastEventID)}, h.subscribeOpts...)
+	return h.broker.Subscribe(tenantID, cityIDs, opts...), nil
+}
+
+// handleCityStreamRequest streams the temperatures of a city as Server-Sent Events whose id is the temperature id,
+// clients reconnecting with the Last-Event-ID header first receive the temperatures they missed
+func (h *Handler) handleCityStreamRequest(ctx *gin.Context) {
+	cityID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		h.handleError(ctx, core.Invalid("invalid city_id sent"))
+		return
+	}
+
+	lastEventID, err := parseLastEventID(ctx.GetHeader(LastEventIDHeader))
+	if err != nil {
+		h.handleError(ctx, err)
+		return
+	}
+
+	subscription, err := h.Subscribe(ctx.Request.Context(), []int64{cityID}, lastEventID)
+	if err != nil {
+		h.handleError(ctx, err)
+		return
+	}
+	defer subscription.Close()
+
+	logger := logging.FromContext(ctx.Request.Context()).WithField("city_id", cityID)
+	logger.Debug("weather handler: streaming temperatures")
+
+	ctx.Header("Content-Type", sse.ContentType)
+	ctx.Header("Cache-Control", "no-cache")
+	// disables response buffering by nginx
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+
+	send := func(temperature core.Temperature) error {
+		err := sse.Encode(ctx.Writer, sse.Event{
+			Id:    strconv.FormatInt(temperature.ID, 10),
+			Event: temperatureEvent,
+			Data:  temperature,
+		})
+		ctx.Writer.Flush()
+		return err
+	}
+
+	for _, temperature := range subscription.Replay {
+		if send(temperature) != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case temperature, ok := <-subscription.C:
+			if !ok {
+				if err := subscription.Err(); err != nil {
+					logger.WithError(err).Warning("weather handler: stream disconnected")
+				}
+				return
+			}
+			if send(temperature) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			// comments are ignored by clients
+			_, err := io.WriteString(ctx.Writer, ":\n\n")
+			if err != nil {
+				return
+			}
+			ctx.Writer.Flush()
+		}
+	}
+}
+
+// handleTemperatureStreamRequest streams the temperatures of the cities given by the city_id query parameters
+// over a WebSocket, each message is a temperature. Clients resume with the last_event_id query parameter.
+// Clients too slow to receive temperatures are closed with the 1013 (try again later) code.
+func (h *Handler) handleTemperatureStreamRequest(ctx *gin.Context) {
+	ids := ctx.QueryArray("city_id")
+	if len(ids) > maxStreamCities {
+		h.handleError(ctx, core.Invalid("at most %d city_id allowed", maxStreamCities))
+		return
+	}
+
+	var cityIDs []int64
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		cityID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			h.handleError(ctx, core.Invalid("invalid city_id sent"))
+			return
+		}
+		if !seen[cityID] {
+			seen[cityID] = true
+			cityIDs = append(cityIDs, cityID)
+		}
+	}
+	if len(cityIDs) == 0 {
+		h.handleError(ctx, core.Invalid("city_id required"))
+		return
+	}
+
+	lastEventID, err := parseLastEventID(ctx.Query("last_event_id"))
+	if err != nil {
+		h.handleError(ctx, err)
+		return
+	}
+
+	subscription, err := h.Subscribe(ctx.Request.Context(), cityIDs, lastEventID)
+	if err != nil {
+		h.handleError(ctx, err)
+		return
+	}
+	defer subscription.Close()
+
+	logger := logging.FromContext(ctx.Request.Context()).WithField("city_ids", cityIDs)
+	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		// the upgrader answered the client already
+		logger.WithError(err).Warning("weather handler: websocket upgrade failed")
+		return
+	}
+	defer conn.Close()
+	logger.Debug("weather handler: streaming temperatures")
+
+	// reading handles control messages and notices the client going away
+	closed := make(chan struct{})
+	conn.SetReadLimit(wsMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(2 * h.heartbeat))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * h.heartbeat))
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(temperature core.Temperature) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		return conn.WriteJSON(temperature)
+	}
+	closeWith := func(code int, text string) {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(wsWriteWait))
+	}
+
+	for _, temperature := range subscription.Replay {
+		if send(temperature) != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case temperature, ok := <-subscription.C:
+			if !ok {
+				if err := subscription.Err(); err != nil {
+					logger.WithError(err).Warning("weather handler: stream disconnected")
+					closeWith(websocket.CloseTryAgainLater, err.Error())
+					return
+				}
+				closeWith(websocket.CloseGoingAway, "stream closed")
+				return
+			}
+			if send(temperature) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// parseLastEventID parses the id of the last temperature received by a resuming client, 0 when empty
+func parseLastEventID(id string) (int64, error) {
+	if id == "" {
+		return 0, nil
+	}
+
+	lastEventID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, core.Invalid("invalid last event id sent")
+	}
+	return lastEventID, nil
+}
@@ -0,0 +1,146 @@
+package weather_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/auth"
+	"github.com/walez/weather-monster/events"
+	mocks "github.com/walez/weather-monster/mocks"
+	"github.com/walez/weather-monster/weather"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamServer serves the routes of a handler streaming from broker to the tenant 1
+func streamServer(t *testing.T, broker *events.Broker) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	mockCtrl := gomock.NewController(t)
+	t.Cleanup(mockCtrl.Finish)
+
+	ws := mocks.NewMockWeatherService(mockCtrl)
+	ws.EXPECT().FindCitiesByIDs(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, ids []int64) ([]*core.City, error) {
+		var cities []*core.City
+		for _, id := range ids {
+			// the cities above 100 do not exist
+			if id <= 100 {
+				cities = append(cities, &core.City{ID: id, TenantID: 1})
+			}
+		}
+		return cities, nil
+	}).AnyTimes()
+	h := weather.NewHandler(ws, events.NewManager(context.Background()), discardAudit{},
+		weather.WithBroker(broker), weather.WithStream(time.Minute))
+
+	r := gin.New()
+	h.RegisterRoutes(r.Group(weather.V1Path, auth.Middleware(auth.DefaultTenant(&core.Tenant{ID: 1}, core.ScopeForecastsRead))))
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRoutes_CityStream(t *testing.T) {
+	ctx := context.Background()
+	broker := events.NewBroker()
+	srv := streamServer(t, broker)
+
+	require.NoError(t, broker.Publish(ctx, &core.Temperature{ID: 1, TenantID: 1, CityID: 1, Max: 10}))
+	require.NoError(t, broker.Publish(ctx, &core.Temperature{ID: 2, TenantID: 1, CityID: 1, Max: 20}))
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/v1/cities/1/stream", nil)
+	require.NoError(t, err)
+	req.Header.Set(weather.LastEventIDHeader, "1")
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	// the response headers are sent once subscribed
+	require.NoError(t, broker.Publish(ctx, &core.Temperature{ID: 3, TenantID: 1, CityID: 1, Max: 30}))
+	require.NoError(t, broker.Publish(ctx, &core.Temperature{ID: 4, TenantID: 2, CityID: 1, Max: 40}))
+	require.NoError(t, broker.Publish(ctx, &core.Temperature{ID: 5, TenantID: 1, CityID: 1, Max: 50}))
+
+	scanner := bufio.NewScanner(res.Body)
+	var events []string
+	for len(events) < 3 && scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "id:") {
+			events = append(events, scanner.Text())
+			require.True(t, scanner.Scan())
+			assert.Equal(t, "event:temperature", scanner.Text())
+			require.True(t, scanner.Scan())
+			assert.True(t, strings.HasPrefix(scanner.Text(), "data:{"), "temperatures are sent as JSON")
+		}
+	}
+	assert.Equal(t, []string{"id:2", "id:3", "id:5"}, events, "missed temperatures are replayed first")
+}
+
+func TestRoutes_TemperatureStream(t *testing.T) {
+	ctx := context.Background()
+	broker := events.NewBroker()
+	srv := streamServer(t, broker)
+
+	require.NoError(t, broker.Publish(ctx, &core.Temperature{ID: 1, TenantID: 1, CityID: 2}))
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/v1/temperatures/stream?city_id=1&city_id=2&last_event_id=0"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	t.Run("should stream the temperatures of every city", func(t *testing.T) {
+		// the connection is upgraded once subscribed
+		require.NoError(t, broker.Publish(ctx, &core.Temperature{ID: 2, TenantID: 1, CityID: 1}))
+		require.NoError(t, broker.Publish(ctx, &core.Temperature{ID: 3, TenantID: 1, CityID: 3}))
+		require.NoError(t, broker.Publish(ctx, &core.Temperature{ID: 4, TenantID: 1, CityID: 2}))
+
+		for _, id := range []int64{2, 4} {
+			_, message, err := conn.ReadMessage()
+			require.NoError(t, err)
+
+			temperature := core.Temperature{}
+			require.NoError(t, json.Unmarshal(message, &temperature))
+			assert.Equal(t, id, temperature.ID)
+		}
+	})
+
+	t.Run("should close the connection once the stream closes", func(t *testing.T) {
+		broker.Close()
+
+		_, _, err := conn.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "unexpected error %v", err)
+	})
+
+	t.Run("should bound the cities of a stream", func(t *testing.T) {
+		query := strings.Repeat("&city_id=1", 51)
+		res, err := http.Get(srv.URL + "/v1/temperatures/stream?" + query[1:])
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("should require the cities to exist", func(t *testing.T) {
+		res, err := http.Get(srv.URL + "/v1/temperatures/stream?city_id=1&city_id=101")
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("should require cities", func(t *testing.T) {
+		res, err := http.Get(srv.URL + "/v1/temperatures/stream")
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+}
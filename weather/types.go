@@ -3,8 +3,10 @@ package weather
 import (
 	"net/http"
 
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/logging"
+
 	"github.com/gin-gonic/gin"
-	log "github.com/sirupsen/logrus"
 )
 
 type CreateCityRequest struct {
@@ -22,6 +24,57 @@ type CreateTemperatureRequest struct {
 type CreateWebhookRequest struct {
 	CityID      string `json:"city_id,omitempty"`
 	CallbackURL string `json:"callback_url,omitempty"`
+	// PayloadVersion is the latest version when 0
+	PayloadVersion int `json:"payload_version,omitempty"`
+}
+
+// CreateWebhookResponse is the created webhook with the secret signing its deliveries, it is only answered once
+type CreateWebhookResponse struct {
+	ID             int64  `json:"id"`
+	CityID         int64  `json:"city_id"`
+	CallbackURL    string `json:"callback_url"`
+	PayloadVersion int    `json:"payload_version"`
+	Secret         string `json:"secret"`
+}
+
+func newCreateWebhookResponse(webhook *core.Webhook) *CreateWebhookResponse {
+	return &CreateWebhookResponse{
+		ID:             webhook.ID,
+		CityID:         webhook.CityID,
+		CallbackURL:    webhook.CallbackURL,
+		PayloadVersion: webhook.PayloadVersion,
+		Secret:         webhook.Secret,
+	}
+}
+
+type CityQuery struct {
+	Cursor int64 `form:"cursor"`
+	Limit  int   `form:"limit" binding:"min=0,max=100"`
+}
+
+type CitiesResponse struct {
+	Cities []*core.City `json:"cities"`
+	// NextCursor fetches the next page when set
+	NextCursor int64 `json:"next_cursor,omitempty"`
+}
+
+type WebhookQuery struct {
+	CityID int64 `form:"city_id" binding:"required"`
+}
+
+type AuditQuery struct {
+	Entity string `form:"entity"`
+	ID     int64  `form:"id"`
+	Actor  string `form:"actor"`
+	Action string `form:"action"`
+	Cursor int64  `form:"cursor"`
+	Limit  int    `form:"limit" binding:"min=0,max=100"`
+}
+
+type AuditResponse struct {
+	Entries []*core.AuditEntry `json:"entries"`
+	// NextCursor fetches the next page when set
+	NextCursor int64 `json:"next_cursor,omitempty"`
 }
 
 type Response struct {
@@ -29,16 +82,22 @@ type Response struct {
 	Message string `json:"message,omitempty"`
 }
 
+// httpStatuses answer each kind of error
+var httpStatuses = map[ErrorKind]int{
+	KindInternal:      http.StatusInternalServerError,
+	KindInvalid:       http.StatusBadRequest,
+	KindNotFound:      http.StatusNotFound,
+	KindQuotaExceeded: http.StatusTooManyRequests,
+}
+
 func (h *Handler) handleError(c *gin.Context, err error) {
-	log.
+	logging.FromContext(c.Request.Context()).
 		WithError(err).
-		WithFields(map[string]interface{}{
-			"endpoint": c.Request.URL.Path,
-			"error":    err,
-		}).Error("weather handler: error processing request")
+		Error("weather handler: error processing request")
 
-	c.SecureJSON(http.StatusBadRequest, Response{
+	kind, message := ClassifyError(err)
+	c.SecureJSON(httpStatuses[kind], Response{
 		Status:  false,
-		Message: "request failure",
+		Message: message,
 	})
 }
@@ -0,0 +1,177 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxPayloadSize bounds the body of deliveries, the api sends far smaller ones
+const maxPayloadSize = 1 << 20
+
+// DefaultDedupeWindow is how long the delivery ids handled are remembered by the default Deduplicator
+const DefaultDedupeWindow = 24 * time.Hour
+
+// Callback handles an event, deliveries it fails are answered with a 500 so that they can be sent again
+type Callback func(ctx context.Context, event *Event) error
+
+// Deduplicator remembers the deliveries handled, share one between receivers, e.g backed by redis,
+// so that a delivery is handled once across them
+type Deduplicator interface {
+	// Reserve returns false when the delivery was handled or is being handled already
+	Reserve(ctx context.Context, deliveryID string) (bool, error)
+	// Release forgets a delivery the callbacks failed to handle
+	Release(ctx context.Context, deliveryID string) error
+}
+
+// Handler is the http.Handler of the callback url of webhooks, it answers:
+//   - 401 to deliveries that are not signed with the secret, without delivery id or older than the tolerance
+//   - 400 to payloads that are not events of a known version
+//   - 200 to deliveries handled already without calling the callbacks again
+//   - 500 when a callback fails
+type Handler struct {
+	secret    string
+	callbacks []Callback
+	tolerance time.Duration
+	dedupe    Deduplicator
+	now       func() time.Time
+}
+
+// Option configures optional behaviour of the handler
+type Option func(*Handler)
+
+// WithTolerance sets how old deliveries can be, DefaultTolerance by default
+func WithTolerance(tolerance time.Duration) Option {
+	return func(h *Handler) {
+		h.tolerance = tolerance
+	}
+}
+
+// WithDeduplicator sets the deliveries handled, a memory of the last DefaultDedupeWindow by default
+func WithDeduplicator(dedupe Deduplicator) Option {
+	return func(h *Handler) {
+		h.dedupe = dedupe
+	}
+}
+
+// WithClock sets the current time the timestamp of deliveries is compared to, time.Now by default
+func WithClock(now func() time.Time) Option {
+	return func(h *Handler) {
+		h.now = now
+	}
+}
+
+// NewHandler returns a handler of the deliveries signed with secret calling callback with their events
+func NewHandler(secret string, callback Callback, opts ...Option) *Handler {
+	h := &Handler{
+		secret:    secret,
+		callbacks: []Callback{callback},
+		tolerance: DefaultTolerance,
+		dedupe:    NewMemoryDeduplicator(DefaultDedupeWindow),
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Handle adds a callback called after the previous ones, a delivery is handled once every callback succeeded
+func (h *Handler) Handle(callback Callback) {
+	h.callbacks = append(h.callbacks, callback)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+	if err != nil {
+		http.Error(w, "unable to read payload", http.StatusBadRequest)
+		return
+	}
+
+	err = Verify(h.secret, r.Header, payload, h.tolerance, h.now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	event, err := ParseEvent(payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	event.DeliveryID = r.Header.Get(DeliveryHeader)
+
+	// the delivery id is signed, Verify rejects deliveries without one
+	ctx := r.Context()
+	first, err := h.dedupe.Reserve(ctx, event.DeliveryID)
+	if err != nil {
+		http.Error(w, "webhook: unable to deduplicate delivery", http.StatusInternalServerError)
+		return
+	}
+	if !first {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	for _, callback := range h.callbacks {
+		if err := callback(ctx, event); err != nil {
+			h.dedupe.Release(ctx, event.DeliveryID)
+			http.Error(w, "webhook: callback failed", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// MemoryDeduplicator remembers the deliveries handled by a single receiver for a window of time
+type MemoryDeduplicator struct {
+	window time.Duration
+
+	mu     sync.Mutex
+	seen   map[string]time.Time
+	pruned time.Time
+}
+
+// NewMemoryDeduplicator remembers deliveries for window, it should exceed how long the api sends a delivery again
+func NewMemoryDeduplicator(window time.Duration) *MemoryDeduplicator {
+	return &MemoryDeduplicator{window: window, seen: map[string]time.Time{}}
+}
+
+func (d *MemoryDeduplicator) Reserve(ctx context.Context, deliveryID string) (bool, error) {
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// forgetting expired deliveries at most once per window keeps reservations cheap
+	if now.Sub(d.pruned) > d.window {
+		for id, reserved := range d.seen {
+			if now.Sub(reserved) > d.window {
+				delete(d.seen, id)
+			}
+		}
+		d.pruned = now
+	}
+
+	if reserved, ok := d.seen[deliveryID]; ok && now.Sub(reserved) <= d.window {
+		return false, nil
+	}
+	d.seen[deliveryID] = now
+	return true, nil
+}
+
+func (d *MemoryDeduplicator) Release(ctx context.Context, deliveryID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.seen, deliveryID)
+	return nil
+}
@@ -0,0 +1,173 @@
+package webhook
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	core "github.com/walez/weather-monster"
+)
+
+// Payload versions a webhook can pin, receivers are sent the version of their webhook until they move
+// to a newer one
+const (
+	// Version1 is the flat temperature posted before payloads were versioned, e.g
+	// {"city_id": 1, "max": 20, "min": 10, "timestamp": 1600000000}
+	Version1 = 1
+	// Version2 wraps the event in an Envelope holding the city and the temperature
+	Version2 = 2
+	// LatestVersion is pinned by the webhooks created without a version
+	LatestVersion = Version2
+)
+
+// Versions lists the payload versions, oldest first
+var Versions = []int{Version1, Version2}
+
+// EventTemperatureCreated is the type of the event sent when a temperature is created for the city of a webhook
+const EventTemperatureCreated = "temperature.created"
+
+var ErrUnknownVersion = errors.New("webhook: unknown payload version")
+
+// ValidVersion reports whether version is one of Versions
+func ValidVersion(version int) bool {
+	for _, v := range Versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Envelope is the payload of Version2 onwards, Data depends on the Type
+type Envelope struct {
+	Type string `json:"type"`
+	// ID identifies the event, it is the same for every webhook of the city
+	ID        string          `json:"id"`
+	Version   int             `json:"version"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// TemperatureCreated is the data of EventTemperatureCreated events
+type TemperatureCreated struct {
+	City        core.City        `json:"city"`
+	Temperature core.Temperature `json:"temperature"`
+}
+
+// legacyTemperature is the Version1 payload
+type legacyTemperature struct {
+	CityID    int64 `json:"city_id"`
+	Max       int   `json:"max"`
+	Min       int   `json:"min"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+// Event is a decoded payload, whatever its version
+type Event struct {
+	// DeliveryID identifies the delivery of the event, from DeliveryHeader
+	DeliveryID string `json:"-"`
+	Type       string `json:"type"`
+	// ID identifies the event, empty for Version1
+	ID        string    `json:"id,omitempty"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	// City only has its ID for Version1
+	City        core.City        `json:"city"`
+	Temperature core.Temperature `json:"temperature"`
+}
+
+// NewPayload encodes the event of temperature created for city in version
+func NewPayload(version int, city *core.City, temperature *core.Temperature) ([]byte, error) {
+	switch version {
+	case Version1:
+		return json.Marshal(legacyTemperature{
+			CityID:    temperature.CityID,
+			Max:       temperature.Max,
+			Min:       temperature.Min,
+			Timestamp: temperature.Timestamp,
+		})
+
+	case Version2:
+		data, err := json.Marshal(TemperatureCreated{City: *city, Temperature: *temperature})
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(Envelope{
+			Type:      EventTemperatureCreated,
+			ID:        EventID(temperature),
+			Version:   Version2,
+			CreatedAt: time.Unix(temperature.Timestamp, 0).UTC(),
+			Data:      data,
+		})
+
+	default:
+		return nil, ErrUnknownVersion
+	}
+}
+
+// EventID identifies the event of the creation of temperature
+func EventID(temperature *core.Temperature) string {
+	return "evt_temperature_" + strconv.FormatInt(temperature.ID, 10)
+}
+
+// ParseEvent decodes a payload of any version, payloads without version are Version1 ones
+func ParseEvent(payload []byte) (*Event, error) {
+	envelope := &Envelope{}
+	if err := json.Unmarshal(payload, envelope); err != nil {
+		return nil, errors.New("webhook: invalid payload")
+	}
+
+	switch envelope.Version {
+	case 0:
+		legacy := &legacyTemperature{}
+		if err := json.Unmarshal(payload, legacy); err != nil {
+			return nil, errors.New("webhook: invalid payload")
+		}
+		return &Event{
+			Type:      EventTemperatureCreated,
+			Version:   Version1,
+			CreatedAt: time.Unix(legacy.Timestamp, 0).UTC(),
+			City:      core.City{ID: legacy.CityID},
+			Temperature: core.Temperature{
+				CityID:    legacy.CityID,
+				Max:       legacy.Max,
+				Min:       legacy.Min,
+				Timestamp: legacy.Timestamp,
+			},
+		}, nil
+
+	case Version2:
+		if envelope.Type != EventTemperatureCreated {
+			return nil, fmt.Errorf("webhook: unknown event type %q", envelope.Type)
+		}
+		data := &TemperatureCreated{}
+		if err := json.Unmarshal(envelope.Data, data); err != nil {
+			return nil, errors.New("webhook: invalid payload data")
+		}
+		return &Event{
+			Type:        envelope.Type,
+			ID:          envelope.ID,
+			Version:     envelope.Version,
+			CreatedAt:   envelope.CreatedAt,
+			City:        data.City,
+			Temperature: data.Temperature,
+		}, nil
+
+	default:
+		return nil, ErrUnknownVersion
+	}
+}
+
+//go:embed schemas/*.json
+var schemas embed.FS
+
+// Schema returns the JSON Schema of the payloads of version, receivers can validate payloads with it
+func Schema(version int) ([]byte, error) {
+	if !ValidVersion(version) {
+		return nil, ErrUnknownVersion
+	}
+	return schemas.ReadFile(fmt.Sprintf("schemas/v%d.json", version))
+}
@@ -0,0 +1,140 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/webhook"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testCity        = &core.City{ID: 1, Name: "Lagos", Latitude: 6.45, Longitude: 3.39}
+	testTemperature = &core.Temperature{ID: 7, CityID: 1, Max: 20, Min: 10, Timestamp: 1600000000}
+)
+
+func TestPayload(t *testing.T) {
+	t.Run("should keep the flat temperature in version 1", func(t *testing.T) {
+		payload, err := webhook.NewPayload(webhook.Version1, nil, testTemperature)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"city_id": 1, "max": 20, "min": 10, "timestamp": 1600000000}`, string(payload))
+
+		event, err := webhook.ParseEvent(payload)
+		require.NoError(t, err)
+		assert.Equal(t, webhook.Version1, event.Version)
+		assert.Equal(t, webhook.EventTemperatureCreated, event.Type)
+		assert.Equal(t, int64(1), event.City.ID)
+		assert.Equal(t, 20, event.Temperature.Max)
+	})
+
+	t.Run("should wrap the city and temperature in an envelope from version 2", func(t *testing.T) {
+		payload, err := webhook.NewPayload(webhook.Version2, testCity, testTemperature)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"type": "temperature.created",
+			"id": "evt_temperature_7",
+			"version": 2,
+			"created_at": "2020-09-13T12:26:40Z",
+			"data": {
+				"city": {"id": 1, "name": "Lagos", "latitude": 6.45, "longitude": 3.39},
+				"temperature": {"id": 7, "city_id": 1, "max": 20, "min": 10, "timestamp": 1600000000}
+			}
+		}`, string(payload))
+
+		event, err := webhook.ParseEvent(payload)
+		require.NoError(t, err)
+		assert.Equal(t, &webhook.Event{
+			Type:        webhook.EventTemperatureCreated,
+			ID:          "evt_temperature_7",
+			Version:     webhook.Version2,
+			CreatedAt:   time.Unix(1600000000, 0).UTC(),
+			City:        *testCity,
+			Temperature: *testTemperature,
+		}, event)
+	})
+
+	t.Run("should reject unknown versions", func(t *testing.T) {
+		_, err := webhook.NewPayload(99, testCity, testTemperature)
+		assert.Equal(t, webhook.ErrUnknownVersion, err)
+		_, err = webhook.Schema(99)
+		assert.Equal(t, webhook.ErrUnknownVersion, err)
+	})
+}
+
+// schema is the subset of JSON Schema used by the published schemas
+type schema struct {
+	Ref         string             `json:"$ref"`
+	Type        string             `json:"type"`
+	Required    []string           `json:"required"`
+	Properties  map[string]*schema `json:"properties"`
+	Definitions map[string]*schema `json:"definitions"`
+}
+
+func TestSchema(t *testing.T) {
+	for _, version := range webhook.Versions {
+		raw, err := webhook.Schema(version)
+		require.NoError(t, err, "version %d has a schema", version)
+
+		root := &schema{}
+		require.NoError(t, json.Unmarshal(raw, root))
+
+		payload, err := webhook.NewPayload(version, testCity, testTemperature)
+		require.NoError(t, err)
+		var value interface{}
+		require.NoError(t, json.Unmarshal(payload, &value))
+
+		conforms(t, root, root, value, fmt.Sprintf("v%d", version))
+	}
+}
+
+// conforms checks that the objects of value have the required and only the documented properties of s,
+// and that their values have the type documented
+func conforms(t *testing.T, root *schema, s *schema, value interface{}, path string) {
+	if s.Ref != "" {
+		s = root.Definitions[strings.TrimPrefix(s.Ref, "#/definitions/")]
+		require.NotNil(t, s, "%s refers to a missing definition", path)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if s.Type != "" {
+			assert.Equal(t, "object", s.Type, path)
+		}
+
+		var documented, encoded []string
+		for name := range s.Properties {
+			documented = append(documented, name)
+		}
+		for name, property := range v {
+			encoded = append(encoded, name)
+			if ps, ok := s.Properties[name]; ok {
+				conforms(t, root, ps, property, path+"."+name)
+			}
+		}
+		sort.Strings(documented)
+		sort.Strings(encoded)
+		assert.Equal(t, documented, encoded, "properties of %s", path)
+		for _, name := range s.Required {
+			assert.Contains(t, v, name, "required property of %s", path)
+		}
+
+	case float64:
+		if s.Type == "integer" {
+			assert.Equal(t, float64(int64(v)), v, "%s is an integer", path)
+		} else if s.Type != "" {
+			assert.Equal(t, "number", s.Type, path)
+		}
+
+	case string:
+		if s.Type != "" {
+			assert.Equal(t, "string", s.Type, path)
+		}
+	}
+}
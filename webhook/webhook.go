@@ -0,0 +1,115 @@
+// Package webhook receives the callbacks the weather API sends to webhooks when temperatures are created.
+// It verifies their signature, deduplicates them and dispatches them as typed events:
+//
+//	http.Handle("/callback", webhook.NewHandler(secret, func(ctx context.Context, event *webhook.Event) error {
+//		return store(ctx, event.City.Name, event.Temperature.Max, event.Temperature.Min)
+//	}))
+//
+// The api signs deliveries with the secret answered when the webhook was created. Events are decoded from
+// the payload version pinned by the webhook, Version1 payloads only detail the id of the city.
+// Deliveries already handled are acknowledged without calling back again, and deliveries whose callback
+// fails are answered with an error so that the api sends them again.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with each delivery
+const (
+	// DeliveryHeader identifies a delivery, it is the same when a delivery is sent again
+	DeliveryHeader = "X-Weather-Delivery"
+	// TimestampHeader is the unix time in seconds at which the delivery was signed
+	TimestampHeader = "X-Weather-Timestamp"
+	// SignatureHeader holds the signatures of the delivery, e.g v1=<hex>
+	SignatureHeader = "X-Weather-Signature"
+)
+
+// signatureVersion prefixes the HMAC-SHA256 signature of the timestamp and body
+const signatureVersion = "v1="
+
+// DefaultTolerance is how old a delivery can be, it bounds how long a captured delivery can be replayed
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	ErrMissingSignature = errors.New("webhook: missing signature")
+	ErrMissingDelivery  = errors.New("webhook: missing delivery id")
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	ErrInvalidTimestamp = errors.New("webhook: timestamp outside of the tolerance")
+)
+
+// secretPrefix marks webhook secrets so that they are recognised when leaked, like api keys
+const secretPrefix = "whsec_"
+
+// NewSecret returns a random secret to sign the deliveries of a webhook
+func NewSecret() (string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return secretPrefix + hex.EncodeToString(secret), nil
+}
+
+// Sign returns the signature of the delivery of payload sent at timestamp, the value of SignatureHeader.
+// The delivery id is signed so that a captured delivery cannot be replayed under another id.
+func Sign(secret string, deliveryID string, timestamp time.Time, payload []byte) string {
+	return signatureVersion + hex.EncodeToString(mac(secret, strconv.FormatInt(timestamp.Unix(), 10), deliveryID, payload))
+}
+
+// SetHeaders sets the delivery, timestamp and signature headers of a delivery of payload
+func SetHeaders(header http.Header, secret string, deliveryID string, timestamp time.Time, payload []byte) {
+	header.Set(DeliveryHeader, deliveryID)
+	header.Set(TimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	header.Set(SignatureHeader, Sign(secret, deliveryID, timestamp, payload))
+}
+
+// Verify checks that the delivery of payload was signed with secret less than tolerance before now,
+// the signature header may hold several comma separated signatures while secrets are rotated
+func Verify(secret string, header http.Header, payload []byte, tolerance time.Duration, now time.Time) error {
+	signatures := header.Get(SignatureHeader)
+	timestamp := header.Get(TimestampHeader)
+	if signatures == "" || timestamp == "" {
+		return ErrMissingSignature
+	}
+	deliveryID := header.Get(DeliveryHeader)
+	if deliveryID == "" {
+		return ErrMissingDelivery
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return ErrInvalidTimestamp
+	}
+
+	expected := mac(secret, timestamp, deliveryID, payload)
+	for _, signature := range strings.Split(signatures, ",") {
+		signature = strings.TrimSpace(signature)
+		if !strings.HasPrefix(signature, signatureVersion) {
+			continue
+		}
+		sum, err := hex.DecodeString(strings.TrimPrefix(signature, signatureVersion))
+		if err == nil && hmac.Equal(sum, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// mac is the HMAC-SHA256 of the timestamp, delivery id and payload separated by dots
+func mac(secret string, timestamp string, deliveryID string, payload []byte) []byte {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(timestamp + "." + deliveryID + "."))
+	m.Write(payload)
+	return m.Sum(nil)
+}
@@ -0,0 +1,164 @@
+package webhook_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	core "github.com/walez/weather-monster"
+	"github.com/walez/weather-monster/webhook"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const secret = "whsec_test"
+
+var now = time.Unix(1600000000, 0)
+
+// delivery returns a delivery of payload signed with secret at signedAt
+func delivery(id string, payload string, secret string, signedAt time.Time) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/callback", bytes.NewBufferString(payload))
+	webhook.SetHeaders(r.Header, secret, id, signedAt, []byte(payload))
+	return r
+}
+
+func TestVerify(t *testing.T) {
+	payload := []byte(`{"city_id":1}`)
+
+	t.Run("should accept signed payloads", func(t *testing.T) {
+		header := http.Header{}
+		webhook.SetHeaders(header, secret, "1-1", now, payload)
+		assert.NoError(t, webhook.Verify(secret, header, payload, time.Minute, now.Add(30*time.Second)))
+	})
+
+	t.Run("should accept any of the signatures sent", func(t *testing.T) {
+		header := http.Header{}
+		webhook.SetHeaders(header, secret, "1-1", now, payload)
+		header.Set(webhook.SignatureHeader, "v1=00ff, "+header.Get(webhook.SignatureHeader))
+		assert.NoError(t, webhook.Verify(secret, header, payload, time.Minute, now))
+	})
+
+	t.Run("should reject other secrets and payloads", func(t *testing.T) {
+		header := http.Header{}
+		webhook.SetHeaders(header, "whsec_other", "1-1", now, payload)
+		assert.Equal(t, webhook.ErrInvalidSignature, webhook.Verify(secret, header, payload, time.Minute, now))
+
+		webhook.SetHeaders(header, secret, "1-1", now, payload)
+		assert.Equal(t, webhook.ErrInvalidSignature, webhook.Verify(secret, header, []byte(`{"city_id":2}`), time.Minute, now))
+	})
+
+	t.Run("should reject replayed payloads", func(t *testing.T) {
+		header := http.Header{}
+		webhook.SetHeaders(header, secret, "1-1", now, payload)
+		assert.Equal(t, webhook.ErrInvalidTimestamp, webhook.Verify(secret, header, payload, time.Minute, now.Add(2*time.Minute)))
+	})
+
+	t.Run("should reject unsigned payloads", func(t *testing.T) {
+		assert.Equal(t, webhook.ErrMissingSignature, webhook.Verify(secret, http.Header{}, payload, time.Minute, now))
+	})
+
+	t.Run("should reject payloads signed for another delivery", func(t *testing.T) {
+		header := http.Header{}
+		webhook.SetHeaders(header, secret, "1-1", now, payload)
+		header.Set(webhook.DeliveryHeader, "1-2")
+		assert.Equal(t, webhook.ErrInvalidSignature, webhook.Verify(secret, header, payload, time.Minute, now))
+
+		header.Del(webhook.DeliveryHeader)
+		assert.Equal(t, webhook.ErrMissingDelivery, webhook.Verify(secret, header, payload, time.Minute, now))
+	})
+}
+
+func TestHandler(t *testing.T) {
+	var mu sync.Mutex
+	var received []*webhook.Event
+	fail := false
+	h := webhook.NewHandler(secret, func(ctx context.Context, event *webhook.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			return errors.New("unavailable")
+		}
+		received = append(received, event)
+		return nil
+	}, webhook.WithClock(func() time.Time { return now }))
+
+	serve := func(r *http.Request) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	t.Run("should dispatch typed events", func(t *testing.T) {
+		code := serve(delivery("1-1", `{"city_id":1,"max":20,"min":10,"timestamp":1600000000}`, secret, now))
+		require.Equal(t, http.StatusOK, code)
+
+		require.Len(t, received, 1)
+		assert.Equal(t, "1-1", received[0].DeliveryID)
+		assert.Equal(t, webhook.EventTemperatureCreated, received[0].Type)
+		assert.Equal(t, core.Temperature{CityID: 1, Max: 20, Min: 10, Timestamp: 1600000000}, received[0].Temperature)
+	})
+
+	t.Run("should acknowledge deliveries handled already", func(t *testing.T) {
+		code := serve(delivery("1-1", `{"city_id":1,"max":20,"min":10}`, secret, now))
+		assert.Equal(t, http.StatusOK, code)
+		assert.Len(t, received, 1, "duplicates are not dispatched")
+	})
+
+	t.Run("should not dispatch deliveries replayed under another id", func(t *testing.T) {
+		payload := `{"city_id":1,"max":20,"min":10,"timestamp":1600000000}`
+		replayed := delivery("1-1", payload, secret, now)
+		replayed.Header.Set(webhook.DeliveryHeader, "1-99")
+		assert.Equal(t, http.StatusUnauthorized, serve(replayed))
+
+		replayed = delivery("1-1", payload, secret, now)
+		replayed.Header.Del(webhook.DeliveryHeader)
+		assert.Equal(t, http.StatusUnauthorized, serve(replayed))
+		assert.Len(t, received, 1, "replays are not dispatched")
+	})
+
+	t.Run("should reject deliveries not signed with the secret", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, serve(delivery("1-2", `{"city_id":1}`, "whsec_other", now)))
+		assert.Equal(t, http.StatusUnauthorized, serve(delivery("1-2", `{"city_id":1}`, secret, now.Add(-time.Hour))))
+		assert.Len(t, received, 1)
+	})
+
+	t.Run("should reject payloads that are not events", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve(delivery("1-3", `[1]`, secret, now)))
+		assert.Equal(t, http.StatusBadRequest, serve(delivery("1-3", `{"version":99}`, secret, now)), "versions are known")
+	})
+
+	t.Run("should accept deliveries again when callbacks fail", func(t *testing.T) {
+		fail = true
+		assert.Equal(t, http.StatusInternalServerError, serve(delivery("1-4", `{"city_id":1}`, secret, now)))
+
+		fail = false
+		assert.Equal(t, http.StatusOK, serve(delivery("1-4", `{"city_id":1}`, secret, now)))
+		assert.Len(t, received, 2)
+	})
+
+	t.Run("should only accept posts", func(t *testing.T) {
+		assert.Equal(t, http.StatusMethodNotAllowed, serve(httptest.NewRequest(http.MethodGet, "/callback", nil)))
+	})
+}
+
+func TestMemoryDeduplicator(t *testing.T) {
+	ctx := context.Background()
+	d := webhook.NewMemoryDeduplicator(50 * time.Millisecond)
+
+	first, err := d.Reserve(ctx, "1-1")
+	require.NoError(t, err)
+	assert.True(t, first)
+
+	first, _ = d.Reserve(ctx, "1-1")
+	assert.False(t, first)
+
+	time.Sleep(60 * time.Millisecond)
+	first, _ = d.Reserve(ctx, "1-1")
+	assert.True(t, first, "deliveries are forgotten after the window")
+}